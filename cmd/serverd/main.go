@@ -3,22 +3,80 @@ package main
 import (
 	"context"
 	"crypto/rsa"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/audit"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/config"
 	"github.com/the-witcher-knight/jwt-encryption-server/internal/handler"
 	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/metrics"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/policy"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/rbac"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/resource"
 	"github.com/the-witcher-knight/jwt-encryption-server/internal/secrets"
 	"github.com/the-witcher-knight/jwt-encryption-server/internal/service"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/tenant"
 	"github.com/the-witcher-knight/jwt-encryption-server/internal/tracing"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+	"github.com/the-witcher-knight/jwt-encryption-server/pkg/dpop"
+	"github.com/the-witcher-knight/jwt-encryption-server/pkg/tokenhook"
 )
 
+// Files of an issuer, in the data directory or the directory of a tenant.
 const (
-	addr           = ":8080"
 	privateKeyPath = "private-key.pem"
+	usersPath      = "users.json"
+	clientsPath    = "clients.json"
+	resourcesPath  = "resources.json"
+	rolesPath      = "roles.json"
+	policyPath     = "policy.json"
+	tokenHookPath  = "token-hook.json"
+	auditLogPath   = "audit.log"
+)
+
+// Paths of the admin endpoints.
+const (
+	pathMetrics = "/metrics"
+	// pathLogLevel is only served on the admin listener.
+	pathLogLevel = "/log/level"
+)
+
+var (
+	sampleClient = client.Client{
+		ID:           "sample-client-id",
+		Secret:       "sample-client-secret",
+		Name:         "Sample Client",
+		RedirectURIs: []string{"http://localhost:9999/callback"},
+		GrantTypes: []string{
+			client.GrantTypeClientCredentials,
+			client.GrantTypeAuthorizationCode,
+			client.GrantTypeDeviceCode,
+			client.GrantTypeTokenExchange,
+			client.GrantTypeRefreshToken,
+		},
+		Scopes: []string{"openid", "profile", "email", "read", "write"},
+		TokenExchange: []client.TokenExchangeRule{
+			{SubjectAudience: "http://localhost:9999/", Audiences: []string{"http://localhost:9998/"}},
+		},
+	}
 )
 
 var (
@@ -26,49 +84,233 @@ var (
 )
 
 func main() {
-	if err := run(); err != nil {
+	args := os.Args[1:]
+	if len(args) >= 2 && args[0] == "config" && args[1] == "check" {
+		if _, err := config.Load("serverd config check", args[2:], os.Getenv); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("configuration is valid")
+		return
+	}
+
+	if len(args) >= 2 && args[0] == "audit" && args[1] == "export" {
+		if err := exportAudit(args[2:], os.Stdout); err != nil && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "could not export audit events: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := config.Load("serverd", args, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logger.Printf("invalid configuration: %v", err)
+		os.Exit(2)
+	}
+
+	load := func() (config.Config, error) {
+		return config.Load("serverd", args, os.Getenv)
+	}
+
+	if err := run(cfg, load); err != nil {
 		logger.Printf("server exited abnormally %+v", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
-	fileBytes, err := os.ReadFile(privateKeyPath)
+// run reads the configuration again with load to apply the changes that do not need a restart.
+func run(cfg config.Config, load func() (config.Config, error)) error {
+	tracer, tracerFiles, err := newTracer(cfg)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		for _, f := range tracerFiles {
+			_ = f.Close()
+		}
+	}()
+	defer func() {
+		if err := tracer.Flush(); err != nil {
+			logger.Printf("error flushing tracer: %+v", err)
+		}
+	}()
 
-	privateKey, err := secrets.LoadPrivateKeyFromPEM[*rsa.PrivateKey](fileBytes, "")
+	// State is kept in process memory without a storage URL.
+	kv, kvCloser, err := storage.Open(cfg.StorageURL())
 	if err != nil {
 		return err
 	}
+	defer kvCloser.Close()
 
-	tracer := tracing.New()
-	defer func() {
-		if err := tracer.Flush(); err != nil {
-			logger.Printf("error flushing tracer: %+v", err)
+	if resp, ok := kv.(*storage.RESPStore); ok {
+		if err := resp.Ping(context.Background()); err != nil {
+			return fmt.Errorf("storage is unavailable: %w", err)
 		}
-	}()
+	}
 
-	svc, err := service.NewRSASignatureService(privateKey)
-	hdl := handler.New(svc)
+	// A single server keeps its clients and audit events in the storage file with the rest of its state.
+	_, persistent := kv.(*storage.FileStore)
+
+	// Metrics of all issuers, labeled with the issuer.
+	reg := metrics.NewRegistry()
+
+	// Options shared by all tenants of the deployment.
+	opts := []handler.Option{handler.WithMetrics(handler.NewMetrics(reg))}
+
+	// Mutual-TLS client authentication needs TLS.
+	if cfg.TLS.Enabled {
+		opts = append(opts, handler.WithClientCertificates())
+
+		pool, err := cfg.ClientCAs()
+		if err != nil {
+			return err
+		}
+		if pool != nil {
+			opts = append(opts, handler.WithClientCAs(pool))
+		}
+	}
+
+	if cfg.DPoP.NonceInterval > 0 {
+		opts = append(opts, handler.WithDPoPNonces(dpop.NewSharedNonces(kv, time.Duration(cfg.DPoP.NonceInterval))))
+	}
+
+	// The sample client serves demos when there is no clients file.
+	hdl, auditLog, err := loadHandler(cfg.DataDir, cfg.Path(cfg.PrivateKeyFile), kv, persistent, []client.Client{sampleClient}, append(slices.Clone(opts),
+		handler.WithIssuer(cfg.Issuer),
+		handler.WithAudience(cfg.Audience),
+		handler.WithAccessTokenTTL(time.Duration(cfg.AccessTokenTTL)),
+	)...)
+	if err != nil {
+		return err
+	}
+	defer auditLog.Close()
+
+	tenants, err := tenant.Load(cfg.Path(cfg.TenantsDir))
+	if err != nil {
+		return err
+	}
+
+	tenantHandlers := make(map[string]handler.Handler, len(tenants))
+	for _, t := range tenants {
+		tenantOpts := append(slices.Clone(opts), handler.WithIssuer(t.Issuer(cfg.Issuer)), handler.WithAudience(cfg.Audience),
+			handler.WithAccessTokenTTL(time.Duration(cfg.AccessTokenTTL)))
+		if t.Config.Audience != "" {
+			tenantOpts = append(tenantOpts, handler.WithAudience(t.Config.Audience))
+		}
+		if t.Config.AccessTokenTTL > 0 {
+			tenantOpts = append(tenantOpts, handler.WithAccessTokenTTL(time.Duration(t.Config.AccessTokenTTL)))
+		}
+
+		tenantHdl, tenantAuditLog, err := loadHandler(t.Dir, t.Path(privateKeyPath), tenantStore(kv, t.ID), persistent, nil, tenantOpts...)
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", t.ID, err)
+		}
+		defer tenantAuditLog.Close()
+
+		tenantHandlers[t.ID] = tenantHdl
+	}
+
+	// Issuers share the limits of addresses, the limits of clients are kept apart per issuer.
+	limits := httpserver.NewRateLimitStore(kv)
+	rateLimit := func(scope string) gin.HandlerFunc {
+		limitCfg := cfg.RateLimitConfig()
+		limitCfg.Scope = scope
+		return httpserver.RateLimit(limits, limitCfg)
+	}
 
 	// Setup HTTP server
 	srv := &http.Server{
-		Addr:         addr,
-		ReadTimeout:  time.Second,
-		WriteTimeout: 10 * time.Second,
-		Handler:      newHTTPHandler(tracing.SetInContext(context.Background(), tracer), hdl),
+		Addr:         cfg.Addr,
+		ReadTimeout:  time.Duration(cfg.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.WriteTimeout),
+		Handler:      newHTTPHandler(tracing.SetInContext(context.Background(), tracer), reg, cfg.Metrics.Addr == "", hdl, tenantHandlers, rateLimit),
+	}
+
+	// The metrics are kept off the public listener when an admin listener serves them.
+	var metricsSrv *http.Server
+	if cfg.Metrics.Addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle(pathMetrics, reg.Handler())
+		mux.Handle(pathLogLevel, tracer.LevelHandler())
+		metricsSrv = &http.Server{
+			Addr:         cfg.Metrics.Addr,
+			ReadTimeout:  time.Duration(cfg.ReadTimeout),
+			WriteTimeout: time.Duration(cfg.WriteTimeout),
+			Handler:      mux,
+		}
+	}
+
+	var redirectSrv *http.Server
+	if cfg.TLS.Enabled {
+		if srv.TLSConfig, err = httpserver.TLSConfig(cfg.TLS.MinVersion, cfg.TLS.CipherPolicy); err != nil {
+			return err
+		}
+
+		certs, err := httpserver.NewCertificateReloader(cfg.Path(cfg.TLS.CertFile), cfg.Path(cfg.TLS.KeyFile))
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig.GetCertificate = certs.GetCertificate
+
+		// Client certificates are requested but verified by the handler, which accepts self-signed
+		// certificates registered by clients as well as CA-issued ones.
+		srv.TLSConfig.ClientAuth = tls.RequestClientCert
+
+		if cfg.TLS.RedirectAddr != "" {
+			redirectSrv = &http.Server{
+				Addr:         cfg.TLS.RedirectAddr,
+				ReadTimeout:  time.Duration(cfg.ReadTimeout),
+				WriteTimeout: time.Duration(cfg.WriteTimeout),
+				Handler:      httpserver.RedirectHandler(cfg.Addr),
+			}
+		}
+	} else {
+		logger.Printf("serving without TLS: tokens are only safe to issue on localhost")
 	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	srvErr := make(chan error, 1)
+	// The log level is reloaded from the configuration on SIGHUP, which needs no admin listener.
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
 	go func() {
+		for {
+			select {
+			case <-hangup:
+				reloadLogLevel(tracer, load)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	srvErr := make(chan error, 3)
+	go func() {
+		if cfg.TLS.Enabled {
+			srvErr <- srv.ListenAndServeTLS("", "")
+			return
+		}
 		srvErr <- srv.ListenAndServe()
 	}()
 
+	if redirectSrv != nil {
+		go func() {
+			srvErr <- redirectSrv.ListenAndServe()
+		}()
+	}
+
+	if metricsSrv != nil {
+		go func() {
+			srvErr <- metricsSrv.ListenAndServe()
+		}()
+	}
+
 	// Wait for interruption
 	select {
 	case err := <-srvErr:
@@ -78,15 +320,314 @@ func run() error {
 		stop()
 	}
 
+	if redirectSrv != nil {
+		if err := redirectSrv.Shutdown(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(context.Background()); err != nil {
+			return err
+		}
+	}
+
 	return srv.Shutdown(context.Background())
 }
 
-func newHTTPHandler(rootCtx context.Context, hdl handler.Handler) http.Handler {
+// loadHandler registers the fallback clients when dir has no clients file. A persistent kv also keeps the
+// clients and audit events. The returned audit log must be closed when the server stops.
+func loadHandler(dir, keyPath string, kv storage.Store, persistent bool, fallbackClients []client.Client, opts ...handler.Option) (handler.Handler, io.Closer, error) {
+	fileBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		return handler.Handler{}, nil, err
+	}
+
+	privateKey, err := secrets.LoadPrivateKeyFromPEM[*rsa.PrivateKey](fileBytes, "")
+	if err != nil {
+		return handler.Handler{}, nil, err
+	}
+
+	// The key is as old as its file, which is replaced to rotate it.
+	keyInfo, err := os.Stat(keyPath)
+	if err != nil {
+		return handler.Handler{}, nil, err
+	}
+
+	users, err := user.NewFileStore(filepath.Join(dir, usersPath))
+	if err != nil {
+		return handler.Handler{}, nil, err
+	}
+
+	// Clients are read from the clients file when there is one, or imported from it into a persistent kv.
+	clients := client.NewMemoryRegistry(fallbackClients...)
+	if persistent {
+		if clients, err = client.NewStoreRegistry(context.Background(), kv, filepath.Join(dir, clientsPath), fallbackClients...); err != nil {
+			return handler.Handler{}, nil, err
+		}
+	} else if _, err := os.Stat(filepath.Join(dir, clientsPath)); err == nil {
+		if clients, err = client.NewFileRegistry(filepath.Join(dir, clientsPath)); err != nil {
+			return handler.Handler{}, nil, err
+		}
+	}
+
+	resources, err := resource.NewFileRegistry(filepath.Join(dir, resourcesPath))
+	if err != nil {
+		return handler.Handler{}, nil, err
+	}
+
+	roles, err := rbac.NewFileStore(filepath.Join(dir, rolesPath))
+	if err != nil {
+		return handler.Handler{}, nil, err
+	}
+
+	policies, err := policy.NewFileSource(filepath.Join(dir, policyPath))
+	if err != nil {
+		return handler.Handler{}, nil, err
+	}
+
+	svc, err := service.NewRSASignatureService(privateKey)
+	if err != nil {
+		return handler.Handler{}, nil, err
+	}
+
+	// Access tokens are sent to the token hook before being signed when one is configured.
+	if _, err := os.Stat(filepath.Join(dir, tokenHookPath)); err == nil {
+		hook, err := loadTokenHook(filepath.Join(dir, tokenHookPath))
+		if err != nil {
+			return handler.Handler{}, nil, err
+		}
+		opts = append(opts, handler.WithTokenHook(hook))
+	}
+
+	// Audit events are appended to the audit log, or kept in a persistent kv.
+	var recorder audit.Recorder
+	var auditLog io.Closer = io.NopCloser(nil)
+	if persistent {
+		recorder = audit.NewStoreRecorder(kv)
+	} else {
+		f, err := os.OpenFile(filepath.Join(dir, auditLogPath), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return handler.Handler{}, nil, err
+		}
+		recorder, auditLog = audit.NewWriterRecorder(f), f
+	}
+
+	opts = append(opts,
+		handler.WithClients(clients),
+		handler.WithResources(resources),
+		handler.WithRoles(roles),
+		handler.WithPolicy(policies),
+		handler.WithUsers(user.NewLockoutStore(users, kv, user.DefaultLockoutPolicy)),
+		handler.WithStorage(kv),
+		handler.WithAuditRecorder(recorder),
+		handler.WithSigningKeyCreatedAt(keyInfo.ModTime()),
+	)
+
+	return handler.New(svc, opts...), auditLog, nil
+}
+
+func reloadLogLevel(tracer *tracing.Tracer, load func() (config.Config, error)) {
+	cfg, err := load()
+	if err != nil {
+		logger.Printf("could not reload configuration: %v", err)
+		return
+	}
+
+	// The level was validated by load.
+	level, _ := tracing.ParseLevel(cfg.Log.Level)
+	if level != tracer.Level() {
+		tracer.SetLevel(level)
+		logger.Printf("log level set to %s", level)
+	}
+}
+
+func tenantStore(kv storage.Store, tenantID string) storage.Store {
+	return storage.Prefixed(kv, "tenant:"+tenantID+":")
+}
+
+// exportAudit writes the events of the tenant given as the first argument, or of the issuer of the
+// deployment. The server must be stopped since it locks the file.
+func exportAudit(args []string, w io.Writer) error {
+	var tenantID string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		tenantID, args = args[0], args[1:]
+	}
+
+	cfg, err := config.Load("serverd audit export", args, os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	kv, kvCloser, err := storage.Open(cfg.StorageURL())
+	if err != nil {
+		return err
+	}
+	defer kvCloser.Close()
+
+	if _, ok := kv.(*storage.FileStore); !ok {
+		return fmt.Errorf("audit events are only kept in a file storage, they are written to %s otherwise", auditLogPath)
+	}
+
+	if tenantID != "" {
+		tenants, err := tenant.Load(cfg.Path(cfg.TenantsDir))
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(tenants, func(t tenant.Tenant) bool { return t.ID == tenantID }) {
+			return fmt.Errorf("unknown tenant %q", tenantID)
+		}
+		kv = tenantStore(kv, tenantID)
+	}
+
+	enc := json.NewEncoder(w)
+	return audit.ReadEvents(context.Background(), kv, func(event audit.Event) error {
+		return enc.Encode(event)
+	})
+}
+
+type tokenHookConfig struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+	// Timeout and Cooldown are durations such as "2s".
+	Timeout          string `json:"timeout"`
+	FailureThreshold int    `json:"failure_threshold"`
+	Cooldown         string `json:"cooldown"`
+}
+
+func loadTokenHook(path string) (tokenhook.Hook, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg tokenHookConfig
+	if err := json.Unmarshal(fileBytes, &cfg); err != nil {
+		return nil, fmt.Errorf("could not parse token hook config %s: %w", path, err)
+	}
+
+	if cfg.URL == "" || cfg.Secret == "" {
+		return nil, fmt.Errorf("token hook config %s: url and secret are required", path)
+	}
+
+	// Calls are traced as part of the token issuance.
+	opts := []tokenhook.Option{tokenhook.WithHTTPClient(&http.Client{Transport: tracing.NewTransport(nil)})}
+	if cfg.Timeout != "" {
+		timeout, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("token hook config %s: %w", path, err)
+		}
+		opts = append(opts, tokenhook.WithTimeout(timeout))
+	}
+
+	if cfg.FailureThreshold > 0 || cfg.Cooldown != "" {
+		cooldown := tokenhook.DefaultCooldown
+		if cfg.Cooldown != "" {
+			if cooldown, err = time.ParseDuration(cfg.Cooldown); err != nil {
+				return nil, fmt.Errorf("token hook config %s: %w", path, err)
+			}
+		}
+
+		threshold := cfg.FailureThreshold
+		if threshold == 0 {
+			threshold = tokenhook.DefaultFailureThreshold
+		}
+		opts = append(opts, tokenhook.WithCircuitBreaker(threshold, cooldown))
+	}
+
+	return tokenhook.NewHTTPHook(cfg.URL, []byte(cfg.Secret), opts...), nil
+}
+
+func newTracer(cfg config.Config) (*tracing.Tracer, []io.Closer, error) {
+	level, err := tracing.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := []tracing.Option{
+		tracing.WithLevel(level),
+		tracing.WithFormat(tracing.Format(cfg.Log.Format)),
+		tracing.WithServiceName(cfg.Tracing.ServiceName),
+		tracing.WithSampleRatio(cfg.Tracing.SampleRatio),
+	}
+
+	var files []io.Closer
+	closeFiles := func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}
+
+	if path := cfg.LogFile(); path != "" {
+		f, err := tracing.OpenRotatingFile(path, int64(cfg.Log.MaxSizeMB)<<20, cfg.Log.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+		opts = append(opts, tracing.WithOutput(f))
+	}
+
+	if cfg.Tracing.OTLPEndpoint != "" {
+		headers, err := cfg.OTLPHeaders()
+		if err != nil {
+			closeFiles()
+			return nil, nil, err
+		}
+
+		exporter, err := tracing.NewOTLPExporter(cfg.Tracing.OTLPEndpoint, tracing.WithOTLPHeaders(headers))
+		if err != nil {
+			closeFiles()
+			return nil, nil, err
+		}
+		opts = append(opts, tracing.WithExporter(exporter))
+	}
+
+	switch path := cfg.TracingFile(); path {
+	case "":
+	case "stdout":
+		opts = append(opts, tracing.WithExporter(tracing.NewWriterExporter(os.Stdout)))
+	default:
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			closeFiles()
+			return nil, nil, err
+		}
+		files = append(files, f)
+		opts = append(opts, tracing.WithExporter(tracing.NewWriterExporter(f)))
+	}
+
+	return tracing.New(opts...), files, nil
+}
+
+func newHTTPHandler(rootCtx context.Context, reg *metrics.Registry, serveMetrics bool, hdl handler.Handler, tenants map[string]handler.Handler, rateLimit func(scope string) gin.HandlerFunc) http.Handler {
 	router := httpserver.NewRouter(rootCtx)
+	router.Use(httpserver.RequestMetrics(reg))
+	if serveMetrics {
+		router.GET(pathMetrics, gin.WrapH(reg.Handler()))
+	}
 
-	// Register handlers
-	router.POST("/token", hdl.GenerateToken())
-	router.GET("/.well-known/jwks.json", hdl.GetJWKs())
+	registerRoutes(router, hdl, rateLimit(""))
+	for id, tenantHdl := range tenants {
+		registerRoutes(router.Group(tenant.PathPrefix(id)), tenantHdl, rateLimit(id))
+	}
 
 	return router
 }
+
+func registerRoutes(router gin.IRoutes, hdl handler.Handler, rateLimit gin.HandlerFunc) {
+	router.POST(handler.PathToken, rateLimit, hdl.GenerateToken())
+	router.POST(handler.PathRevoke, rateLimit, hdl.Revoke())
+	router.GET(handler.PathJWKS, hdl.GetJWKs())
+	router.GET(handler.PathOpenIDConfiguration, hdl.Discovery())
+	router.GET(handler.PathOAuthAuthorizationServer, hdl.Discovery())
+	router.GET(handler.PathAuthorize, hdl.Authorize())
+	router.POST(handler.PathAuthorizeDecision, rateLimit, hdl.AuthorizeDecision())
+	router.POST(handler.PathLogin, hdl.Login())
+	router.POST(handler.PathConsent, hdl.Consent())
+	router.GET(handler.PathUserInfo, hdl.UserInfo())
+	router.POST(handler.PathUserInfo, hdl.UserInfo())
+	router.POST(handler.PathPushedAuthorizationRequest, rateLimit, hdl.PushedAuthorizationRequest())
+	router.POST(handler.PathDeviceAuthorization, rateLimit, hdl.DeviceAuthorization())
+	router.GET(handler.PathDevice, rateLimit, hdl.DeviceVerification())
+	router.POST(handler.PathDevice, rateLimit, hdl.DeviceDecision())
+}
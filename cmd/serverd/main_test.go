@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/handler"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/metrics"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/tenant"
+)
+
+func writeIssuerFiles(t *testing.T, dir, clients string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, privateKeyPath), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if clients != "" {
+		if err := os.WriteFile(filepath.Join(dir, clientsPath), []byte(clients), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newTestHTTPHandler serves the issuer of dir and its tenants, as run does.
+func newTestHTTPHandler(t *testing.T, dir, issuer string) http.Handler {
+	t.Helper()
+
+	kv := storage.NewMemoryStore()
+	load := func(dir string, kv storage.Store, issuer string) handler.Handler {
+		hdl, auditLog, err := loadHandler(dir, filepath.Join(dir, privateKeyPath), kv, false, nil, handler.WithIssuer(issuer))
+		if err != nil {
+			t.Fatalf("loadHandler(%s) = %v", dir, err)
+		}
+		t.Cleanup(func() { _ = auditLog.Close() })
+
+		return hdl
+	}
+
+	tenants, err := tenant.Load(filepath.Join(dir, "tenants"))
+	if err != nil {
+		t.Fatalf("tenant.Load() = %v", err)
+	}
+	tenantHandlers := map[string]handler.Handler{}
+	for _, tn := range tenants {
+		tenantHandlers[tn.ID] = load(tn.Dir, tenantStore(kv, tn.ID), tn.Issuer(issuer))
+	}
+
+	limits := httpserver.NewRateLimitStore(kv)
+	rateLimit := func(scope string) gin.HandlerFunc {
+		return httpserver.RateLimit(limits, httpserver.RateLimitConfig{Scope: scope})
+	}
+
+	return newHTTPHandler(context.Background(), metrics.NewRegistry(), false, load(dir, kv, issuer), tenantHandlers, rateLimit)
+}
+
+func TestTenantIsolation(t *testing.T) {
+	dir := t.TempDir()
+	clients := func(secret string) string {
+		return `[{"client_id": "svc", "client_secret": "` + secret + `", "grant_types": ["client_credentials"]}]`
+	}
+	writeIssuerFiles(t, dir, clients("root-secret"))
+	writeIssuerFiles(t, filepath.Join(dir, "tenants", "acme"), clients("acme-secret"))
+	writeIssuerFiles(t, filepath.Join(dir, "tenants", "globex"), clients("globex-secret"))
+	if err := os.WriteFile(filepath.Join(dir, "tenants", "globex", tenant.ConfigFile), []byte(`{"issuer": "https://globex.example.com/"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newTestHTTPHandler(t, dir, "http://localhost:8080/")
+
+	serve := func(method, path string, form url.Values, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if secret != "" {
+			req.SetBasicAuth("svc", secret)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	issuers := []struct {
+		prefix     string
+		secret     string
+		wantIssuer string
+	}{
+		{prefix: "", secret: "root-secret", wantIssuer: "http://localhost:8080/"},
+		{prefix: tenant.PathPrefix("acme"), secret: "acme-secret", wantIssuer: "http://localhost:8080/t/acme/"},
+		{prefix: tenant.PathPrefix("globex"), secret: "globex-secret", wantIssuer: "https://globex.example.com/"},
+	}
+
+	keys := make([]jose.JWKS, len(issuers))
+	tokens := make([]jose.Token, len(issuers))
+	for i, iss := range issuers {
+		rec := serve(http.MethodGet, iss.prefix+handler.PathJWKS, nil, "")
+		if err := json.Unmarshal(rec.Body.Bytes(), &keys[i]); err != nil || len(keys[i].Keys) == 0 {
+			t.Fatalf("%s: JWKS = %s, %v", iss.wantIssuer, rec.Body, err)
+		}
+
+		rec = serve(http.MethodPost, iss.prefix+handler.PathToken, url.Values{"grant_type": {"client_credentials"}}, iss.secret)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: token status = %d: %s", iss.wantIssuer, rec.Code, rec.Body)
+		}
+		var resp struct {
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		token, err := jose.Parse(resp.AccessToken)
+		if err != nil {
+			t.Fatalf("%s: Parse() = %v", iss.wantIssuer, err)
+		}
+		tokens[i] = token
+
+		var claims struct {
+			Issuer string `json:"iss"`
+		}
+		if err := token.Claims(&claims); err != nil || claims.Issuer != iss.wantIssuer {
+			t.Errorf("iss = %q, %v, want %q", claims.Issuer, err, iss.wantIssuer)
+		}
+	}
+
+	// Each issuer signs with its own key: its tokens only verify against its own JWKS.
+	for i, iss := range issuers {
+		for j := range issuers {
+			err := keys[j].Verify(tokens[i])
+			if i == j && err != nil {
+				t.Errorf("token of %s does not verify against its JWKS: %v", iss.wantIssuer, err)
+			}
+			if i != j && err == nil {
+				t.Errorf("token of %s verifies against the JWKS of %s", iss.wantIssuer, issuers[j].wantIssuer)
+			}
+		}
+	}
+
+	// Clients are registered with one issuer: the credentials of another are rejected.
+	for _, tt := range []struct{ prefix, secret string }{
+		{tenant.PathPrefix("acme"), "root-secret"},
+		{tenant.PathPrefix("acme"), "globex-secret"},
+		{"", "acme-secret"},
+	} {
+		rec := serve(http.MethodPost, tt.prefix+handler.PathToken, url.Values{"grant_type": {"client_credentials"}}, tt.secret)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("token request at %q with %s: status = %d, want %d", tt.prefix, tt.secret, rec.Code, http.StatusUnauthorized)
+		}
+	}
+}
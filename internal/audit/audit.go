@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	TypeLogin         = "login"
+	TypePasswordGrant = "password_grant"
+
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Outcome    string    `json:"outcome"`
+	UserID     string    `json:"user_id,omitempty"`
+	Username   string    `json:"username,omitempty"`
+	ClientID   string    `json:"client_id,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+type Recorder interface {
+	Record(ctx context.Context, event Event) error
+}
+
+type writerRecorder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterRecorder(w io.Writer) Recorder {
+	return &writerRecorder{
+		w: w,
+	}
+}
+
+func (r *writerRecorder) Record(_ context.Context, event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err = r.w.Write(append(b, '\n'))
+	return err
+}
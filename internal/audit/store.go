@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+const (
+	storeEventPrefix = "audit:"
+	// storeCountKey counts the recorded events, numbering them from 1.
+	storeCountKey = "audit_events"
+)
+
+type storeRecorder struct {
+	store storage.Store
+}
+
+func NewStoreRecorder(store storage.Store) Recorder {
+	return storeRecorder{
+		store: store,
+	}
+}
+
+func (r storeRecorder) Record(ctx context.Context, event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	n, err := r.store.Incr(ctx, storeCountKey, 0)
+	if err != nil {
+		return err
+	}
+
+	return r.store.Set(ctx, storeEventPrefix+strconv.FormatInt(n, 10), b, 0)
+}
+
+// ReadEvents calls fn in the order the events were recorded.
+func ReadEvents(ctx context.Context, store storage.Store, fn func(Event) error) error {
+	b, err := store.Get(ctx, storeCountKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	count, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return err
+	}
+
+	for n := int64(1); n <= count; n++ {
+		b, err := store.Get(ctx, storeEventPrefix+strconv.FormatInt(n, 10))
+		if errors.Is(err, storage.ErrNotFound) {
+			// The server stopped between numbering the event and storing it.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var event Event
+		if err := json.Unmarshal(b, &event); err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
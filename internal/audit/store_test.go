@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+func TestStoreRecorder(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	kv, err := storage.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() = %v", err)
+	}
+
+	r := NewStoreRecorder(kv)
+	for _, event := range []Event{
+		{Type: TypeLogin, Outcome: OutcomeFailure, Username: "alice", Reason: "invalid credentials"},
+		{Type: TypeLogin, Outcome: OutcomeSuccess, Username: "alice"},
+		{Type: TypePasswordGrant, Outcome: OutcomeSuccess, Username: "bob", ClientID: "legacy-tool"},
+	} {
+		if err := r.Record(ctx, event); err != nil {
+			t.Fatalf("Record() = %v", err)
+		}
+	}
+
+	// The events persist across restarts.
+	if err := kv.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if kv, err = storage.OpenFileStore(path); err != nil {
+		t.Fatalf("OpenFileStore() = %v", err)
+	}
+	defer kv.Close()
+
+	var events []Event
+	err = ReadEvents(ctx, kv, func(event Event) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadEvents() = %v", err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("read %d events, want 3", len(events))
+	}
+	if events[0].Outcome != OutcomeFailure || events[1].Outcome != OutcomeSuccess || events[2].Username != "bob" {
+		t.Errorf("events are not in the order they were recorded: %+v", events)
+	}
+	for _, event := range events {
+		if event.Time.IsZero() {
+			t.Errorf("event without time: %+v", event)
+		}
+	}
+
+	// ReadEvents stops at the first error of fn.
+	stop := errors.New("stop")
+	calls := 0
+	err = ReadEvents(ctx, kv, func(Event) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("ReadEvents() = %v after %d calls, want %v after 1", err, calls, stop)
+	}
+}
+
+func TestReadEventsEmpty(t *testing.T) {
+	err := ReadEvents(context.Background(), storage.NewMemoryStore(), func(Event) error {
+		t.Error("ReadEvents() called fn without events")
+		return nil
+	})
+	if err != nil {
+		t.Errorf("ReadEvents() = %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package authcode
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+const (
+	ChallengeMethodPlain = "plain"
+	ChallengeMethodS256  = "S256"
+)
+
+var (
+	ErrNotFound = errors.New("authorization code not found")
+)
+
+type Code struct {
+	Code                string
+	ClientID            string
+	UserID              string
+	RedirectURI         string
+	Scopes              []string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+	AuthTime            time.Time
+	ACR                 string
+	AMR                 []string
+	ExpiresAt           time.Time
+}
+
+func (c Code) VerifyChallenge(verifier string) bool {
+	if c.CodeChallenge == "" {
+		return verifier == ""
+	}
+
+	computed := verifier
+	if c.CodeChallengeMethod == ChallengeMethodS256 {
+		sum := sha256.Sum256([]byte(verifier))
+		computed = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(c.CodeChallenge)) == 1
+}
+
+type Store interface {
+	Save(ctx context.Context, code Code) error
+
+	// Consume removes the code, so a code can be redeemed once.
+	Consume(ctx context.Context, code string) (Code, error)
+}
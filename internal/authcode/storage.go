@@ -0,0 +1,57 @@
+package authcode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+const (
+	keyPrefix = "authcode:"
+)
+
+type kvStore struct {
+	kv storage.Store
+}
+
+func NewStore(kv storage.Store) Store {
+	return kvStore{kv: kv}
+}
+
+func (s kvStore) Save(ctx context.Context, code Code) error {
+	ttl := time.Until(code.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+
+	return s.kv.Set(ctx, keyPrefix+code.Code, b, ttl)
+}
+
+func (s kvStore) Consume(ctx context.Context, code string) (Code, error) {
+	b, err := s.kv.Take(ctx, keyPrefix+code)
+	if errors.Is(err, storage.ErrNotFound) {
+		return Code{}, ErrNotFound
+	}
+	if err != nil {
+		return Code{}, err
+	}
+
+	var c Code
+	if err := json.Unmarshal(b, &c); err != nil {
+		return Code{}, err
+	}
+
+	if time.Now().After(c.ExpiresAt) {
+		return Code{}, ErrNotFound
+	}
+
+	return c, nil
+}
@@ -0,0 +1,113 @@
+// Package claimmap applies the claim mappings of clients and resources to access tokens.
+package claimmap
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/policy"
+)
+
+const (
+	sourceClaims = "claims."
+)
+
+// Reserved claims carry what resource servers rely on to accept a token, so only the server sets them.
+var Reserved = []string{
+	"iss", "sub", "aud", "exp", "nbf", "iat", "jti", "azp", "client_id", "scope", "cnf", "gty", "act", "may_act",
+	"auth_time", "acr", "amr", "nonce", "sid", "roles", "permissions", "groups", "entitlements",
+}
+
+type Mapping struct {
+	// Namespace, such as https://example.com/, keeps custom claims apart from registered ones.
+	Namespace string `json:"namespace,omitempty"`
+	Rules     []Rule `json:"rules,omitempty"`
+}
+
+type Rule struct {
+	Claim string      `json:"claim"`
+	Value interface{} `json:"value,omitempty"`
+	// From is user.<name>, client.<name> or claims.<name>. Nothing is set when the attribute is missing.
+	From string `json:"from,omitempty"`
+	// Rename removes the claim From copies.
+	Rename bool               `json:"rename,omitempty"`
+	When   []policy.Condition `json:"when,omitempty"`
+}
+
+func (m Mapping) Validate() error {
+	for i, r := range m.Rules {
+		if r.Claim == "" {
+			return fmt.Errorf("claim mapping rule %d has no claim", i)
+		}
+
+		name := m.Namespace + r.Claim
+		if slices.Contains(Reserved, name) {
+			return fmt.Errorf("claim mapping rule %d sets reserved claim %q", i, name)
+		}
+
+		if (r.Value == nil) == (r.From == "") {
+			return fmt.Errorf("claim mapping rule %d for %q needs either a value or a source", i, name)
+		}
+
+		if r.Rename {
+			source, ok := strings.CutPrefix(r.From, sourceClaims)
+			if !ok {
+				return fmt.Errorf("claim mapping rule %d for %q can only rename a claim", i, name)
+			}
+			if slices.Contains(Reserved, source) {
+				return fmt.Errorf("claim mapping rule %d renames reserved claim %q", i, source)
+			}
+		}
+
+		for _, c := range r.When {
+			if err := c.Validate(); err != nil {
+				return fmt.Errorf("claim mapping rule %d for %q: %w", i, name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Apply never changes reserved claims, even when the mapping was not validated.
+func (m Mapping) Apply(claims map[string]interface{}, attrs map[string]interface{}) {
+	for _, r := range m.Rules {
+		name := m.Namespace + r.Claim
+		if r.Claim == "" || slices.Contains(Reserved, name) {
+			continue
+		}
+
+		env := map[string]interface{}{"claims": claims}
+		for k, v := range attrs {
+			env[k] = v
+		}
+
+		if !conditionsHold(r.When, env) {
+			continue
+		}
+
+		value := r.Value
+		if r.From != "" {
+			if value = policy.Lookup(env, r.From); value == nil {
+				continue
+			}
+		}
+
+		if source, ok := strings.CutPrefix(r.From, sourceClaims); ok && r.Rename && !slices.Contains(Reserved, source) {
+			delete(claims, source)
+		}
+
+		claims[name] = value
+	}
+}
+
+func conditionsHold(conditions []policy.Condition, env map[string]interface{}) bool {
+	for _, c := range conditions {
+		if !c.Holds(env) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,166 @@
+package claimmap
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeMapping(t *testing.T, s string) Mapping {
+	t.Helper()
+
+	var m Mapping
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatalf("could not decode mapping: %v", err)
+	}
+
+	return m
+}
+
+func TestMappingValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			mapping: `{"namespace": "https://example.com/", "rules": [
+				{"claim": "tier", "value": "gold", "when": [{"attribute": "client.metadata.plan", "operator": "equals", "value": "gold"}]},
+				{"claim": "department", "from": "user.metadata.department"},
+				{"claim": "tenant", "from": "claims.tid", "rename": true}
+			]}`,
+		},
+		{name: "no claim", mapping: `{"rules": [{"value": "gold"}]}`, wantErr: "has no claim"},
+		{name: "reserved claim", mapping: `{"rules": [{"claim": "sub", "value": "admin"}]}`, wantErr: `reserved claim "sub"`},
+		{
+			name:    "reserved claim through the namespace",
+			mapping: `{"namespace": "s", "rules": [{"claim": "cope", "value": "admin"}]}`,
+			wantErr: `reserved claim "scope"`,
+		},
+		{name: "value and source", mapping: `{"rules": [{"claim": "tier", "value": "gold", "from": "user.tier"}]}`, wantErr: "either a value or a source"},
+		{name: "neither value nor source", mapping: `{"rules": [{"claim": "tier"}]}`, wantErr: "either a value or a source"},
+		{name: "renaming an attribute", mapping: `{"rules": [{"claim": "tier", "from": "user.tier", "rename": true}]}`, wantErr: "can only rename a claim"},
+		{name: "renaming a reserved claim", mapping: `{"rules": [{"claim": "subject", "from": "claims.sub", "rename": true}]}`, wantErr: `renames reserved claim "sub"`},
+		{
+			name:    "invalid condition",
+			mapping: `{"rules": [{"claim": "tier", "value": "gold", "when": [{"attribute": "user.plan", "operator": "like"}]}]}`,
+			wantErr: `unknown operator "like"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decodeMapping(t, tt.mapping).Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMappingApply(t *testing.T) {
+	attrs := map[string]interface{}{
+		"user": map[string]interface{}{
+			"id":       "u1",
+			"metadata": map[string]interface{}{"department": "sales", "level": 3},
+		},
+		"client": map[string]interface{}{
+			"id":       "portal",
+			"metadata": map[string]interface{}{"plan": "gold"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		mapping string
+		claims  map[string]interface{}
+		want    map[string]interface{}
+	}{
+		{
+			name:    "static value",
+			mapping: `{"namespace": "https://example.com/", "rules": [{"claim": "tier", "value": "gold"}]}`,
+			claims:  map[string]interface{}{"sub": "u1"},
+			want:    map[string]interface{}{"sub": "u1", "https://example.com/tier": "gold"},
+		},
+		{
+			name:    "copied attribute",
+			mapping: `{"rules": [{"claim": "department", "from": "user.metadata.department"}, {"claim": "level", "from": "user.metadata.level"}]}`,
+			claims:  map[string]interface{}{"sub": "u1"},
+			want:    map[string]interface{}{"sub": "u1", "department": "sales", "level": 3},
+		},
+		{
+			name:    "missing attribute",
+			mapping: `{"rules": [{"claim": "manager", "from": "user.metadata.manager"}]}`,
+			claims:  map[string]interface{}{"sub": "u1"},
+			want:    map[string]interface{}{"sub": "u1"},
+		},
+		{
+			name:    "copied claim",
+			mapping: `{"rules": [{"claim": "tenant", "from": "claims.tid"}]}`,
+			claims:  map[string]interface{}{"sub": "u1", "tid": "t1"},
+			want:    map[string]interface{}{"sub": "u1", "tid": "t1", "tenant": "t1"},
+		},
+		{
+			name:    "renamed claim",
+			mapping: `{"rules": [{"claim": "tenant", "from": "claims.tid", "rename": true}]}`,
+			claims:  map[string]interface{}{"sub": "u1", "tid": "t1"},
+			want:    map[string]interface{}{"sub": "u1", "tenant": "t1"},
+		},
+		{
+			name: "rules see the claims set by earlier rules",
+			mapping: `{"rules": [
+				{"claim": "department", "from": "user.metadata.department"},
+				{"claim": "sales", "value": true, "when": [{"attribute": "claims.department", "operator": "equals", "value": "sales"}]}
+			]}`,
+			claims: map[string]interface{}{"sub": "u1"},
+			want:   map[string]interface{}{"sub": "u1", "department": "sales", "sales": true},
+		},
+		{
+			name: "conditions",
+			mapping: `{"rules": [
+				{"claim": "gold", "value": true, "when": [
+					{"attribute": "client.metadata.plan", "operator": "equals", "value": "gold"},
+					{"attribute": "user.metadata.level", "operator": "in", "value": [2, 3]}
+				]},
+				{"claim": "silver", "value": true, "when": [{"attribute": "client.metadata.plan", "operator": "equals", "value": "silver"}]},
+				{"claim": "senior", "value": true, "when": [
+					{"attribute": "client.metadata.plan", "operator": "equals", "value": "gold"},
+					{"attribute": "user.metadata.level", "operator": "equals", "value": 5}
+				]}
+			]}`,
+			claims: map[string]interface{}{"sub": "u1"},
+			want:   map[string]interface{}{"sub": "u1", "gold": true},
+		},
+		{
+			// Apply holds even when Validate was not called.
+			name: "reserved claims",
+			mapping: `{"namespace": "s", "rules": [
+				{"claim": "ub", "value": "admin"},
+				{"claim": "cope", "from": "claims.aud", "rename": true},
+				{"claim": "audience", "from": "claims.aud", "rename": true}
+			]}`,
+			claims: map[string]interface{}{"sub": "u1", "aud": "api"},
+			want:   map[string]interface{}{"sub": "u1", "aud": "api", "saudience": "api"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := decodeMapping(t, tt.mapping)
+			m.Apply(tt.claims, attrs)
+
+			if !reflect.DeepEqual(tt.claims, tt.want) {
+				t.Errorf("Apply() = %v, want %v", tt.claims, tt.want)
+			}
+		})
+	}
+}
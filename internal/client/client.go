@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/claimmap"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+)
+
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+	GrantTypeJWTBearer         = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	GrantTypePassword          = "password"
+	GrantTypeRefreshToken      = "refresh_token"
+
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodPrivateKeyJWT     = "private_key_jwt"
+	AuthMethodTLSClientAuth     = "tls_client_auth"
+	AuthMethodSelfSignedTLS     = "self_signed_tls_client_auth"
+	AuthMethodNone              = "none"
+
+	// TokenHookFailClosed refuses to issue tokens while the token hook is unavailable, TokenHookFailOpen
+	// issues them without the hook's claims.
+	TokenHookFailClosed = "closed"
+	TokenHookFailOpen   = "open"
+)
+
+var (
+	ErrNotFound = errors.New("client not found")
+)
+
+type Client struct {
+	ID           string   `json:"client_id"`
+	Secret       string   `json:"client_secret,omitempty"`
+	Name         string   `json:"client_name,omitempty"`
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
+	GrantTypes   []string `json:"grant_types,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	// TokenEndpointAuthMethod is derived from the registered credentials when empty.
+	TokenEndpointAuthMethod string     `json:"token_endpoint_auth_method,omitempty"`
+	JWKS                    *jose.JWKS `json:"jwks,omitempty"`
+	JWKSURI                 string     `json:"jwks_uri,omitempty"`
+	// Roles names the roles assigned to the client, granting permissions to its own tokens.
+	Roles                              []string            `json:"roles,omitempty"`
+	TLSClientAuth                      TLSClientAuth       `json:"tls_client_auth,omitempty"`
+	CertificateBoundAccessTokens       bool                `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+	DPoPBoundAccessTokens              bool                `json:"dpop_bound_access_tokens,omitempty"`
+	RequirePushedAuthorizationRequests bool                `json:"require_pushed_authorization_requests,omitempty"`
+	AssertionSubjects                  []string            `json:"assertion_subjects,omitempty"`
+	TokenExchange                      []TokenExchangeRule `json:"token_exchange,omitempty"`
+	ClaimMapping                       claimmap.Mapping    `json:"claim_mapping,omitempty"`
+	// TokenHookFailurePolicy is TokenHookFailOpen or TokenHookFailClosed, the default.
+	TokenHookFailurePolicy string `json:"token_hook_failure_policy,omitempty"`
+	// Metadata holds custom attributes claim mappings can copy into tokens.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// TLSClientAuth expects exactly one of its fields to be set, as described by RFC 8705 section 2.1.2.
+type TLSClientAuth struct {
+	SubjectDN string `json:"subject_dn,omitempty"`
+	SANDNS    string `json:"san_dns,omitempty"`
+	SANURI    string `json:"san_uri,omitempty"`
+	SANIP     string `json:"san_ip,omitempty"`
+	SANEmail  string `json:"san_email,omitempty"`
+}
+
+func (a TLSClientAuth) Matches(cert *x509.Certificate) bool {
+	switch {
+	case a.SubjectDN != "":
+		return cert.Subject.String() == a.SubjectDN
+	case a.SANDNS != "":
+		return slices.Contains(cert.DNSNames, a.SANDNS)
+	case a.SANURI != "":
+		return slices.ContainsFunc(cert.URIs, func(u *url.URL) bool { return u.String() == a.SANURI })
+	case a.SANIP != "":
+		ip := net.ParseIP(a.SANIP)
+		return ip != nil && slices.ContainsFunc(cert.IPAddresses, ip.Equal)
+	case a.SANEmail != "":
+		return slices.Contains(cert.EmailAddresses, a.SANEmail)
+	default:
+		return false
+	}
+}
+
+type TokenExchangeRule struct {
+	SubjectAudience string   `json:"subject_audience"`
+	Audiences       []string `json:"audiences"`
+	// Impersonation leaves the act claim out when no actor token is sent, which otherwise names the client.
+	Impersonation bool `json:"impersonation,omitempty"`
+}
+
+func (c Client) Public() bool {
+	return c.AllowsAuthMethod(AuthMethodNone)
+}
+
+func (c Client) HasKeys() bool {
+	return c.JWKS != nil || c.JWKSURI != ""
+}
+
+func (c Client) AllowsAuthMethod(method string) bool {
+	if c.TokenEndpointAuthMethod != "" {
+		return method == c.TokenEndpointAuthMethod
+	}
+
+	switch {
+	case c.Secret != "":
+		return method == AuthMethodClientSecretBasic || method == AuthMethodClientSecretPost
+	case c.HasKeys():
+		return method == AuthMethodPrivateKeyJWT
+	default:
+		return method == AuthMethodNone
+	}
+}
+
+func (c Client) VerifySecret(secret string) bool {
+	if c.Secret == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(c.Secret), []byte(secret)) == 1
+}
+
+// Clients without explicit grant types may only use client credentials.
+func (c Client) AllowsGrantType(grantType string) bool {
+	if len(c.GrantTypes) == 0 {
+		return grantType == GrantTypeClientCredentials
+	}
+
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+func (c Client) AllowsRedirectURI(redirectURI string) bool {
+	return slices.Contains(c.RedirectURIs, redirectURI)
+}
+
+func (c Client) AllowsScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c Client) TokenExchangeRule(subjectAudiences []string, audience string) (TokenExchangeRule, bool) {
+	for _, rule := range c.TokenExchange {
+		if slices.Contains(subjectAudiences, rule.SubjectAudience) && slices.Contains(rule.Audiences, audience) {
+			return rule, true
+		}
+	}
+
+	return TokenExchangeRule{}, false
+}
+
+type Registry interface {
+	Get(ctx context.Context, id string) (Client, error)
+}
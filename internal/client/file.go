@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+type fileRegistry struct {
+	path string
+
+	mu      sync.RWMutex
+	modTime time.Time
+	clients map[string]Client
+}
+
+// NewFileRegistry reloads the file whenever its modification time changes; a missing file holds no clients.
+func NewFileRegistry(path string) (Registry, error) {
+	r := &fileRegistry{path: path}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *fileRegistry) Get(_ context.Context, id string) (Client, error) {
+	if err := r.reload(); err != nil {
+		return Client{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.clients[id]
+	if !ok {
+		return Client{}, ErrNotFound
+	}
+
+	return c, nil
+}
+
+func (r *fileRegistry) reload() error {
+	info, err := os.Stat(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.mu.Lock()
+		r.modTime, r.clients = time.Time{}, nil
+		r.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	upToDate := info.ModTime().Equal(r.modTime)
+	r.mu.RUnlock()
+	if upToDate {
+		return nil
+	}
+
+	clients, err := readClients(r.path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.modTime, r.clients = info.ModTime(), clients
+	r.mu.Unlock()
+
+	return nil
+}
+
+func readClients(path string) (map[string]Client, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Client
+	if err := json.Unmarshal(fileBytes, &list); err != nil {
+		return nil, fmt.Errorf("could not parse clients file %s: %w", path, err)
+	}
+
+	clients := make(map[string]Client, len(list))
+	for _, c := range list {
+		if c.ID == "" {
+			return nil, fmt.Errorf("clients file %s: client without client_id", path)
+		}
+
+		switch c.TokenHookFailurePolicy {
+		case "", TokenHookFailClosed, TokenHookFailOpen:
+		default:
+			return nil, fmt.Errorf("clients file %s: client %s: unknown token hook failure policy %q", path, c.ID, c.TokenHookFailurePolicy)
+		}
+
+		if err := c.ClaimMapping.Validate(); err != nil {
+			return nil, fmt.Errorf("clients file %s: client %s: %w", path, c.ID, err)
+		}
+
+		clients[c.ID] = c
+	}
+
+	return clients, nil
+}
@@ -0,0 +1,30 @@
+package client
+
+import (
+	"context"
+)
+
+type memoryRegistry struct {
+	clients map[string]Client
+}
+
+func NewMemoryRegistry(clients ...Client) Registry {
+	m := memoryRegistry{
+		clients: make(map[string]Client, len(clients)),
+	}
+
+	for _, c := range clients {
+		m.clients[c.ID] = c
+	}
+
+	return m
+}
+
+func (m memoryRegistry) Get(_ context.Context, id string) (Client, error) {
+	c, ok := m.clients[id]
+	if !ok {
+		return Client{}, ErrNotFound
+	}
+
+	return c, nil
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+const (
+	storeClientPrefix = "client:"
+	// storeIndexKey holds the IDs of the stored clients, which the store cannot list.
+	storeIndexKey = "clients"
+)
+
+type storeRegistry struct {
+	store    storage.Store
+	path     string
+	fallback Registry
+
+	mu      sync.RWMutex
+	modTime time.Time
+}
+
+// NewStoreRegistry imports the clients file whenever its modification time changes, replacing the stored
+// clients, which are kept when the file is removed. The fallback clients are served until a file is imported.
+func NewStoreRegistry(ctx context.Context, store storage.Store, path string, fallback ...Client) (Registry, error) {
+	r := &storeRegistry{
+		store:    store,
+		path:     path,
+		fallback: NewMemoryRegistry(fallback...),
+	}
+	if err := r.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *storeRegistry) Get(ctx context.Context, id string) (Client, error) {
+	if err := r.sync(ctx); err != nil {
+		return Client{}, err
+	}
+
+	b, err := r.store.Get(ctx, storeClientPrefix+id)
+	if errors.Is(err, storage.ErrNotFound) {
+		if _, err := r.store.Get(ctx, storeIndexKey); errors.Is(err, storage.ErrNotFound) {
+			return r.fallback.Get(ctx, id)
+		} else if err != nil {
+			return Client{}, err
+		}
+		return Client{}, ErrNotFound
+	}
+	if err != nil {
+		return Client{}, err
+	}
+
+	var c Client
+	if err := json.Unmarshal(b, &c); err != nil {
+		return Client{}, err
+	}
+
+	return c, nil
+}
+
+func (r *storeRegistry) sync(ctx context.Context) error {
+	info, err := os.Stat(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	upToDate := info.ModTime().Equal(r.modTime)
+	r.mu.RUnlock()
+	if upToDate {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if info.ModTime().Equal(r.modTime) {
+		return nil
+	}
+
+	clients, err := readClients(r.path)
+	if err != nil {
+		return err
+	}
+	if err := r.replace(ctx, clients); err != nil {
+		return err
+	}
+
+	r.modTime = info.ModTime()
+	return nil
+}
+
+// replace writes the index last, so an import interrupted by a crash is completed by the next one.
+func (r *storeRegistry) replace(ctx context.Context, clients map[string]Client) error {
+	ids := make([]string, 0, len(clients))
+	for id, c := range clients {
+		b, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		if err := r.store.Set(ctx, storeClientPrefix+id, b, 0); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	var stored []string
+	b, err := r.store.Get(ctx, storeIndexKey)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(b, &stored); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range stored {
+		if _, ok := clients[id]; !ok {
+			if _, err := r.store.Delete(ctx, storeClientPrefix+id); err != nil {
+				return err
+			}
+		}
+	}
+
+	b, err = json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+
+	return r.store.Set(ctx, storeIndexKey, b, 0)
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+func TestStoreRegistry(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clients.json")
+
+	kv, err := storage.OpenFileStore(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatalf("OpenFileStore() = %v", err)
+	}
+
+	// writeClients writes the clients file with a new modification time.
+	modTime := time.Now()
+	writeClients := func(content string) {
+		t.Helper()
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("could not write clients file: %v", err)
+		}
+		modTime = modTime.Add(time.Second)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("could not touch clients file: %v", err)
+		}
+	}
+
+	r, err := NewStoreRegistry(ctx, kv, path, Client{ID: "sample"})
+	if err != nil {
+		t.Fatalf("NewStoreRegistry() = %v", err)
+	}
+	if _, err := r.Get(ctx, "sample"); err != nil {
+		t.Errorf("Get() of a fallback client before an import = %v", err)
+	}
+
+	writeClients(`[{"client_id": "a", "client_secret": "secret-a"}, {"client_id": "b"}]`)
+	if c, err := r.Get(ctx, "a"); err != nil || c.Secret != "secret-a" {
+		t.Errorf("Get() of an imported client = %+v, %v", c, err)
+	}
+	if _, err := r.Get(ctx, "sample"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a fallback client after an import = %v, want ErrNotFound", err)
+	}
+
+	writeClients(`[{"client_id": "a", "client_secret": "rotated"}]`)
+	if c, err := r.Get(ctx, "a"); err != nil || c.Secret != "rotated" {
+		t.Errorf("Get() of a changed client = %+v, %v", c, err)
+	}
+	if _, err := r.Get(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a removed client = %v, want ErrNotFound", err)
+	}
+
+	// An invalid file is rejected and the stored clients are kept.
+	writeClients(`[{"client_secret": "no id"}]`)
+	if _, err := r.Get(ctx, "a"); err == nil {
+		t.Error("Get() succeeded with an invalid clients file")
+	}
+
+	// The clients persist in the store without the file.
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("could not remove clients file: %v", err)
+	}
+	if err := kv.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	kv, err = storage.OpenFileStore(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatalf("OpenFileStore() = %v", err)
+	}
+	defer kv.Close()
+
+	r, err = NewStoreRegistry(ctx, kv, path, Client{ID: "sample"})
+	if err != nil {
+		t.Fatalf("NewStoreRegistry() = %v", err)
+	}
+	if c, err := r.Get(ctx, "a"); err != nil || c.Secret != "rotated" {
+		t.Errorf("Get() after a restart = %+v, %v", c, err)
+	}
+	if _, err := r.Get(ctx, "sample"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a fallback client after a restart = %v, want ErrNotFound", err)
+	}
+}
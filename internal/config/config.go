@@ -0,0 +1,434 @@
+// Package config layers the settings of serverd: defaults, then a YAML or JSON file, then SERVERD_*
+// environment variables, then command-line flags.
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/tracing"
+)
+
+type Config struct {
+	Addr string `yaml:"addr" json:"addr"`
+	// Issuer is the iss of the default issuer, under which tenants are served.
+	Issuer string `yaml:"issuer" json:"issuer"`
+	// Audience is the aud of tokens requested without a resource.
+	Audience       string   `yaml:"audience" json:"audience"`
+	AccessTokenTTL Duration `yaml:"access_token_ttl" json:"access_token_ttl"`
+	ReadTimeout    Duration `yaml:"read_timeout" json:"read_timeout"`
+	WriteTimeout   Duration `yaml:"write_timeout" json:"write_timeout"`
+	// DataDir holds the files of the default issuer. Relative paths of the configuration are resolved
+	// against it.
+	DataDir        string    `yaml:"data_dir" json:"data_dir"`
+	PrivateKeyFile string    `yaml:"private_key_file" json:"private_key_file"`
+	TenantsDir     string    `yaml:"tenants_dir" json:"tenants_dir"`
+	TLS            TLS       `yaml:"tls" json:"tls"`
+	DPoP           DPoP      `yaml:"dpop" json:"dpop"`
+	RateLimit      RateLimit `yaml:"rate_limit" json:"rate_limit"`
+	Storage        Storage   `yaml:"storage" json:"storage"`
+	Tracing        Tracing   `yaml:"tracing" json:"tracing"`
+	Metrics        Metrics   `yaml:"metrics" json:"metrics"`
+	Log            Log       `yaml:"log" json:"log"`
+}
+
+type TLS struct {
+	// Without Enabled the certificate and client CA files must not exist, so that a server is never
+	// downgraded to plaintext by mistake.
+	Enabled  bool   `yaml:"enabled" json:"enabled"`
+	CertFile string `yaml:"cert_file" json:"cert_file"`
+	KeyFile  string `yaml:"key_file" json:"key_file"`
+	// ClientCAFile disables tls_client_auth when it does not exist.
+	ClientCAFile string `yaml:"client_ca_file" json:"client_ca_file"`
+	// MinVersion is "1.2" or "1.3".
+	MinVersion string `yaml:"min_version" json:"min_version"`
+	// CipherPolicy is "intermediate" or "modern", which only allows TLS 1.3.
+	CipherPolicy string `yaml:"cipher_policy" json:"cipher_policy"`
+	// RedirectAddr is a plaintext listener redirecting to HTTPS, not started when empty.
+	RedirectAddr string `yaml:"redirect_addr" json:"redirect_addr"`
+}
+
+type DPoP struct {
+	// Nonces are not required when NonceInterval is 0.
+	NonceInterval Duration `yaml:"nonce_interval" json:"nonce_interval"`
+}
+
+// RateLimit rates are in requests per second, a zero rate disables the limit.
+type RateLimit struct {
+	ClientRate  float64 `yaml:"client_rate" json:"client_rate"`
+	ClientBurst int     `yaml:"client_burst" json:"client_burst"`
+	IPRate      float64 `yaml:"ip_rate" json:"ip_rate"`
+	IPBurst     int     `yaml:"ip_burst" json:"ip_burst"`
+	// FailureThreshold invalid_client failures of a client or address block it for FailureBaseDelay,
+	// doubling with each further failure up to FailureMaxDelay.
+	FailureThreshold int      `yaml:"failure_threshold" json:"failure_threshold"`
+	FailureBaseDelay Duration `yaml:"failure_base_delay" json:"failure_base_delay"`
+	FailureMaxDelay  Duration `yaml:"failure_max_delay" json:"failure_max_delay"`
+}
+
+type Storage struct {
+	// URL is a file:path URL for a single server, or the redis:// or rediss:// URL of a Redis server shared
+	// by replicas. The state is kept in process memory when it is empty.
+	URL string `yaml:"url" json:"url"`
+}
+
+// Tracing records no spans when neither an endpoint nor a file is set.
+type Tracing struct {
+	// OTLPEndpoint receives spans over OTLP/HTTP, such as http://collector:4318.
+	OTLPEndpoint string `yaml:"otlp_endpoint" json:"otlp_endpoint"`
+	// OTLPHeaders are comma separated name=value headers sent to the collector.
+	OTLPHeaders string `yaml:"otlp_headers" json:"otlp_headers"`
+	// File is stdout, or a file the spans are appended to as JSON lines.
+	File string `yaml:"file" json:"file"`
+	// SampleRatio only applies to the traces started by serverd.
+	SampleRatio float64 `yaml:"sample_ratio" json:"sample_ratio"`
+	ServiceName string  `yaml:"service_name" json:"service_name"`
+}
+
+type Metrics struct {
+	// Addr is a plaintext admin listener. The metrics are served by the main listener when it is empty.
+	Addr string `yaml:"addr" json:"addr"`
+}
+
+type Log struct {
+	// Level can be changed while running on the admin listener, or by editing the configuration and
+	// sending SIGHUP.
+	Level string `yaml:"level" json:"level"`
+	// Format is json or console.
+	Format string `yaml:"format" json:"format"`
+	// File is stdout when empty.
+	File string `yaml:"file" json:"file"`
+	// MaxSizeMB is 0 to never rotate the file.
+	MaxSizeMB  int `yaml:"max_size_mb" json:"max_size_mb"`
+	MaxBackups int `yaml:"max_backups" json:"max_backups"`
+}
+
+func Default() Config {
+	return Config{
+		Addr:           ":8080",
+		Issuer:         "http://localhost:8080/",
+		Audience:       "http://localhost:9999/",
+		AccessTokenTTL: Duration(24 * time.Hour),
+		ReadTimeout:    Duration(time.Second),
+		WriteTimeout:   Duration(10 * time.Second),
+		DataDir:        ".",
+		PrivateKeyFile: "private-key.pem",
+		TenantsDir:     "tenants",
+		TLS: TLS{
+			CertFile:     "tls-cert.pem",
+			KeyFile:      "tls-key.pem",
+			ClientCAFile: "client-ca.pem",
+			MinVersion:   "1.2",
+			CipherPolicy: httpserver.CipherPolicyIntermediate,
+		},
+		RateLimit: RateLimit{
+			ClientRate:       5,
+			ClientBurst:      20,
+			IPRate:           10,
+			IPBurst:          50,
+			FailureThreshold: 5,
+			FailureBaseDelay: Duration(time.Second),
+			FailureMaxDelay:  Duration(15 * time.Minute),
+		},
+		Tracing: Tracing{
+			SampleRatio: 1,
+			ServiceName: "serverd",
+		},
+		Log: Log{
+			Level:      "info",
+			Format:     string(tracing.FormatJSON),
+			MaxSizeMB:  100,
+			MaxBackups: 5,
+		},
+	}
+}
+
+func (c Config) Path(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(c.DataDir, path)
+}
+
+// StorageURL resolves the path of a file: URL against the data directory.
+func (c Config) StorageURL() string {
+	if path := storage.FilePath(c.Storage.URL); path != "" && !filepath.IsAbs(path) {
+		return "file:" + c.Path(path)
+	}
+
+	return c.Storage.URL
+}
+
+// TracingFile resolves the file against the data directory, except for "stdout" and "".
+func (c Config) TracingFile() string {
+	if c.Tracing.File == "" || c.Tracing.File == "stdout" {
+		return c.Tracing.File
+	}
+
+	return c.Path(c.Tracing.File)
+}
+
+// LogFile returns "" for stdout.
+func (c Config) LogFile() string {
+	if c.Log.File == "" {
+		return ""
+	}
+
+	return c.Path(c.Log.File)
+}
+
+func (c Config) OTLPHeaders() (map[string]string, error) {
+	headers := map[string]string{}
+	for i, header := range strings.Split(c.Tracing.OTLPHeaders, ",") {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(header, "=")
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			// The header may be a credential, it is not repeated.
+			return nil, fmt.Errorf("header %d is not name=value", i+1)
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+
+	return headers, nil
+}
+
+// ClientCAs returns nil when the client CA file does not exist.
+func (c Config) ClientCAs() (*x509.CertPool, error) {
+	if c.TLS.ClientCAFile == "" {
+		return nil, nil
+	}
+
+	fileBytes, err := os.ReadFile(c.Path(c.TLS.ClientCAFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(fileBytes) {
+		return nil, fmt.Errorf("no certificates found in %s", c.TLS.ClientCAFile)
+	}
+
+	return pool, nil
+}
+
+func (c Config) RateLimitConfig() httpserver.RateLimitConfig {
+	return httpserver.RateLimitConfig{
+		PerClient: httpserver.Limit{Rate: c.RateLimit.ClientRate, Burst: c.RateLimit.ClientBurst},
+		PerIP:     httpserver.Limit{Rate: c.RateLimit.IPRate, Burst: c.RateLimit.IPBurst},
+		Failures: httpserver.FailurePolicy{
+			Threshold:  c.RateLimit.FailureThreshold,
+			BaseDelay:  time.Duration(c.RateLimit.FailureBaseDelay),
+			MaxDelay:   time.Duration(c.RateLimit.FailureMaxDelay),
+			ResetAfter: 24 * time.Hour,
+		},
+	}
+}
+
+// Validate reports every problem found, including with the files the configuration requires.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(name, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, args...)))
+	}
+
+	if err := validAddr(c.Addr); err != nil {
+		invalid("addr", "%v", err)
+	}
+
+	if u, err := url.Parse(c.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.RawQuery != "" || u.Fragment != "" {
+		invalid("issuer", "must be an http or https URL without query or fragment, got %q", c.Issuer)
+	} else if u.Scheme == "https" && !c.TLS.Enabled {
+		invalid("issuer", "an https issuer needs tls.enabled")
+	}
+
+	if c.Audience == "" {
+		invalid("audience", "must not be empty")
+	}
+
+	for _, s := range []struct {
+		name  string
+		value Duration
+	}{
+		{"access_token_ttl", c.AccessTokenTTL},
+		{"read_timeout", c.ReadTimeout},
+		{"write_timeout", c.WriteTimeout},
+	} {
+		if s.value <= 0 {
+			invalid(s.name, "must be positive, got %s", s.value)
+		}
+	}
+
+	for _, s := range []struct {
+		name  string
+		rate  float64
+		burst int
+	}{
+		{"rate_limit.client", c.RateLimit.ClientRate, c.RateLimit.ClientBurst},
+		{"rate_limit.ip", c.RateLimit.IPRate, c.RateLimit.IPBurst},
+	} {
+		if s.rate < 0 {
+			invalid(s.name+"_rate", "must not be negative, got %g", s.rate)
+		}
+		if s.rate > 0 && s.burst < 1 {
+			invalid(s.name+"_burst", "must be at least 1, got %d", s.burst)
+		}
+	}
+
+	if c.RateLimit.FailureThreshold < 0 {
+		invalid("rate_limit.failure_threshold", "must not be negative, got %d", c.RateLimit.FailureThreshold)
+	}
+	if c.RateLimit.FailureThreshold > 0 && (c.RateLimit.FailureBaseDelay <= 0 || c.RateLimit.FailureMaxDelay < c.RateLimit.FailureBaseDelay) {
+		invalid("rate_limit.failure_base_delay", "must be positive and at most rate_limit.failure_max_delay")
+	}
+
+	if err := storage.ValidateURL(c.Storage.URL); err != nil {
+		invalid("storage.url", "%v", err)
+	} else if path := storage.FilePath(c.StorageURL()); path != "" {
+		if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+			invalid("storage.url", "the directory of %s does not exist", path)
+		}
+	}
+
+	if c.Tracing.OTLPEndpoint != "" {
+		if u, err := url.Parse(c.Tracing.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("tracing.otlp_endpoint", "must be an http or https URL, got %q", c.Tracing.OTLPEndpoint)
+		}
+	}
+	if _, err := c.OTLPHeaders(); err != nil {
+		invalid("tracing.otlp_headers", "%v", err)
+	}
+	if path := c.TracingFile(); path != "" && path != "stdout" {
+		if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+			invalid("tracing.file", "the directory of %s does not exist", path)
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio", "must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	}
+
+	if c.Metrics.Addr != "" {
+		if err := validAddr(c.Metrics.Addr); err != nil {
+			invalid("metrics.addr", "%v", err)
+		}
+	}
+
+	if _, err := tracing.ParseLevel(c.Log.Level); err != nil {
+		invalid("log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	if f := tracing.Format(c.Log.Format); f != tracing.FormatJSON && f != tracing.FormatConsole {
+		invalid("log.format", "must be json or console, got %q", c.Log.Format)
+	}
+	if path := c.LogFile(); path != "" {
+		if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+			invalid("log.file", "the directory of %s does not exist", path)
+		}
+	}
+	if c.Log.MaxSizeMB < 0 {
+		invalid("log.max_size_mb", "must not be negative, got %d", c.Log.MaxSizeMB)
+	}
+	if c.Log.MaxBackups < 0 {
+		invalid("log.max_backups", "must not be negative, got %d", c.Log.MaxBackups)
+	}
+
+	if c.DPoP.NonceInterval < 0 {
+		invalid("dpop.nonce_interval", "must not be negative, got %s", c.DPoP.NonceInterval)
+	}
+
+	if info, err := os.Stat(c.DataDir); err != nil || !info.IsDir() {
+		invalid("data_dir", "%q is not a directory", c.DataDir)
+	} else {
+		if _, err := os.Stat(c.Path(c.PrivateKeyFile)); err != nil {
+			invalid("private_key_file", "%v", err)
+		}
+
+		if c.TLS.Enabled {
+			if _, err := tls.LoadX509KeyPair(c.Path(c.TLS.CertFile), c.Path(c.TLS.KeyFile)); err != nil {
+				invalid("tls.cert_file", "could not load the certificate and its key: %v", err)
+			}
+			if _, err := c.ClientCAs(); err != nil {
+				invalid("tls.client_ca_file", "%v", err)
+			}
+		} else {
+			for _, s := range []struct {
+				name string
+				path string
+			}{
+				{"tls.cert_file", c.TLS.CertFile},
+				{"tls.client_ca_file", c.TLS.ClientCAFile},
+			} {
+				if _, err := os.Stat(c.Path(s.path)); s.path != "" && err == nil {
+					invalid(s.name, "%s exists but tls.enabled is off, enable TLS or clear the setting", s.path)
+				}
+			}
+		}
+	}
+
+	if c.TLS.RedirectAddr != "" && !c.TLS.Enabled {
+		invalid("tls.redirect_addr", "redirects to HTTPS, which needs tls.enabled")
+	}
+
+	if _, err := httpserver.TLSConfig(c.TLS.MinVersion, c.TLS.CipherPolicy); err != nil {
+		invalid("tls", "%v", err)
+	}
+
+	if c.TLS.RedirectAddr != "" {
+		if err := validAddr(c.TLS.RedirectAddr); err != nil {
+			invalid("tls.redirect_addr", "%v", err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("must be host:port, got %q", addr)
+	}
+
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+
+	return nil
+}
+
+// Duration is written like "30s" in files, variables and flags.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", text)
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDataDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "private-key.pem"), "key")
+
+	return dir
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestKeyPair(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFile(t, certFile, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeTestFile(t, keyFile, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup changes the default configuration of a data directory holding a signing key.
+		setup func(t *testing.T, c *Config)
+		// wantErrs are the settings reported as invalid, none when empty.
+		wantErrs []string
+	}{
+		{name: "defaults", setup: func(*testing.T, *Config) {}},
+		{
+			name: "TLS",
+			setup: func(t *testing.T, c *Config) {
+				c.TLS.Enabled, c.TLS.RedirectAddr, c.Issuer = true, ":8081", "https://localhost:8080/"
+				writeTestKeyPair(t, c.Path(c.TLS.CertFile), c.Path(c.TLS.KeyFile))
+			},
+		},
+		{
+			name: "TLS with client authorities",
+			setup: func(t *testing.T, c *Config) {
+				c.TLS.Enabled = true
+				writeTestKeyPair(t, c.Path(c.TLS.CertFile), c.Path(c.TLS.KeyFile))
+				writeTestKeyPair(t, c.Path(c.TLS.ClientCAFile), c.Path("ca-key.pem"))
+			},
+		},
+		{
+			name:     "TLS without certificate",
+			setup:    func(_ *testing.T, c *Config) { c.TLS.Enabled = true },
+			wantErrs: []string{"tls.cert_file"},
+		},
+		{
+			name: "TLS with the key of another certificate",
+			setup: func(t *testing.T, c *Config) {
+				c.TLS.Enabled = true
+				writeTestKeyPair(t, c.Path(c.TLS.CertFile), c.Path("other-key.pem"))
+				writeTestKeyPair(t, c.Path("other-cert.pem"), c.Path(c.TLS.KeyFile))
+			},
+			wantErrs: []string{"tls.cert_file"},
+		},
+		{
+			name: "TLS with client authorities that are not certificates",
+			setup: func(t *testing.T, c *Config) {
+				c.TLS.Enabled = true
+				writeTestKeyPair(t, c.Path(c.TLS.CertFile), c.Path(c.TLS.KeyFile))
+				writeTestFile(t, c.Path(c.TLS.ClientCAFile), "not a certificate")
+			},
+			wantErrs: []string{"tls.client_ca_file"},
+		},
+		{
+			name:     "certificate without TLS",
+			setup:    func(t *testing.T, c *Config) { writeTestKeyPair(t, c.Path(c.TLS.CertFile), c.Path(c.TLS.KeyFile)) },
+			wantErrs: []string{"tls.cert_file"},
+		},
+		{
+			name:     "client authorities without TLS",
+			setup:    func(t *testing.T, c *Config) { writeTestKeyPair(t, c.Path(c.TLS.ClientCAFile), c.Path("ca-key.pem")) },
+			wantErrs: []string{"tls.client_ca_file"},
+		},
+		{
+			name: "cleared certificate without TLS",
+			setup: func(t *testing.T, c *Config) {
+				writeTestKeyPair(t, c.Path(c.TLS.CertFile), c.Path(c.TLS.KeyFile))
+				c.TLS.CertFile = ""
+			},
+		},
+		{
+			name:     "https issuer without TLS",
+			setup:    func(_ *testing.T, c *Config) { c.Issuer = "https://localhost:8080/" },
+			wantErrs: []string{"issuer"},
+		},
+		{
+			name:     "redirect without TLS",
+			setup:    func(_ *testing.T, c *Config) { c.TLS.RedirectAddr = ":8081" },
+			wantErrs: []string{"tls.redirect_addr"},
+		},
+		{
+			name:     "unknown TLS version",
+			setup:    func(_ *testing.T, c *Config) { c.TLS.MinVersion = "1.1" },
+			wantErrs: []string{"tls"},
+		},
+		{
+			name:     "issuer with a query",
+			setup:    func(_ *testing.T, c *Config) { c.Issuer = "http://localhost:8080/?tenant=a" },
+			wantErrs: []string{"issuer"},
+		},
+		{
+			name:     "missing signing key",
+			setup:    func(_ *testing.T, c *Config) { c.PrivateKeyFile = "missing.pem" },
+			wantErrs: []string{"private_key_file"},
+		},
+		{
+			name:     "missing data directory",
+			setup:    func(_ *testing.T, c *Config) { c.DataDir = c.Path("missing") },
+			wantErrs: []string{"data_dir"},
+		},
+		{
+			name:     "rate without burst",
+			setup:    func(_ *testing.T, c *Config) { c.RateLimit.ClientBurst = 0 },
+			wantErrs: []string{"rate_limit.client_burst"},
+		},
+		{
+			name:     "base delay above the maximum",
+			setup:    func(_ *testing.T, c *Config) { c.RateLimit.FailureBaseDelay = Duration(time.Hour) },
+			wantErrs: []string{"rate_limit.failure_base_delay"},
+		},
+		{
+			name:     "storage in a missing directory",
+			setup:    func(_ *testing.T, c *Config) { c.Storage.URL = "file:missing/state.db" },
+			wantErrs: []string{"storage.url"},
+		},
+		{
+			name:     "OTLP header without value",
+			setup:    func(_ *testing.T, c *Config) { c.Tracing.OTLPHeaders = "authorization" },
+			wantErrs: []string{"tracing.otlp_headers"},
+		},
+		{
+			name: "every problem",
+			setup: func(_ *testing.T, c *Config) {
+				c.Addr, c.AccessTokenTTL, c.Tracing.SampleRatio, c.Log.Level = "8080", 0, 2, "trace"
+			},
+			wantErrs: []string{"addr", "access_token_ttl", "tracing.sample_ratio", "log.level"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			c.DataDir = newTestDataDir(t)
+			tt.setup(t, &c)
+
+			err := c.Validate()
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Validate() = nil, want errors of %v", tt.wantErrs)
+			}
+			for _, name := range tt.wantErrs {
+				if !strings.Contains("\n"+err.Error(), "\n"+name+": ") {
+					t.Errorf("Validate() = %v, want an error of %s", err, name)
+				}
+			}
+		})
+	}
+}
+
+func TestOTLPHeaders(t *testing.T) {
+	c := Config{Tracing: Tracing{OTLPHeaders: " authorization = Bearer a=b , , x-tenant=t1"}}
+
+	headers, err := c.OTLPHeaders()
+	if err != nil {
+		t.Fatalf("OTLPHeaders() = %v", err)
+	}
+	if len(headers) != 2 || headers["authorization"] != "Bearer a=b" || headers["x-tenant"] != "t1" {
+		t.Errorf("OTLPHeaders() = %v", headers)
+	}
+
+	// A malformed header may be a credential, it is not repeated in the error.
+	c.Tracing.OTLPHeaders = "x-tenant=t1,secret-token"
+	if _, err := c.OTLPHeaders(); err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("OTLPHeaders() = %v, want an error without the header", err)
+	}
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvPrefix prefixes the environment variables of the settings, such as SERVERD_TLS_CERT_FILE for
+	// tls.cert_file.
+	EnvPrefix = "SERVERD_"
+	// EnvConfigFile names the configuration file when the -config flag is not given.
+	EnvConfigFile = EnvPrefix + "CONFIG"
+)
+
+// The name of a setting is its path in configuration files.
+type setting struct {
+	name  string
+	usage string
+	value interface{}
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"addr", "address to listen on", &c.Addr},
+		{"issuer", "issuer of the tokens", &c.Issuer},
+		{"audience", "audience of tokens requested without a resource", &c.Audience},
+		{"access_token_ttl", "lifetime of access tokens", &c.AccessTokenTTL},
+		{"read_timeout", "maximum duration for reading a request", &c.ReadTimeout},
+		{"write_timeout", "maximum duration for writing a response", &c.WriteTimeout},
+		{"data_dir", "directory of the issuer files, relative paths are resolved against it", &c.DataDir},
+		{"private_key_file", "PEM encoded signing key", &c.PrivateKeyFile},
+		{"tenants_dir", "directory of the tenants", &c.TenantsDir},
+		{"tls.enabled", "serve HTTPS", &c.TLS.Enabled},
+		{"tls.cert_file", "TLS certificate", &c.TLS.CertFile},
+		{"tls.key_file", "TLS private key", &c.TLS.KeyFile},
+		{"tls.client_ca_file", "authorities of the certificates of tls_client_auth clients", &c.TLS.ClientCAFile},
+		{"tls.min_version", "minimum TLS version, 1.2 or 1.3", &c.TLS.MinVersion},
+		{"tls.cipher_policy", "intermediate, or modern for TLS 1.3 only", &c.TLS.CipherPolicy},
+		{"tls.redirect_addr", "address of a listener redirecting plaintext requests to HTTPS", &c.TLS.RedirectAddr},
+		{"dpop.nonce_interval", "rotation interval of DPoP nonces, 0 to not require nonces", &c.DPoP.NonceInterval},
+		{"rate_limit.client_rate", "requests per second per client, 0 for no limit", &c.RateLimit.ClientRate},
+		{"rate_limit.client_burst", "burst of requests per client", &c.RateLimit.ClientBurst},
+		{"rate_limit.ip_rate", "requests per second per address, 0 for no limit", &c.RateLimit.IPRate},
+		{"rate_limit.ip_burst", "burst of requests per address", &c.RateLimit.IPBurst},
+		{"rate_limit.failure_threshold", "invalid_client failures before blocking, 0 to never block", &c.RateLimit.FailureThreshold},
+		{"rate_limit.failure_base_delay", "first block duration, doubled by each further failure", &c.RateLimit.FailureBaseDelay},
+		{"rate_limit.failure_max_delay", "longest block duration", &c.RateLimit.FailureMaxDelay},
+		{"storage.url", "file: URL of a state file, or redis:// or rediss:// URL of the storage shared by replicas; in memory when empty", &c.Storage.URL},
+		{"tracing.otlp_endpoint", "OTLP/HTTP endpoint of the collector receiving spans", &c.Tracing.OTLPEndpoint},
+		{"tracing.otlp_headers", "comma separated name=value headers sent to the collector", &c.Tracing.OTLPHeaders},
+		{"tracing.file", "stdout or file spans are written to as JSON lines", &c.Tracing.File},
+		{"tracing.sample_ratio", "ratio of new traces recorded, from 0 to 1", &c.Tracing.SampleRatio},
+		{"tracing.service_name", "service name of the spans", &c.Tracing.ServiceName},
+		{"metrics.addr", "address of an admin listener serving /metrics and /log/level, the main listener serves /metrics when empty", &c.Metrics.Addr},
+		{"log.level", "debug, info, warn or error", &c.Log.Level},
+		{"log.format", "json or console", &c.Log.Format},
+		{"log.file", "file logs are written to, stdout when empty", &c.Log.File},
+		{"log.max_size_mb", "size in megabytes the log file is rotated at, 0 to never rotate it", &c.Log.MaxSizeMB},
+		{"log.max_backups", "number of rotated log files kept", &c.Log.MaxBackups},
+	}
+}
+
+func (s setting) envName() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(s.name, ".", "_"))
+}
+
+func (s setting) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.name)
+}
+
+func (s setting) String() string {
+	switch p := s.value.(type) {
+	case *string:
+		return *p
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *Duration:
+		return p.String()
+	case *float64:
+		return strconv.FormatFloat(*p, 'g', -1, 64)
+	case *int:
+		return strconv.Itoa(*p)
+	default:
+		return ""
+	}
+}
+
+func (s setting) set(v string) error {
+	switch p := s.value.(type) {
+	case *string:
+		*p = v
+		return nil
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		*p = b
+		return nil
+	case *Duration:
+		return p.UnmarshalText([]byte(v))
+	case *float64:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		*p = f
+		return nil
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		*p = n
+		return nil
+	default:
+		return fmt.Errorf("unsupported setting type %T", p)
+	}
+}
+
+func Load(name string, args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	type flagValue struct {
+		setting string
+		value   string
+	}
+	var flagValues []flagValue
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", getenv(EnvConfigFile), "YAML or JSON configuration file")
+	for _, s := range cfg.settings() {
+		usage := fmt.Sprintf("%s (%s, default %q)", s.usage, s.envName(), s.String())
+		record := func(v string) error {
+			flagValues = append(flagValues, flagValue{s.name, v})
+			return nil
+		}
+
+		// Boolean flags can be given without a value, as -tls-enabled.
+		if _, ok := s.value.(*bool); ok {
+			fs.BoolFunc(s.flagName(), usage, record)
+		} else {
+			fs.Func(s.flagName(), usage, record)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if *configFile != "" {
+		if err := readFile(*configFile, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	settings := map[string]setting{}
+	for _, s := range cfg.settings() {
+		settings[s.name] = s
+
+		if v := getenv(s.envName()); v != "" {
+			if err := s.set(v); err != nil {
+				return Config{}, fmt.Errorf("%s: %w", s.envName(), err)
+			}
+		}
+	}
+
+	for _, fv := range flagValues {
+		s := settings[fv.setting]
+		if err := s.set(fv.value); err != nil {
+			return Config{}, fmt.Errorf("-%s: %w", s.flagName(), err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// readFile rejects unknown settings.
+func readFile(path string, cfg *Config) error {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(fileBytes))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(fileBytes))
+		dec.KnownFields(true)
+		if err = dec.Decode(cfg); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .json", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func environment(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "YAML",
+			file: "serverd.yaml",
+			content: `
+addr: ":9000"
+audience: file-audience
+access_token_ttl: 1h
+log:
+  level: debug
+`,
+		},
+		{
+			name:    "JSON",
+			file:    "serverd.json",
+			content: `{"addr": ":9000", "audience": "file-audience", "access_token_ttl": "1h", "log": {"level": "debug"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestDataDir(t)
+			file := filepath.Join(dir, tt.file)
+			writeTestFile(t, file, tt.content)
+
+			cfg, err := Load("serverd", []string{"-data-dir", dir, "-access-token-ttl", "3h"}, environment(map[string]string{
+				EnvConfigFile:              file,
+				"SERVERD_AUDIENCE":         "env-audience",
+				"SERVERD_ACCESS_TOKEN_TTL": "2h",
+			}))
+			if err != nil {
+				t.Fatalf("Load() = %v", err)
+			}
+
+			for _, check := range []struct {
+				setting   string
+				got, want interface{}
+			}{
+				{"read_timeout from the defaults", cfg.ReadTimeout, Duration(time.Second)},
+				{"addr from the file", cfg.Addr, ":9000"},
+				{"log.level from the file", cfg.Log.Level, "debug"},
+				{"log.format from the defaults", cfg.Log.Format, Default().Log.Format},
+				{"audience from the environment", cfg.Audience, "env-audience"},
+				{"access_token_ttl from the flags", cfg.AccessTokenTTL, Duration(3 * time.Hour)},
+			} {
+				if check.got != check.want {
+					t.Errorf("%s = %v, want %v", check.setting, check.got, check.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigFlag(t *testing.T) {
+	dir := newTestDataDir(t)
+	writeTestFile(t, filepath.Join(dir, "flag.yaml"), "audience: flag-file\n")
+	writeTestFile(t, filepath.Join(dir, "env.yaml"), "audience: env-file\n")
+
+	// The -config flag names the file instead of SERVERD_CONFIG.
+	cfg, err := Load("serverd", []string{"-data-dir", dir, "-config", filepath.Join(dir, "flag.yaml")}, environment(map[string]string{
+		EnvConfigFile: filepath.Join(dir, "env.yaml"),
+	}))
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if cfg.Audience != "flag-file" {
+		t.Errorf("audience = %q, want the one of the -config file", cfg.Audience)
+	}
+}
+
+func TestLoadTLSEnabled(t *testing.T) {
+	dir := newTestDataDir(t)
+	writeTestKeyPair(t, filepath.Join(dir, "tls-cert.pem"), filepath.Join(dir, "tls-key.pem"))
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		want    bool
+		wantErr bool
+	}{
+		{name: "flag without value", args: []string{"-tls-enabled"}, want: true},
+		{name: "flag with value", args: []string{"-tls-enabled=true"}, want: true},
+		{name: "environment", env: map[string]string{"SERVERD_TLS_ENABLED": "true"}, want: true},
+		// The certificate exists, so TLS cannot be turned off without clearing it.
+		{name: "flag over the environment", args: []string{"-tls-enabled=false"}, env: map[string]string{"SERVERD_TLS_ENABLED": "true"}, wantErr: true},
+		{name: "invalid boolean", env: map[string]string{"SERVERD_TLS_ENABLED": "yes please"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load("serverd", append([]string{"-data-dir", dir}, tt.args...), environment(tt.env))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Load() = %+v, want an error", cfg.TLS)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() = %v", err)
+			}
+			if cfg.TLS.Enabled != tt.want {
+				t.Errorf("tls.enabled = %v, want %v", cfg.TLS.Enabled, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "unknown YAML setting", file: "serverd.yaml", content: "adress: :9000\n", wantErr: "adress"},
+		{name: "unknown nested YAML setting", file: "serverd.yml", content: "tls:\n  cert: a.pem\n", wantErr: "cert"},
+		{name: "unknown JSON setting", file: "serverd.json", content: `{"adress": ":9000"}`, wantErr: "adress"},
+		{name: "unknown nested JSON setting", file: "serverd.json", content: `{"tls": {"cert": "a.pem"}}`, wantErr: "cert"},
+		{name: "invalid duration in the file", file: "serverd.yaml", content: "read_timeout: soon\n", wantErr: "soon"},
+		{name: "unsupported format", file: "serverd.toml", content: `addr = ":9000"`, wantErr: "unsupported format"},
+		{name: "missing file", args: []string{"-config", "missing.yaml"}, wantErr: "missing.yaml"},
+		{name: "invalid variable", env: map[string]string{"SERVERD_RATE_LIMIT_IP_BURST": "many"}, wantErr: "SERVERD_RATE_LIMIT_IP_BURST"},
+		{name: "invalid flag", args: []string{"-tracing-sample-ratio", "half"}, wantErr: "-tracing-sample-ratio"},
+		{name: "unknown flag", args: []string{"-adress", ":9000"}, wantErr: "adress"},
+		{name: "argument", args: []string{"serve"}, wantErr: `"serve"`},
+		{name: "invalid configuration", args: []string{"-log-level", "trace"}, wantErr: "log.level"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestDataDir(t)
+			args := []string{"-data-dir", dir}
+			if tt.file != "" {
+				file := filepath.Join(dir, tt.file)
+				writeTestFile(t, file, tt.content)
+				args = append(args, "-config", file)
+			}
+
+			_, err := Load("serverd", append(args, tt.args...), environment(tt.env))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() = %v, want an error mentioning %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package consent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+type Store interface {
+	Granted(ctx context.Context, userID, clientID string) ([]string, error)
+	Grant(ctx context.Context, userID, clientID string, scopes []string) error
+}
+
+const (
+	keyPrefix    = "consent:"
+	grantLockTTL = 5 * time.Second
+)
+
+type kvStore struct {
+	kv storage.Store
+}
+
+// NewStore keeps consent decisions without expiry.
+func NewStore(kv storage.Store) Store {
+	return kvStore{kv: kv}
+}
+
+func (s kvStore) Granted(ctx context.Context, userID, clientID string) ([]string, error) {
+	b, err := s.kv.Get(ctx, key(userID, clientID))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var scopes []string
+	if err := json.Unmarshal(b, &scopes); err != nil {
+		return nil, err
+	}
+
+	return scopes, nil
+}
+
+func (s kvStore) Grant(ctx context.Context, userID, clientID string, scopes []string) error {
+	k := key(userID, clientID)
+
+	// Concurrent grants to the same client must not drop each other's scopes.
+	unlock, err := storage.Lock(ctx, s.kv, k, grantLockTTL)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	granted, err := s.Granted(ctx, userID, clientID)
+	if err != nil {
+		return err
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	b, err := json.Marshal(granted)
+	if err != nil {
+		return err
+	}
+
+	return s.kv.Set(ctx, k, b, 0)
+}
+
+func Covers(granted, requested []string) bool {
+	for _, scope := range requested {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func key(userID, clientID string) string {
+	return keyPrefix + userID + "\x00" + clientID
+}
@@ -0,0 +1,88 @@
+package device
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	StatusPending  Status = "pending"
+	StatusApproved Status = "approved"
+	StatusDenied   Status = "denied"
+
+	// userCodeCharset excludes vowels and look-alike characters, as recommended by RFC 8628 section 6.1.
+	userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength  = 8
+)
+
+var (
+	ErrNotFound = errors.New("device authorization not found")
+)
+
+type Status string
+
+type Authorization struct {
+	DeviceCode string
+	UserCode   string
+	ClientID   string
+	Scopes     []string
+	Status     Status
+
+	UserID   string
+	AuthTime time.Time
+	ACR      string
+	AMR      []string
+
+	Interval     time.Duration
+	LastPolledAt time.Time
+	ExpiresAt    time.Time
+}
+
+type Store interface {
+	Save(ctx context.Context, auth Authorization) error
+
+	// Expired authorizations may still be returned until they are evicted, so callers check ExpiresAt.
+	GetByDeviceCode(ctx context.Context, deviceCode string) (Authorization, error)
+
+	// GetByUserCode takes a normalized user code.
+	GetByUserCode(ctx context.Context, userCode string) (Authorization, error)
+
+	// Update applies fn atomically and saves nothing when fn returns an error.
+	Update(ctx context.Context, deviceCode string, fn func(*Authorization) error) (Authorization, error)
+
+	// Delete returns ErrNotFound when another caller removed the authorization first.
+	Delete(ctx context.Context, deviceCode string) error
+}
+
+func NewUserCode() (string, error) {
+	var sb strings.Builder
+	for i := 0; i < userCodeLength; i++ {
+		if i == userCodeLength/2 {
+			sb.WriteByte('-')
+		}
+
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(userCodeCharset))))
+		if err != nil {
+			return "", fmt.Errorf("could not generate user code: %w", err)
+		}
+		sb.WriteByte(userCodeCharset[n.Int64()])
+	}
+
+	return sb.String(), nil
+}
+
+func NormalizeUserCode(userCode string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToUpper(userCode) {
+		if strings.ContainsRune(userCodeCharset, r) {
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,120 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+const (
+	keyPrefix         = "device:"
+	userCodeKeyPrefix = "device_user_code:"
+
+	// expiredRetention keeps expired authorizations for a while, so clients still polling are told the device
+	// code expired rather than that it does not exist.
+	expiredRetention = 10 * time.Minute
+	updateLockTTL    = 5 * time.Second
+)
+
+type kvStore struct {
+	kv storage.Store
+}
+
+func NewStore(kv storage.Store) Store {
+	return kvStore{kv: kv}
+}
+
+func (s kvStore) Save(ctx context.Context, auth Authorization) error {
+	if err := s.save(ctx, auth); err != nil {
+		return err
+	}
+
+	return s.kv.Set(ctx, userCodeKeyPrefix+NormalizeUserCode(auth.UserCode), []byte(auth.DeviceCode), retention(auth))
+}
+
+func (s kvStore) GetByDeviceCode(ctx context.Context, deviceCode string) (Authorization, error) {
+	b, err := s.kv.Get(ctx, keyPrefix+deviceCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		return Authorization{}, ErrNotFound
+	}
+	if err != nil {
+		return Authorization{}, err
+	}
+
+	var auth Authorization
+	if err := json.Unmarshal(b, &auth); err != nil {
+		return Authorization{}, err
+	}
+
+	return auth, nil
+}
+
+func (s kvStore) GetByUserCode(ctx context.Context, userCode string) (Authorization, error) {
+	deviceCode, err := s.kv.Get(ctx, userCodeKeyPrefix+NormalizeUserCode(userCode))
+	if errors.Is(err, storage.ErrNotFound) {
+		return Authorization{}, ErrNotFound
+	}
+	if err != nil {
+		return Authorization{}, err
+	}
+
+	return s.GetByDeviceCode(ctx, string(deviceCode))
+}
+
+func (s kvStore) Update(ctx context.Context, deviceCode string, fn func(*Authorization) error) (Authorization, error) {
+	// The user approving on one replica and the device polling another must not overwrite each other.
+	unlock, err := storage.Lock(ctx, s.kv, keyPrefix+deviceCode, updateLockTTL)
+	if err != nil {
+		return Authorization{}, err
+	}
+	defer unlock()
+
+	auth, err := s.GetByDeviceCode(ctx, deviceCode)
+	if err != nil {
+		return Authorization{}, err
+	}
+
+	if err := fn(&auth); err != nil {
+		return Authorization{}, err
+	}
+
+	if err := s.save(ctx, auth); err != nil {
+		return Authorization{}, err
+	}
+
+	return auth, nil
+}
+
+func (s kvStore) Delete(ctx context.Context, deviceCode string) error {
+	b, err := s.kv.Take(ctx, keyPrefix+deviceCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var auth Authorization
+	if err := json.Unmarshal(b, &auth); err != nil {
+		return err
+	}
+
+	_, err = s.kv.Delete(ctx, userCodeKeyPrefix+NormalizeUserCode(auth.UserCode))
+	return err
+}
+
+func (s kvStore) save(ctx context.Context, auth Authorization) error {
+	b, err := json.Marshal(auth)
+	if err != nil {
+		return err
+	}
+
+	return s.kv.Set(ctx, keyPrefix+auth.DeviceCode, b, retention(auth))
+}
+
+func retention(auth Authorization) time.Duration {
+	return max(time.Until(auth.ExpiresAt), 0) + expiredRetention
+}
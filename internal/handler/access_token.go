@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/resource"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/session"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/tracing"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+)
+
+const (
+	accessTokenType = "at+jwt"
+	jwtType         = "JWT"
+)
+
+func (h Handler) signAccessToken(ctx context.Context, grant tokenGrant) (token string, err error) {
+	clientID, _ := grant.Claims["azp"].(string)
+	ctx, span := tracing.StartSpan(ctx, "access_token.issue",
+		tracing.String("oauth.grant_type", grant.GrantType),
+		tracing.String("oauth.client_id", clientID),
+	)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// Every access token has an ID, by which it can be revoked.
+	if grant.Claims["jti"], err = session.NewID(); err != nil {
+		return "", err
+	}
+
+	if err := h.addAuthorizationClaims(ctx, grant.Claims); err != nil {
+		return "", err
+	}
+
+	profile, err := h.tokenProfile(ctx, grant.Claims["aud"])
+	if err != nil {
+		return "", err
+	}
+	span.SetAttributes(tracing.String("token.profile", profile))
+
+	if profile == resource.ProfileRFC9068 {
+		if err := h.addRFC9068Claims(ctx, grant); err != nil {
+			return "", err
+		}
+	}
+
+	if err := h.applyClaimMappings(ctx, grant.Claims); err != nil {
+		return "", err
+	}
+
+	if err := h.callTokenHook(ctx, grant.GrantType, grant.Claims); err != nil {
+		return "", err
+	}
+
+	if profile != resource.ProfileRFC9068 {
+		return h.signClaims(ctx, jwtType, grant.Claims)
+	}
+
+	return h.signClaims(ctx, accessTokenType, grant.Claims)
+}
+
+func (h Handler) addRFC9068Claims(ctx context.Context, grant tokenGrant) error {
+	claims := grant.Claims
+	claims["client_id"] = claims["azp"]
+
+	if auth := grant.Authentication; auth != nil {
+		claims["auth_time"] = auth.AuthTime.Unix()
+		if auth.ACR != "" {
+			claims["acr"] = auth.ACR
+		}
+		if len(auth.AMR) > 0 {
+			claims["amr"] = auth.AMR
+		}
+	}
+
+	// Tokens issued for a user carry the user's authorization attributes.
+	if sub, ok := claims["sub"].(string); ok {
+		u, err := h.users.Get(ctx, sub)
+		switch {
+		case err == nil:
+			setNonEmpty(claims, "groups", u.Groups)
+			setNonEmpty(claims, "entitlements", u.Entitlements)
+		case !errors.Is(err, user.ErrNotFound):
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tokenProfile uses the RFC 9068 profile as soon as one of the resources in the audience asks for it.
+func (h Handler) tokenProfile(ctx context.Context, aud interface{}) (string, error) {
+	profile := resource.ProfileJWT
+	for _, id := range audiences(aud) {
+		res, err := h.resources.Get(ctx, id)
+		if errors.Is(err, resource.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if res.TokenProfile == resource.ProfileRFC9068 {
+			profile = resource.ProfileRFC9068
+		}
+	}
+
+	return profile, nil
+}
+
+func setNonEmpty(claims map[string]interface{}, key string, values []string) {
+	if len(values) > 0 {
+		claims[key] = values
+	}
+}
+
+func audiences(aud interface{}) []string {
+	switch aud := aud.(type) {
+	case string:
+		return []string{aud}
+	case []string:
+		return aud
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,485 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/audit"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/authcode"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/consent"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/par"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/session"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+)
+
+const (
+	sessionCookieName = "sid"
+	csrfCookieName    = "csrf_token"
+
+	promptNone    = "none"
+	promptLogin   = "login"
+	promptConsent = "consent"
+
+	responseTypeCode        = "code"
+	responseTypeCodeIDToken = "code id_token"
+
+	responseModeQuery    = "query"
+	responseModeFragment = "fragment"
+)
+
+// Query holds the parameters that resume the request through the login and consent forms, which are a
+// reference to the stored request when there is one.
+type authorizeRequest struct {
+	Client              client.Client
+	ResponseType        string
+	ResponseMode        string
+	RedirectURI         string
+	State               string
+	Nonce               string
+	Scopes              []string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Prompts             []string
+	// MaxAge is the allowable time since the user last signed in, or -1 when not restricted.
+	MaxAge time.Duration
+	Query  url.Values
+}
+
+type redirectError struct {
+	Code        string
+	Description string
+}
+
+func (e *redirectError) Error() string {
+	return e.Code
+}
+
+func (h Handler) Authorize() gin.HandlerFunc {
+	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
+		req, err := h.parseAuthorizeRequest(ctx, ctx.Request.URL.Query())
+		if err != nil {
+			return h.authorizeFailed(ctx, req, err)
+		}
+
+		sess, err := h.currentSession(ctx)
+		if err == nil && req.MaxAge >= 0 && time.Since(sess.AuthTime) > req.MaxAge {
+			err = session.ErrNotFound
+		}
+
+		if errors.Is(err, session.ErrNotFound) || slices.Contains(req.Prompts, promptLogin) {
+			if slices.Contains(req.Prompts, promptNone) {
+				return h.authorizeFailed(ctx, req, &redirectError{Code: "login_required"})
+			}
+
+			return h.renderLogin(ctx, authorizeReturnTo(req), clientName(req.Client), http.StatusOK, "")
+		}
+		if err != nil {
+			return err
+		}
+
+		granted, err := h.consents.Granted(ctx, sess.UserID, req.Client.ID)
+		if err != nil {
+			return err
+		}
+
+		if !consent.Covers(granted, req.Scopes) || slices.Contains(req.Prompts, promptConsent) {
+			if slices.Contains(req.Prompts, promptNone) {
+				return h.authorizeFailed(ctx, req, &redirectError{Code: "consent_required"})
+			}
+
+			return h.renderConsent(ctx, req)
+		}
+
+		return h.issueAuthorizationCode(ctx, req, sess)
+	})
+}
+
+func (h Handler) Login() gin.HandlerFunc {
+	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
+		if !validCSRFToken(ctx) {
+			return h.renderError(ctx, errInvalidCSRFToken)
+		}
+
+		returnTo, err := parseReturnTo(ctx.PostForm("return_to"))
+		if err != nil {
+			return h.renderError(ctx, err)
+		}
+
+		username := ctx.PostForm("username")
+		u, err := h.users.Authenticate(ctx, username, ctx.PostForm("password"))
+		if err := h.auditAuthentication(ctx, audit.TypeLogin, "", username, u, err); err != nil {
+			return err
+		}
+		switch {
+		case errors.Is(err, user.ErrInvalidCredentials):
+			return h.renderLogin(ctx, returnTo, h.loginTitle(ctx, returnTo), http.StatusUnauthorized, "Invalid username or password.")
+		case errors.Is(err, user.ErrLocked):
+			return h.renderLogin(ctx, returnTo, h.loginTitle(ctx, returnTo), http.StatusTooManyRequests, "Too many failed attempts. Try again later.")
+		case err != nil:
+			return err
+		}
+
+		if old, err := ctx.Cookie(sessionCookieName); err == nil {
+			if err := h.sessions.Delete(ctx, old); err != nil {
+				return err
+			}
+		}
+
+		sess, err := h.sessions.Create(ctx, session.Session{
+			UserID: u.ID,
+			ACR:    acrPassword,
+			AMR:    []string{amrPassword},
+		}, sessionTTL)
+		if err != nil {
+			return err
+		}
+		h.setCookie(ctx, sessionCookieName, sess.ID, sessionTTL)
+
+		if returnTo.Path == strings.TrimPrefix(PathAuthorize, "/") {
+			// The user has just signed in, so a forced login must not be asked again.
+			if err := h.clearLoginPrompt(ctx, returnTo); err != nil {
+				return err
+			}
+		}
+
+		ctx.Redirect(http.StatusFound, returnTo.String())
+		return nil
+	})
+}
+
+func (h Handler) Consent() gin.HandlerFunc {
+	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
+		if !validCSRFToken(ctx) {
+			return h.renderError(ctx, errInvalidCSRFToken)
+		}
+
+		query, err := url.ParseQuery(ctx.PostForm("request"))
+		if err != nil {
+			return h.renderError(ctx, errInvalidRequest)
+		}
+
+		req, err := h.parseAuthorizeRequest(ctx, query)
+		if err != nil {
+			return h.authorizeFailed(ctx, req, err)
+		}
+
+		sess, err := h.currentSession(ctx)
+		if errors.Is(err, session.ErrNotFound) {
+			return h.renderError(ctx, errLoginRequired)
+		}
+		if err != nil {
+			return err
+		}
+
+		if ctx.PostForm("decision") != "allow" {
+			return h.authorizeFailed(ctx, req, &redirectError{Code: "access_denied", Description: "user denied the request"})
+		}
+
+		if ctx.PostForm("remember") != "" {
+			if err := h.consents.Grant(ctx, sess.UserID, req.Client.ID, req.Scopes); err != nil {
+				return err
+			}
+		}
+
+		return h.issueAuthorizationCode(ctx, req, sess)
+	})
+}
+
+// parseAuthorizeRequest returns the errors found before the redirect URI is validated as
+// *httpserver.HTTPError, which must not be redirected.
+func (h Handler) parseAuthorizeRequest(ctx *gin.Context, query url.Values) (authorizeRequest, error) {
+	cl, err := h.clients.Get(ctx, query.Get("client_id"))
+	if errors.Is(err, client.ErrNotFound) {
+		return authorizeRequest{Query: query}, errUnknownClient
+	}
+	if err != nil {
+		return authorizeRequest{Query: query}, err
+	}
+
+	params, err := h.authorizeParameters(ctx, cl, query)
+	if err != nil {
+		return authorizeRequest{Query: query}, err
+	}
+
+	req, err := validateAuthorizeRequest(cl, params)
+	req.Query = query
+	if err != nil {
+		return req, err
+	}
+
+	// A request object is stored like a pushed request, so its parameters do not travel through the login
+	// and consent pages and the login prompt can be cleared after signing in.
+	if query.Has("request") {
+		requestURI, err := h.storeAuthorizeRequest(ctx, cl, params)
+		if err != nil {
+			return req, err
+		}
+		req.Query = url.Values{"client_id": {cl.ID}, "request_uri": {requestURI}}
+	}
+
+	return req, nil
+}
+
+func validateAuthorizeRequest(cl client.Client, query url.Values) (authorizeRequest, error) {
+	req := authorizeRequest{
+		ResponseType:        strings.Join(sortedFields(query.Get("response_type")), " "),
+		ResponseMode:        responseModeQuery,
+		RedirectURI:         query.Get("redirect_uri"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		Scopes:              strings.Fields(query.Get("scope")),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+		Prompts:             strings.Fields(query.Get("prompt")),
+		MaxAge:              -1,
+	}
+
+	if !cl.AllowsRedirectURI(req.RedirectURI) {
+		return req, errInvalidRedirectURI
+	}
+	req.Client = cl
+
+	switch req.ResponseType {
+	case responseTypeCode:
+	case responseTypeCodeIDToken:
+		req.ResponseMode = responseModeFragment
+		if !slices.Contains(req.Scopes, scopeOpenID) || req.Nonce == "" {
+			return req, &redirectError{Code: "invalid_request", Description: "hybrid flow requires the openid scope and a nonce"}
+		}
+	default:
+		return req, &redirectError{Code: "unsupported_response_type", Description: "response type is not supported"}
+	}
+
+	if mode := query.Get("response_mode"); mode != "" {
+		if mode != responseModeQuery && mode != responseModeFragment {
+			return req, &redirectError{Code: "invalid_request", Description: "response mode is not supported"}
+		}
+		req.ResponseMode = mode
+	}
+
+	if !cl.AllowsGrantType(client.GrantTypeAuthorizationCode) {
+		return req, &redirectError{Code: "unauthorized_client", Description: "client is not allowed to use the authorization code grant"}
+	}
+
+	if !cl.AllowsScopes(req.Scopes) {
+		return req, &redirectError{Code: "invalid_scope", Description: errInvalidScope.Detail}
+	}
+
+	if req.CodeChallenge == "" && cl.Public() {
+		return req, &redirectError{Code: "invalid_request", Description: "public clients must use PKCE"}
+	}
+
+	if req.CodeChallenge != "" {
+		if req.CodeChallengeMethod == "" {
+			req.CodeChallengeMethod = authcode.ChallengeMethodPlain
+		}
+
+		if req.CodeChallengeMethod != authcode.ChallengeMethodPlain && req.CodeChallengeMethod != authcode.ChallengeMethodS256 {
+			return req, &redirectError{Code: "invalid_request", Description: "unsupported code challenge method"}
+		}
+	}
+
+	if maxAge := query.Get("max_age"); maxAge != "" {
+		seconds, err := strconv.ParseUint(maxAge, 10, 32)
+		if err != nil {
+			return req, &redirectError{Code: "invalid_request", Description: "max_age must be a number of seconds"}
+		}
+		req.MaxAge = time.Duration(seconds) * time.Second
+	}
+
+	if slices.Contains(req.Prompts, promptNone) && len(req.Prompts) > 1 {
+		return req, &redirectError{Code: "invalid_request", Description: "prompt none cannot be combined with other values"}
+	}
+
+	return req, nil
+}
+
+func (h Handler) loginTitle(ctx *gin.Context, returnTo *url.URL) string {
+	if returnTo.Path != strings.TrimPrefix(PathAuthorize, "/") {
+		return "your device"
+	}
+
+	req, err := h.parseAuthorizeRequest(ctx, returnTo.Query())
+	if err != nil {
+		return "the application"
+	}
+
+	return clientName(req.Client)
+}
+
+func (h Handler) issueAuthorizationCode(ctx *gin.Context, req authorizeRequest, sess session.Session) error {
+	// A stored request can only be used once.
+	if req.Query.Has("request_uri") {
+		if err := h.pushedRequests.Delete(ctx, req.Query.Get("request_uri")); errors.Is(err, par.ErrNotFound) {
+			return h.renderError(ctx, errInvalidRequestURI)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	id, err := session.NewID()
+	if err != nil {
+		return err
+	}
+
+	code := authcode.Code{
+		Code:                id,
+		ClientID:            req.Client.ID,
+		UserID:              sess.UserID,
+		RedirectURI:         req.RedirectURI,
+		Scopes:              req.Scopes,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
+		AuthTime:            sess.AuthTime,
+		ACR:                 sess.ACR,
+		AMR:                 sess.AMR,
+		ExpiresAt:           time.Now().Add(authorizationCodeTTL),
+	}
+	if err := h.codes.Save(ctx, code); err != nil {
+		return err
+	}
+
+	params := url.Values{"code": {code.Code}}
+	if req.ResponseType == responseTypeCodeIDToken {
+		idToken, err := h.generateIDToken(ctx, authentication{
+			UserID:   code.UserID,
+			ClientID: code.ClientID,
+			Nonce:    code.Nonce,
+			AuthTime: code.AuthTime,
+			ACR:      code.ACR,
+			AMR:      code.AMR,
+		}, "", code.Code)
+		if err != nil {
+			return err
+		}
+		params.Set("id_token", idToken)
+	}
+
+	return redirectToClient(ctx, req, params)
+}
+
+func (h Handler) authorizeFailed(ctx *gin.Context, req authorizeRequest, err error) error {
+	var redirectErr *redirectError
+	if !errors.As(err, &redirectErr) {
+		return h.renderError(ctx, err)
+	}
+
+	params := url.Values{"error": {redirectErr.Code}}
+	if redirectErr.Description != "" {
+		params.Set("error_description", redirectErr.Description)
+	}
+
+	return redirectToClient(ctx, req, params)
+}
+
+func (h Handler) currentSession(ctx *gin.Context) (session.Session, error) {
+	id, err := ctx.Cookie(sessionCookieName)
+	if err != nil {
+		return session.Session{}, session.ErrNotFound
+	}
+
+	return h.sessions.Get(ctx, id)
+}
+
+func authorizeReturnTo(req authorizeRequest) *url.URL {
+	return &url.URL{Path: strings.TrimPrefix(PathAuthorize, "/"), RawQuery: req.Query.Encode()}
+}
+
+// parseReturnTo only accepts the pages that send users to login, so the login form is not an open redirect.
+func parseReturnTo(returnTo string) (*url.URL, error) {
+	u, err := url.Parse(returnTo)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil {
+		return nil, errInvalidRequest
+	}
+
+	switch u.Path {
+	case strings.TrimPrefix(PathAuthorize, "/"), strings.TrimPrefix(PathDevice, "/"):
+		return u, nil
+	default:
+		return nil, errInvalidRequest
+	}
+}
+
+func redirectToClient(ctx *gin.Context, req authorizeRequest, params url.Values) error {
+	u, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		return fmt.Errorf("could not parse redirect URI: %w", err)
+	}
+
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	if req.ResponseMode == responseModeFragment {
+		u.Fragment = params.Encode()
+	} else {
+		query := u.Query()
+		for k, v := range params {
+			query[k] = v
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	ctx.Redirect(http.StatusFound, u.String())
+	return nil
+}
+
+// setCookie sets a cookie scoped to the path of the issuer, so tenants served on the same host do not share
+// sessions.
+func (h Handler) setCookie(ctx *gin.Context, name, value string, ttl time.Duration) {
+	path := "/"
+	if u, err := url.Parse(h.issuer); err == nil && u.Path != "" {
+		path = u.Path
+	}
+
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(ttl.Seconds()),
+		Secure:   ctx.Request.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// csrfToken returns the double-submit token of the browser, issuing one when missing.
+func (h Handler) csrfToken(ctx *gin.Context) (string, error) {
+	if token, err := ctx.Cookie(csrfCookieName); err == nil && token != "" {
+		return token, nil
+	}
+
+	token, err := session.NewID()
+	if err != nil {
+		return "", err
+	}
+	h.setCookie(ctx, csrfCookieName, token, sessionTTL)
+
+	return token, nil
+}
+
+func validCSRFToken(ctx *gin.Context) bool {
+	cookie, err := ctx.Cookie(csrfCookieName)
+	if err != nil || cookie == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(ctx.PostForm("csrf_token"))) == 1
+}
+
+func sortedFields(value string) []string {
+	fields := strings.Fields(value)
+	slices.Sort(fields)
+	return fields
+}
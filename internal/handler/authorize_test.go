@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedirectToClient(t *testing.T) {
+	tests := []struct {
+		name         string
+		redirectURI  string
+		responseMode string
+		state        string
+		wantLocation string
+		wantErr      bool
+	}{
+		{
+			name:         "query",
+			redirectURI:  "https://app.example/callback?tab=1",
+			responseMode: responseModeQuery,
+			state:        "xyz",
+			wantLocation: "https://app.example/callback?code=abc&state=xyz&tab=1",
+		},
+		{
+			name:         "fragment",
+			redirectURI:  "https://app.example/callback",
+			responseMode: responseModeFragment,
+			wantLocation: "https://app.example/callback#code=abc",
+		},
+		{
+			name:        "unparsable redirect URI",
+			redirectURI: "https://app.example/%zz",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			rec := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(rec)
+			ctx.Request = httptest.NewRequest(http.MethodGet, PathAuthorize, nil)
+
+			req := authorizeRequest{RedirectURI: tt.redirectURI, ResponseMode: tt.responseMode, State: tt.state}
+			err := redirectToClient(ctx, req, url.Values{"code": {"abc"}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("redirectToClient() = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if rec.Code != http.StatusFound {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusFound)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
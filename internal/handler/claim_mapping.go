@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/claimmap"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/resource"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+)
+
+// applyClaimMappings applies the mapping of the client before those of the resources in the audience.
+func (h Handler) applyClaimMappings(ctx context.Context, claims map[string]interface{}) error {
+	cl, attrs, err := h.issuanceAttributes(ctx, claims)
+	if err != nil {
+		return err
+	}
+
+	mappings := []claimmap.Mapping{cl.ClaimMapping}
+	for _, id := range audiences(claims["aud"]) {
+		res, err := h.resources.Get(ctx, id)
+		if errors.Is(err, resource.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		mappings = append(mappings, res.ClaimMapping)
+	}
+
+	for _, m := range mappings {
+		m.Apply(claims, attrs)
+	}
+
+	return nil
+}
+
+func (h Handler) issuanceAttributes(ctx context.Context, claims map[string]interface{}) (client.Client, map[string]interface{}, error) {
+	clientID, _ := claims["azp"].(string)
+	cl, err := h.clients.Get(ctx, clientID)
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		return client.Client{}, nil, err
+	}
+
+	attrs := map[string]interface{}{
+		"client": clientAttributes(cl),
+	}
+
+	if sub, ok := claims["sub"].(string); ok {
+		u, err := h.users.Get(ctx, sub)
+		switch {
+		case err == nil:
+			attrs["user"] = userAttributes(u)
+		case !errors.Is(err, user.ErrNotFound):
+			return client.Client{}, nil, err
+		}
+	}
+
+	return cl, attrs, nil
+}
+
+// clientAttributes leaves out the client credentials.
+func clientAttributes(cl client.Client) map[string]interface{} {
+	return withoutEmpty(map[string]interface{}{
+		"client_id":   cl.ID,
+		"client_name": cl.Name,
+		"scopes":      cl.Scopes,
+		"grant_types": cl.GrantTypes,
+		"roles":       cl.Roles,
+		"metadata":    cl.Metadata,
+	})
+}
+
+// userAttributes leaves out the password hash.
+func userAttributes(u user.User) map[string]interface{} {
+	return withoutEmpty(map[string]interface{}{
+		"id":             u.ID,
+		"username":       u.Username,
+		"name":           u.Name,
+		"given_name":     u.GivenName,
+		"family_name":    u.FamilyName,
+		"picture":        u.Picture,
+		"locale":         u.Locale,
+		"email":          u.Email,
+		"email_verified": u.EmailVerified,
+		"roles":          u.Roles,
+		"groups":         u.Groups,
+		"entitlements":   u.Entitlements,
+		"metadata":       u.Metadata,
+	})
+}
+
+// withoutEmpty removes the unset attributes, so mappings copying them leave the claim out.
+func withoutEmpty(attrs map[string]interface{}) map[string]interface{} {
+	for k, v := range attrs {
+		switch v := v.(type) {
+		case string:
+			if v == "" {
+				delete(attrs, k)
+			}
+		case []string:
+			if len(v) == 0 {
+				delete(attrs, k)
+			}
+		case map[string]interface{}:
+			if len(v) == 0 {
+				delete(attrs, k)
+			}
+		}
+	}
+
+	return attrs
+}
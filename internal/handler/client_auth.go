@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+)
+
+const (
+	clientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	assertionLeeway = 30 * time.Second
+	// maxAssertionLifetime bounds how long used assertion IDs must be remembered.
+	maxAssertionLifetime = time.Hour
+)
+
+type assertionClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	JWTID     string   `json:"jti"`
+}
+
+// Public clients only identify themselves and are not authenticated.
+func (h Handler) authenticateClient(ctx *gin.Context, creds clientCredentials) (client.Client, error) {
+	method := client.AuthMethodClientSecretPost
+	if id, secret, ok := ctx.Request.BasicAuth(); ok {
+		if creds.ClientSecret != "" || creds.ClientAssertion != "" {
+			return client.Client{}, errMultipleClientAuthMethods
+		}
+
+		method = client.AuthMethodClientSecretBasic
+		creds.ClientID, creds.ClientSecret = id, secret
+	}
+
+	var assertion jose.Token
+	switch {
+	case creds.ClientAssertion != "":
+		if creds.ClientSecret != "" {
+			return client.Client{}, errMultipleClientAuthMethods
+		}
+
+		if creds.ClientAssertionType != clientAssertionTypeJWTBearer {
+			return client.Client{}, h.clientAuthFailure(failureInvalidAssertion, errInvalidClientIDOrSecret)
+		}
+
+		var err error
+		if assertion, err = jose.Parse(creds.ClientAssertion); err != nil {
+			return client.Client{}, h.clientAuthFailure(failureInvalidAssertion, errInvalidClientIDOrSecret)
+		}
+
+		// The client may be identified by the assertion alone.
+		if creds.ClientID == "" {
+			var claims assertionClaims
+			if err := assertion.Claims(&claims); err != nil {
+				return client.Client{}, h.clientAuthFailure(failureInvalidAssertion, errInvalidClientIDOrSecret)
+			}
+			creds.ClientID = claims.Subject
+		}
+
+		method = client.AuthMethodPrivateKeyJWT
+	case creds.ClientSecret == "":
+		method = client.AuthMethodNone
+	}
+
+	cl, err := h.clients.Get(ctx, creds.ClientID)
+	if errors.Is(err, client.ErrNotFound) {
+		return client.Client{}, h.clientAuthFailure(failureUnknownClient, errInvalidClientIDOrSecret)
+	}
+	if err != nil {
+		return client.Client{}, err
+	}
+
+	// Certificates are presented in the TLS handshake, so a client without other credentials is
+	// authenticated with the TLS method it registered.
+	if method == client.AuthMethodNone && isTLSAuthMethod(cl.TokenEndpointAuthMethod) {
+		method = cl.TokenEndpointAuthMethod
+	}
+
+	if !cl.AllowsAuthMethod(method) {
+		return client.Client{}, h.clientAuthFailure(failureAuthMethodNotAllowed, errInvalidClientIDOrSecret)
+	}
+
+	switch method {
+	case client.AuthMethodClientSecretBasic, client.AuthMethodClientSecretPost:
+		if !cl.VerifySecret(creds.ClientSecret) {
+			return client.Client{}, h.clientAuthFailure(failureInvalidClientSecret, errInvalidClientIDOrSecret)
+		}
+	case client.AuthMethodPrivateKeyJWT:
+		claims, err := h.verifyAssertion(ctx, cl, assertion)
+		if err != nil {
+			return client.Client{}, h.clientAuthFailure(failureInvalidAssertion, err)
+		}
+
+		if claims.Subject != cl.ID {
+			return client.Client{}, h.clientAuthFailure(failureInvalidAssertion, errInvalidClientIDOrSecret)
+		}
+	case client.AuthMethodTLSClientAuth, client.AuthMethodSelfSignedTLS:
+		if err := h.verifyClientCertificate(ctx, cl, method); err != nil {
+			return client.Client{}, h.clientAuthFailure(failureInvalidCertificate, err)
+		}
+	}
+
+	return cl, nil
+}
+
+// clientAuthFailure only counts the errors rejecting the credentials, and returns err.
+func (h Handler) clientAuthFailure(reason string, err error) error {
+	if errors.Is(err, errInvalidClientIDOrSecret) {
+		h.metrics.countAuthFailure(h.issuer, reason)
+	}
+
+	return err
+}
+
+// The client obtains tokens for itself, or for the users it has been allowed to act as.
+func (h Handler) jwtBearerGrant(ctx *gin.Context, cl client.Client, req generateTokenRequest, _ confirmation) (tokenGrant, error) {
+	assertion, err := jose.Parse(req.Assertion)
+	if err != nil {
+		return tokenGrant{}, errInvalidGrant
+	}
+
+	claims, err := h.verifyAssertion(ctx, cl, assertion)
+	if errors.Is(err, errInvalidClientIDOrSecret) {
+		return tokenGrant{}, errInvalidGrant
+	}
+	if err != nil {
+		return tokenGrant{}, err
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if !cl.AllowsScopes(scopes) {
+		return tokenGrant{}, errInvalidScope
+	}
+
+	if claims.Subject == cl.ID {
+		return tokenGrant{Claims: h.accessTokenClaims(cl.ID+"@clients", cl.ID, scopes)}, nil
+	}
+
+	if !slices.Contains(cl.AssertionSubjects, claims.Subject) {
+		return tokenGrant{}, errInvalidGrant
+	}
+
+	if _, err := h.users.Get(ctx, claims.Subject); errors.Is(err, user.ErrNotFound) {
+		return tokenGrant{}, errInvalidGrant
+	} else if err != nil {
+		return tokenGrant{}, err
+	}
+
+	return tokenGrant{Claims: h.accessTokenClaims(claims.Subject, cl.ID, scopes)}, nil
+}
+
+func (h Handler) verifyAssertion(ctx context.Context, cl client.Client, assertion jose.Token) (assertionClaims, error) {
+	var claims assertionClaims
+	if err := assertion.Claims(&claims); err != nil {
+		return assertionClaims{}, errInvalidClientIDOrSecret
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != cl.ID || claims.Subject == "" || claims.JWTID == "":
+		return assertionClaims{}, errInvalidClientIDOrSecret
+	case !slices.ContainsFunc(claims.Audience, h.isAssertionAudience):
+		return assertionClaims{}, errInvalidClientIDOrSecret
+	case claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(assertionLeeway)):
+		return assertionClaims{}, errInvalidClientIDOrSecret
+	case time.Unix(claims.ExpiresAt, 0).After(now.Add(maxAssertionLifetime)):
+		return assertionClaims{}, errInvalidClientIDOrSecret
+	case claims.NotBefore != 0 && now.Add(assertionLeeway).Before(time.Unix(claims.NotBefore, 0)):
+		return assertionClaims{}, errInvalidClientIDOrSecret
+	}
+
+	if err := h.verifyClientSignature(ctx, cl, assertion); err != nil {
+		return assertionClaims{}, err
+	}
+
+	fresh, err := h.replays.Use(ctx, "assertion:"+cl.ID+":"+claims.JWTID, time.Unix(claims.ExpiresAt, 0).Add(assertionLeeway))
+	if err != nil {
+		return assertionClaims{}, err
+	}
+	if !fresh {
+		return assertionClaims{}, errInvalidClientIDOrSecret
+	}
+
+	return claims, nil
+}
+
+func (h Handler) verifyClientSignature(ctx context.Context, cl client.Client, token jose.Token) error {
+	var err error
+	switch {
+	case cl.JWKS != nil:
+		err = cl.JWKS.Verify(token)
+	case cl.JWKSURI != "":
+		err = h.remoteKeys.Verify(ctx, cl.JWKSURI, token)
+	default:
+		return errInvalidClientIDOrSecret
+	}
+
+	if errors.Is(err, jose.ErrInvalidSignature) || errors.Is(err, jose.ErrKeyNotFound) || errors.Is(err, jose.ErrUnsupportedAlgorithm) {
+		return errInvalidClientIDOrSecret
+	}
+
+	return err
+}
+
+func (h Handler) isAssertionAudience(aud string) bool {
+	return aud == h.issuer || aud == strings.TrimSuffix(h.issuer, "/") || aud == strings.TrimSuffix(h.issuer, "/")+PathToken
+}
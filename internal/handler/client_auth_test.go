@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/session"
+)
+
+func TestPrivateKeyJWT(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate client key: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate client key: %v", err)
+	}
+
+	h := newTestHandler(t, WithClients(client.NewMemoryRegistry(client.Client{
+		ID:                      "signer",
+		TokenEndpointAuthMethod: client.AuthMethodPrivateKeyJWT,
+		JWKS:                    &jose.JWKS{Keys: []jose.JWK{ecJWK(&key.PublicKey)}},
+	})))
+
+	now := time.Now()
+	assertion := func(key *ecdsa.PrivateKey, customize func(claims map[string]interface{})) string {
+		jti, err := session.NewID()
+		if err != nil {
+			t.Fatalf("NewID() = %v", err)
+		}
+
+		claims := map[string]interface{}{
+			"iss": "signer",
+			"sub": "signer",
+			"aud": defaultIssuer + "token",
+			"exp": now.Add(time.Minute).Unix(),
+			"jti": jti,
+		}
+		if customize != nil {
+			customize(claims)
+		}
+		return signJWT(t, key, jose.Header{}, claims)
+	}
+	set := func(name string, value interface{}) func(claims map[string]interface{}) {
+		return func(claims map[string]interface{}) { claims[name] = value }
+	}
+
+	header, _ := json.Marshal(jose.Header{Alg: "none"})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + strings.Split(assertion(key, nil), ".")[1] + "."
+
+	replayed := assertion(key, nil)
+
+	tests := []struct {
+		name      string
+		assertion string
+		wantCode  int
+	}{
+		{name: "valid assertion", assertion: assertion(key, nil), wantCode: http.StatusOK},
+		{name: "issuer as audience", assertion: assertion(key, set("aud", defaultIssuer)), wantCode: http.StatusOK},
+		{name: "audience among others", assertion: assertion(key, set("aud", []string{"https://other.example.com/", defaultIssuer})), wantCode: http.StatusOK},
+		{name: "first use", assertion: replayed, wantCode: http.StatusOK},
+		{name: "replayed jti", assertion: replayed, wantCode: http.StatusUnauthorized},
+		{name: "wrong audience", assertion: assertion(key, set("aud", "https://other.example.com/token")), wantCode: http.StatusUnauthorized},
+		{name: "expired", assertion: assertion(key, set("exp", now.Add(-time.Minute).Unix())), wantCode: http.StatusUnauthorized},
+		{name: "expired within the leeway", assertion: assertion(key, set("exp", now.Add(-10*time.Second).Unix())), wantCode: http.StatusOK},
+		{name: "no exp", assertion: assertion(key, func(claims map[string]interface{}) { delete(claims, "exp") }), wantCode: http.StatusUnauthorized},
+		{name: "lifetime over an hour", assertion: assertion(key, set("exp", now.Add(2*time.Hour).Unix())), wantCode: http.StatusUnauthorized},
+		{name: "not yet valid", assertion: assertion(key, set("nbf", now.Add(time.Minute).Unix())), wantCode: http.StatusUnauthorized},
+		{name: "no jti", assertion: assertion(key, func(claims map[string]interface{}) { delete(claims, "jti") }), wantCode: http.StatusUnauthorized},
+		{name: "issued by another client", assertion: assertion(key, set("iss", "other")), wantCode: http.StatusUnauthorized},
+		{name: "signed with another key", assertion: assertion(otherKey, nil), wantCode: http.StatusUnauthorized},
+		{name: "unsigned", assertion: unsigned, wantCode: http.StatusUnauthorized},
+	}
+
+	// The cases run in order, the replayed assertion after its first use.
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveTokenRequest(h, nil, url.Values{
+				"grant_type":            {client.GrantTypeClientCredentials},
+				"client_assertion_type": {clientAssertionTypeJWTBearer},
+				"client_assertion":      {tt.assertion},
+			})
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/policy"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+)
+
+const (
+	PathAuthorizeDecision = "/authorize/decision"
+)
+
+// errUnknownSubject denies the request rather than rejecting it.
+var errUnknownSubject = errors.New("subject is unknown or its token is invalid or expired")
+
+func (h Handler) AuthorizeDecision() gin.HandlerFunc {
+	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
+		var req decisionRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			return errInvalidRequest
+		}
+
+		cl, err := h.authenticateClient(ctx, req.clientCredentials)
+		if err != nil {
+			return err
+		}
+
+		if cl.Public() {
+			return errUnauthorizedClient
+		}
+
+		if req.Action == "" || req.Resource.ID == "" || (req.Subject.Token == "") == (req.Subject.ID == "") {
+			return errInvalidDecisionRequest
+		}
+
+		pol, err := h.policies.Policy(ctx)
+		if err != nil {
+			return err
+		}
+
+		decision := policy.Decision{Reasons: []string{errUnknownSubject.Error()}}
+
+		subject, err := h.subjectAttributes(ctx, req.Subject)
+		switch {
+		case err == nil:
+			resource := maps.Clone(req.Resource.Attributes)
+			if resource == nil {
+				resource = map[string]interface{}{}
+			}
+			resource["id"] = req.Resource.ID
+			resource["type"] = req.Resource.Type
+
+			decision = pol.Evaluate(policy.Request{
+				Subject:  subject,
+				Action:   req.Action,
+				Resource: resource,
+				Context:  req.Context,
+			})
+		case !errors.Is(err, errUnknownSubject):
+			return err
+		}
+
+		resp := decisionResponse{Decision: policy.EffectDeny, Reasons: decision.Reasons}
+		if decision.Allowed {
+			resp.Decision = policy.EffectAllow
+		}
+
+		ctx.JSON(http.StatusOK, resp)
+		return nil
+	})
+}
+
+// subjectAttributes only returns the attributes the server establishes itself. Facts the caller knows
+// about the request belong in its context.
+func (h Handler) subjectAttributes(ctx context.Context, subject decisionSubject) (map[string]interface{}, error) {
+	attrs := map[string]interface{}{}
+
+	if subject.Token != "" {
+		claims, err := h.verifyAccessToken(ctx, subject.Token)
+		if errors.Is(err, errInvalidToken) {
+			return nil, errUnknownSubject
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		maps.Copy(attrs, claims.Raw)
+		attrs["id"] = claims.Subject
+		attrs["scopes"] = strings.Fields(claims.Scope)
+
+		return attrs, nil
+	}
+
+	if clientID, ok := strings.CutSuffix(subject.ID, "@clients"); ok {
+		cl, err := h.clients.Get(ctx, clientID)
+		if errors.Is(err, client.ErrNotFound) {
+			return nil, errUnknownSubject
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		attrs["id"] = subject.ID
+		attrs["client_id"] = cl.ID
+		attrs["roles"] = cl.Roles
+
+		return attrs, nil
+	}
+
+	u, err := h.users.Get(ctx, subject.ID)
+	if errors.Is(err, user.ErrNotFound) {
+		return nil, errUnknownSubject
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	attrs["id"] = u.ID
+	attrs["username"] = u.Username
+	attrs["email"] = u.Email
+	attrs["roles"] = u.Roles
+	attrs["groups"] = u.Groups
+	attrs["entitlements"] = u.Entitlements
+
+	return attrs, nil
+}
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/device"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/session"
+)
+
+const (
+	PathDeviceAuthorization = "/device_authorization"
+	PathDevice              = "/device"
+
+	deviceCodeTTL        = 10 * time.Minute
+	devicePollInterval   = 5 * time.Second
+	deviceSlowDownFactor = 5 * time.Second
+)
+
+func (h Handler) DeviceAuthorization() gin.HandlerFunc {
+	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
+		var req deviceAuthorizationRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			return errInvalidRequest
+		}
+
+		cl, err := h.authenticateClient(ctx, req.clientCredentials)
+		if err != nil {
+			return err
+		}
+
+		if !cl.AllowsGrantType(client.GrantTypeDeviceCode) {
+			return errUnauthorizedClient
+		}
+
+		scopes := strings.Fields(req.Scope)
+		if !cl.AllowsScopes(scopes) {
+			return errInvalidScope
+		}
+
+		deviceCode, err := session.NewID()
+		if err != nil {
+			return err
+		}
+
+		userCode, err := device.NewUserCode()
+		if err != nil {
+			return err
+		}
+
+		if err := h.devices.Save(ctx, device.Authorization{
+			DeviceCode: deviceCode,
+			UserCode:   userCode,
+			ClientID:   cl.ID,
+			Scopes:     scopes,
+			Status:     device.StatusPending,
+			Interval:   devicePollInterval,
+			ExpiresAt:  time.Now().Add(deviceCodeTTL),
+		}); err != nil {
+			return err
+		}
+
+		verificationURI := strings.TrimSuffix(h.issuer, "/") + PathDevice
+
+		ctx.Header("Cache-Control", "no-store")
+		ctx.JSON(http.StatusOK, deviceAuthorizationResponse{
+			DeviceCode:              deviceCode,
+			UserCode:                userCode,
+			VerificationURI:         verificationURI,
+			VerificationURIComplete: verificationURI + "?" + url.Values{"user_code": {userCode}}.Encode(),
+			ExpiresIn:               int64(deviceCodeTTL.Seconds()),
+			Interval:                int64(devicePollInterval.Seconds()),
+		})
+		return nil
+	})
+}
+
+func (h Handler) DeviceVerification() gin.HandlerFunc {
+	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
+		if _, err := h.currentSession(ctx); errors.Is(err, session.ErrNotFound) {
+			returnTo := &url.URL{Path: strings.TrimPrefix(PathDevice, "/"), RawQuery: ctx.Request.URL.RawQuery}
+			return h.renderLogin(ctx, returnTo, "your device", http.StatusOK, "")
+		} else if err != nil {
+			return err
+		}
+
+		userCode := ctx.Query("user_code")
+		if userCode == "" {
+			return h.renderDevice(ctx, http.StatusOK, devicePage{})
+		}
+
+		auth, err := h.pendingDeviceAuthorization(ctx, userCode)
+		if err != nil {
+			return h.renderDeviceError(ctx, err)
+		}
+
+		cl, err := h.clients.Get(ctx, auth.ClientID)
+		if err != nil {
+			return err
+		}
+
+		return h.renderDevice(ctx, http.StatusOK, devicePage{
+			UserCode:   auth.UserCode,
+			ClientName: clientName(cl),
+			Scopes:     auth.Scopes,
+		})
+	})
+}
+
+func (h Handler) DeviceDecision() gin.HandlerFunc {
+	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
+		if !validCSRFToken(ctx) {
+			return h.renderError(ctx, errInvalidCSRFToken)
+		}
+
+		sess, err := h.currentSession(ctx)
+		if errors.Is(err, session.ErrNotFound) {
+			return h.renderError(ctx, errLoginRequired)
+		}
+		if err != nil {
+			return err
+		}
+
+		pending, err := h.pendingDeviceAuthorization(ctx, ctx.PostForm("user_code"))
+		if err != nil {
+			return h.renderDeviceError(ctx, err)
+		}
+
+		approved := ctx.PostForm("decision") == "allow"
+		_, err = h.devices.Update(ctx, pending.DeviceCode, func(auth *device.Authorization) error {
+			if auth.Status != device.StatusPending || time.Now().After(auth.ExpiresAt) {
+				return device.ErrNotFound
+			}
+
+			auth.Status = device.StatusDenied
+			if approved {
+				auth.Status = device.StatusApproved
+				auth.UserID = sess.UserID
+				auth.AuthTime = sess.AuthTime
+				auth.ACR = sess.ACR
+				auth.AMR = sess.AMR
+			}
+
+			return nil
+		})
+		if err != nil {
+			return h.renderDeviceError(ctx, err)
+		}
+
+		message := "Access denied. You can close this window."
+		if approved {
+			message = "Your device is now connected. You can close this window."
+		}
+
+		return h.renderDevice(ctx, http.StatusOK, devicePage{Message: message})
+	})
+}
+
+func (h Handler) deviceCodeGrant(ctx *gin.Context, cl client.Client, req generateTokenRequest, _ confirmation) (tokenGrant, error) {
+	now := time.Now()
+	var slowDown bool
+	auth, err := h.devices.Update(ctx, req.DeviceCode, func(auth *device.Authorization) error {
+		if auth.ClientID != cl.ID {
+			return errInvalidGrant
+		}
+
+		if auth.Status == device.StatusPending {
+			slowDown = now.Sub(auth.LastPolledAt) < auth.Interval
+			if slowDown {
+				auth.Interval += deviceSlowDownFactor
+			}
+			auth.LastPolledAt = now
+		}
+
+		return nil
+	})
+	if errors.Is(err, device.ErrNotFound) {
+		return tokenGrant{}, errInvalidGrant
+	}
+	if err != nil {
+		return tokenGrant{}, err
+	}
+
+	if now.After(auth.ExpiresAt) {
+		if err := h.devices.Delete(ctx, auth.DeviceCode); err != nil && !errors.Is(err, device.ErrNotFound) {
+			return tokenGrant{}, err
+		}
+
+		return tokenGrant{}, errExpiredToken
+	}
+
+	switch auth.Status {
+	case device.StatusPending:
+		if slowDown {
+			return tokenGrant{}, errSlowDown
+		}
+
+		return tokenGrant{}, errAuthorizationPending
+	case device.StatusApproved:
+		if err := h.devices.Delete(ctx, auth.DeviceCode); errors.Is(err, device.ErrNotFound) {
+			return tokenGrant{}, errInvalidGrant
+		} else if err != nil {
+			return tokenGrant{}, err
+		}
+
+		return tokenGrant{
+			Claims: h.accessTokenClaims(auth.UserID, cl.ID, auth.Scopes),
+			Authentication: &authentication{
+				UserID:   auth.UserID,
+				ClientID: cl.ID,
+				Scopes:   auth.Scopes,
+				AuthTime: auth.AuthTime,
+				ACR:      auth.ACR,
+				AMR:      auth.AMR,
+			},
+		}, nil
+	default:
+		if err := h.devices.Delete(ctx, auth.DeviceCode); err != nil && !errors.Is(err, device.ErrNotFound) {
+			return tokenGrant{}, err
+		}
+
+		return tokenGrant{}, errAccessDenied
+	}
+}
+
+func (h Handler) pendingDeviceAuthorization(ctx *gin.Context, userCode string) (device.Authorization, error) {
+	auth, err := h.devices.GetByUserCode(ctx, userCode)
+	if err != nil {
+		return device.Authorization{}, err
+	}
+
+	if auth.Status != device.StatusPending || time.Now().After(auth.ExpiresAt) {
+		return device.Authorization{}, device.ErrNotFound
+	}
+
+	return auth, nil
+}
+
+func (h Handler) renderDeviceError(ctx *gin.Context, err error) error {
+	if !errors.Is(err, device.ErrNotFound) {
+		return err
+	}
+
+	return h.renderDevice(ctx, http.StatusBadRequest, devicePage{
+		Error: "The code is invalid or has expired. Check the code shown on your device and try again.",
+	})
+}
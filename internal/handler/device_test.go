@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/device"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+)
+
+func TestDeviceCodeGrant(t *testing.T) {
+	h := newTestHandler(t,
+		WithUsers(user.NewMemoryStore(user.User{ID: "u1", Username: "alice"})),
+		WithClients(client.NewMemoryRegistry(
+			client.Client{ID: "tv", Secret: "secret", GrantTypes: []string{client.GrantTypeDeviceCode}},
+			client.Client{ID: "other-tv", Secret: "secret", GrantTypes: []string{client.GrantTypeDeviceCode}},
+		)),
+	)
+
+	approve := func(auth *device.Authorization) {
+		auth.Status, auth.UserID, auth.AuthTime = device.StatusApproved, "u1", time.Now()
+	}
+
+	tests := []struct {
+		name string
+		// decide changes the authorization before the polls, as the user deciding would.
+		decide func(auth *device.Authorization)
+		// polls are made one after the other by clientID, each expecting an error or a token.
+		clientID  string
+		wantPolls []string
+	}{
+		{name: "pending", clientID: "tv", wantPolls: []string{"authorization_pending"}},
+		{name: "polling too fast", clientID: "tv", wantPolls: []string{"authorization_pending", "slow_down", "slow_down"}},
+		{
+			name:      "polling after the interval",
+			decide:    func(auth *device.Authorization) { auth.Interval = 0 },
+			clientID:  "tv",
+			wantPolls: []string{"authorization_pending", "authorization_pending"},
+		},
+		{name: "approved", decide: approve, clientID: "tv", wantPolls: []string{"", "invalid_grant"}},
+		{
+			name:      "denied",
+			decide:    func(auth *device.Authorization) { auth.Status = device.StatusDenied },
+			clientID:  "tv",
+			wantPolls: []string{"access_denied", "invalid_grant"},
+		},
+		{
+			name: "expired",
+			decide: func(auth *device.Authorization) {
+				approve(auth)
+				auth.ExpiresAt = time.Now().Add(-time.Second)
+			},
+			clientID:  "tv",
+			wantPolls: []string{"expired_token", "invalid_grant"},
+		},
+		{name: "code of another client", decide: approve, clientID: "other-tv", wantPolls: []string{"invalid_grant"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveForm(h.DeviceAuthorization(), PathDeviceAuthorization, url.Values{
+				"client_id":     {"tv"},
+				"client_secret": {"secret"},
+			})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("device authorization: status = %d: %s", rec.Code, rec.Body)
+			}
+			var started deviceAuthorizationResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil {
+				t.Fatalf("could not decode device authorization response: %v", err)
+			}
+
+			if tt.decide != nil {
+				_, err := h.devices.Update(context.Background(), started.DeviceCode, func(auth *device.Authorization) error {
+					tt.decide(auth)
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("Update() = %v", err)
+				}
+			}
+
+			for i, want := range tt.wantPolls {
+				rec := serveTokenRequest(h, nil, url.Values{
+					"grant_type":    {client.GrantTypeDeviceCode},
+					"client_id":     {tt.clientID},
+					"client_secret": {"secret"},
+					"device_code":   {started.DeviceCode},
+				})
+
+				if want == "" {
+					if rec.Code != http.StatusOK {
+						t.Fatalf("poll %d: status = %d, want a token: %s", i+1, rec.Code, rec.Body)
+					}
+					if claims := tokenClaims(t, rec); claims.Subject != "u1" {
+						t.Errorf("poll %d: sub = %q, want u1", i+1, claims.Subject)
+					}
+					continue
+				}
+
+				if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"error":"`+want+`"`) {
+					t.Fatalf("poll %d: status = %d, body = %s, want %s", i+1, rec.Code, rec.Body, want)
+				}
+			}
+		})
+	}
+}
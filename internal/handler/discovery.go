@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/authcode"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+)
+
+const (
+	PathToken                    = "/token"
+	PathJWKS                     = "/.well-known/jwks.json"
+	PathAuthorize                = "/authorize"
+	PathLogin                    = "/login"
+	PathConsent                  = "/consent"
+	PathUserInfo                 = "/userinfo"
+	PathOpenIDConfiguration      = "/.well-known/openid-configuration"
+	PathOAuthAuthorizationServer = "/.well-known/oauth-authorization-server"
+)
+
+type discoveryMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	PushedAuthorizationEndpoint       string   `json:"pushed_authorization_request_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	ResponseModesSupported            []string `json:"response_modes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	TokenEndpointAuthSigningAlgs      []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	RevocationEndpointAuthMethods     []string `json:"revocation_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	PromptValuesSupported             []string `json:"prompt_values_supported"`
+	CertificateBoundAccessTokens      bool     `json:"tls_client_certificate_bound_access_tokens"`
+	DPoPSigningAlgValuesSupported     []string `json:"dpop_signing_alg_values_supported"`
+	RequestParameterSupported         bool     `json:"request_parameter_supported"`
+	RequestObjectSigningAlgs          []string `json:"request_object_signing_alg_values_supported"`
+	RequirePushedAuthorization        bool     `json:"require_pushed_authorization_requests"`
+	// RequestURIParameterSupported defaults to true when omitted, so it is always written.
+	RequestURIParameterSupported bool `json:"request_uri_parameter_supported"`
+}
+
+// Discovery derives the metadata from the handler configuration so it only advertises what is served.
+func (h Handler) Discovery() gin.HandlerFunc {
+	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
+		ctx.Header("Cache-Control", "public, max-age=3600")
+		ctx.JSON(http.StatusOK, h.discoveryMetadata())
+		return nil
+	})
+}
+
+func (h Handler) discoveryMetadata() discoveryMetadata {
+	base := strings.TrimSuffix(h.issuer, "/")
+
+	var grantTypes []string
+	for grantType := range h.grantHandlers() {
+		grantTypes = append(grantTypes, grantType)
+	}
+	slices.Sort(grantTypes)
+
+	authMethods := []string{
+		client.AuthMethodClientSecretBasic,
+		client.AuthMethodClientSecretPost,
+		client.AuthMethodPrivateKeyJWT,
+		client.AuthMethodNone,
+	}
+	// Certificates are only presented over TLS, and CA-issued ones can only be verified when the trusted
+	// authorities are configured.
+	if h.clientCerts {
+		authMethods = append(authMethods, client.AuthMethodSelfSignedTLS)
+		if h.clientCAs != nil {
+			authMethods = append(authMethods, client.AuthMethodTLSClientAuth)
+		}
+	}
+
+	return discoveryMetadata{
+		Issuer:                            h.issuer,
+		AuthorizationEndpoint:             base + PathAuthorize,
+		TokenEndpoint:                     base + PathToken,
+		UserInfoEndpoint:                  base + PathUserInfo,
+		JWKSURI:                           base + PathJWKS,
+		DeviceAuthorizationEndpoint:       base + PathDeviceAuthorization,
+		PushedAuthorizationEndpoint:       base + PathPushedAuthorizationRequest,
+		RevocationEndpoint:                base + PathRevoke,
+		ScopesSupported:                   []string{scopeOpenID, scopeProfile, scopeEmail},
+		ResponseTypesSupported:            []string{responseTypeCode, responseTypeCodeIDToken},
+		ResponseModesSupported:            []string{responseModeQuery, responseModeFragment},
+		GrantTypesSupported:               grantTypes,
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{h.srv.Algo()},
+		TokenEndpointAuthMethodsSupported: authMethods,
+		TokenEndpointAuthSigningAlgs:      jose.SupportedAlgorithms,
+		RevocationEndpointAuthMethods:     authMethods,
+		CodeChallengeMethodsSupported:     []string{authcode.ChallengeMethodS256, authcode.ChallengeMethodPlain},
+		ClaimsSupported:                   claimsSupported,
+		PromptValuesSupported:             []string{promptNone, promptLogin, promptConsent},
+		CertificateBoundAccessTokens:      h.clientCerts,
+		DPoPSigningAlgValuesSupported:     h.dpop.Algorithms(),
+		RequestParameterSupported:         true,
+		RequestObjectSigningAlgs:          jose.SupportedAlgorithms,
+		// Only the clients registering require_pushed_authorization_requests must push their requests.
+		RequirePushedAuthorization: false,
+	}
+}
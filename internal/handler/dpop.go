@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/pkg/dpop"
+)
+
+// dpopBinding requires a proof from clients registered for DPoP-bound tokens.
+func (h Handler) dpopBinding(ctx *gin.Context, cl client.Client) (string, error) {
+	if len(ctx.Request.Header.Values(dpop.HeaderName)) == 0 {
+		if cl.DPoPBoundAccessTokens {
+			return "", errInvalidDPoPProof
+		}
+
+		return "", nil
+	}
+
+	proof, err := dpop.ProofHeader(ctx.Request.Header)
+	if err != nil {
+		return "", errInvalidDPoPProof
+	}
+
+	p, err := h.dpop.Verify(ctx, proof, ctx.Request.Method, strings.TrimSuffix(h.issuer, "/")+PathToken, "")
+	switch {
+	case errors.Is(err, dpop.ErrUseNonce):
+		if err := h.setDPoPNonce(ctx); err != nil {
+			return "", err
+		}
+
+		return "", errUseDPoPNonce
+	case errors.Is(err, dpop.ErrInvalidProof):
+		return "", errInvalidDPoPProof
+	case err != nil:
+		return "", err
+	}
+
+	return p.Thumbprint, nil
+}
+
+// verifyProofOfPossession checks that a DPoP- or certificate-bound access token is presented by its holder.
+func (h Handler) verifyProofOfPossession(ctx *gin.Context, path, scheme, token string, claims accessTokenClaimSet) error {
+	if claims.Cnf.JKT != "" {
+		if scheme != dpop.TokenType {
+			return errInvalidToken
+		}
+
+		proof, err := dpop.ProofHeader(ctx.Request.Header)
+		if err != nil {
+			return errInvalidToken
+		}
+
+		p, err := h.dpop.Verify(ctx, proof, ctx.Request.Method, strings.TrimSuffix(h.issuer, "/")+path, token)
+		switch {
+		case errors.Is(err, dpop.ErrUseNonce):
+			if err := h.setDPoPNonce(ctx); err != nil {
+				return err
+			}
+
+			return errDPoPNonceRequired
+		case errors.Is(err, dpop.ErrInvalidProof):
+			return errInvalidToken
+		case err != nil:
+			return err
+		}
+
+		if p.Thumbprint != claims.Cnf.JKT {
+			return errInvalidToken
+		}
+	}
+
+	return verifyCertificateBinding(ctx, claims)
+}
+
+func (h Handler) setDPoPNonce(ctx *gin.Context) error {
+	nonce, err := h.dpop.Nonce(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx.Header(dpop.NonceHeaderName, nonce)
+	return nil
+}
@@ -1,10 +1,93 @@
 package handler
 
+type clientCredentials struct {
+	ClientID            string `json:"client_id" form:"client_id"`
+	ClientSecret        string `json:"client_secret" form:"client_secret"`
+	ClientAssertionType string `json:"client_assertion_type" form:"client_assertion_type"`
+	ClientAssertion     string `json:"client_assertion" form:"client_assertion"`
+}
+
 type generateTokenRequest struct {
-	ClientID     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
+	clientCredentials
+
+	GrantType    string `json:"grant_type" form:"grant_type"`
+	Scope        string `json:"scope" form:"scope"`
+	Code         string `json:"code" form:"code"`
+	RedirectURI  string `json:"redirect_uri" form:"redirect_uri"`
+	CodeVerifier string `json:"code_verifier" form:"code_verifier"`
+	DeviceCode   string `json:"device_code" form:"device_code"`
+	Assertion    string `json:"assertion" form:"assertion"`
+	Username     string `json:"username" form:"username"`
+	Password     string `json:"password" form:"password"`
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+
+	SubjectToken       string   `json:"subject_token" form:"subject_token"`
+	SubjectTokenType   string   `json:"subject_token_type" form:"subject_token_type"`
+	ActorToken         string   `json:"actor_token" form:"actor_token"`
+	ActorTokenType     string   `json:"actor_token_type" form:"actor_token_type"`
+	RequestedTokenType string   `json:"requested_token_type" form:"requested_token_type"`
+	Audience           []string `json:"audience" form:"audience"`
+	Resource           []string `json:"resource" form:"resource"`
 }
 
 type generateTokenResponse struct {
-	AccessToken string `json:"access_token"`
+	AccessToken     string `json:"access_token"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Scope           string `json:"scope,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
+}
+
+type revocationRequest struct {
+	clientCredentials
+
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+}
+
+type deviceAuthorizationRequest struct {
+	clientCredentials
+
+	Scope string `json:"scope" form:"scope"`
+}
+
+type deviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+type pushedAuthorizationResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int64  `json:"expires_in"`
+}
+
+type decisionRequest struct {
+	clientCredentials
+
+	Subject  decisionSubject        `json:"subject"`
+	Action   string                 `json:"action"`
+	Resource decisionResource       `json:"resource"`
+	Context  map[string]interface{} `json:"context"`
+}
+
+type decisionSubject struct {
+	Token string `json:"token"`
+	ID    string `json:"id"`
+}
+
+type decisionResource struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+type decisionResponse struct {
+	Decision string   `json:"decision"`
+	Reasons  []string `json:"reasons"`
 }
@@ -7,5 +7,39 @@ import (
 )
 
 var (
-	errInvalidClientIDOrSecret = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid client id or secret"}
+	errInvalidClientIDOrSecret   = &httpserver.HTTPError{Code: http.StatusUnauthorized, Message: "invalid_client", Detail: "invalid client id or secret"}
+	errMultipleClientAuthMethods = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_request", Detail: "client must use exactly one authentication method"}
+	errInvalidRequest            = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_request", Detail: "malformed token request"}
+	errUnauthorizedClient        = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "unauthorized_client", Detail: "client is not allowed to use this grant type"}
+	errUnsupportedGrantType      = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "unsupported_grant_type", Detail: "grant type is not supported"}
+	errInvalidGrant              = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_grant", Detail: "authorization grant is invalid, expired or was issued to another client"}
+	errAccountLocked             = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_grant", Detail: "account is temporarily locked after too many failed attempts"}
+	errAuthorizationPending      = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "authorization_pending", Detail: "user has not yet completed the authorization"}
+	errSlowDown                  = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "slow_down", Detail: "polling too frequently, increase the interval by 5 seconds"}
+	errExpiredToken              = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "expired_token", Detail: "device code has expired"}
+	errAccessDenied              = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "access_denied", Detail: "user denied the authorization request"}
+	errInvalidTarget             = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_target", Detail: "exactly one audience the client may exchange tokens to is required"}
+	errInvalidSubjectToken       = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_request", Detail: "subject token is invalid or expired"}
+	errInvalidActorToken         = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_request", Detail: "actor token is invalid, expired or may not act for the subject"}
+	errUnsupportedTokenType      = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_request", Detail: "token type is not supported"}
+	errInvalidDPoPProof          = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_dpop_proof", Detail: "DPoP proof is invalid, expired or was already used"}
+	errUseDPoPNonce              = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "use_dpop_nonce", Detail: "DPoP proof must contain the nonce of the DPoP-Nonce header"}
+	errTokenIssuanceDenied       = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "access_denied", Detail: "token issuance was denied"}
+	errTokenHookUnavailable      = &httpserver.HTTPError{Code: http.StatusServiceUnavailable, Message: "temporarily_unavailable", Detail: "token cannot be issued right now, try again later"}
+	errInvalidScope              = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_scope", Detail: "requested scope is not allowed for this client"}
+	errRevokeForeignToken        = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "unauthorized_client", Detail: "token was issued to another client"}
+	errUnsupportedRevocation     = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "unsupported_token_type", Detail: "token cannot be revoked"}
+
+	errInvalidDecisionRequest = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_request", Detail: "decision requires an action, a resource id and either a subject token or a subject id"}
+	errInvalidToken           = &httpserver.HTTPError{Code: http.StatusUnauthorized, Message: "invalid_token", Detail: "access token is invalid or expired"}
+	errDPoPNonceRequired      = &httpserver.HTTPError{Code: http.StatusUnauthorized, Message: "use_dpop_nonce", Detail: "DPoP proof must contain the nonce of the DPoP-Nonce header"}
+	errInsufficientScope      = &httpserver.HTTPError{Code: http.StatusForbidden, Message: "insufficient_scope", Detail: "access token does not grant the openid scope"}
+
+	errUnknownClient               = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_request", Detail: "unknown client"}
+	errInvalidRedirectURI          = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_request", Detail: "redirect uri is not registered for this client"}
+	errInvalidRequestURI           = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_request_uri", Detail: "request uri is invalid, expired or was already used"}
+	errInvalidRequestObject        = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_request_object", Detail: "request object is invalid, expired or not signed by the client"}
+	errPushedAuthorizationRequired = &httpserver.HTTPError{Code: http.StatusBadRequest, Message: "invalid_request", Detail: "client must push authorization requests"}
+	errInvalidCSRFToken            = &httpserver.HTTPError{Code: http.StatusForbidden, Message: "invalid_request", Detail: "form expired, please try again"}
+	errLoginRequired               = &httpserver.HTTPError{Code: http.StatusUnauthorized, Message: "login_required", Detail: "user is not signed in"}
 )
@@ -1,73 +1,216 @@
 package handler
 
 import (
+	"context"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/audit"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/authcode"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/consent"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/device"
 	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/par"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/policy"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/rbac"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/refresh"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/replay"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/resource"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/revocation"
 	"github.com/the-witcher-knight/jwt-encryption-server/internal/service"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/session"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/tracing"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+	"github.com/the-witcher-knight/jwt-encryption-server/pkg/dpop"
+	"github.com/the-witcher-knight/jwt-encryption-server/pkg/tokenhook"
 )
 
 const (
-	clientID     string = "sample-client-id"
-	clientSecret string = "sample-client-secret"
-)
+	defaultIssuer         = "http://localhost:8080/"
+	defaultAudience       = "http://localhost:9999/"
+	defaultAccessTokenTTL = 24 * time.Hour
 
-var (
-	payload = map[string]interface{}{
-		"iss": "http://localhost:8080/",
-		"sub": "sample_subject@clients",
-		"aud": "http://localhost:9999/",
-		"iat": 1717176856,
-		"exp": 1717263256,
-		"gty": "client-credentials",
-		"azp": "sample_subject",
-	}
+	authorizationCodeTTL = time.Minute
+	refreshTokenTTL      = 30 * 24 * time.Hour
+	remoteKeysTimeout    = 5 * time.Second
+	remoteKeysTTL        = 5 * time.Minute
+	sessionTTL           = 12 * time.Hour
 )
 
+type tokenGrant struct {
+	GrantType       string
+	Claims          map[string]interface{}
+	Authentication  *authentication
+	IssuedTokenType string
+	// Refreshed is the refresh token a refresh grant used up.
+	Refreshed *refresh.Token
+}
+
 type Handler struct {
 	srv service.SignatureService
+
+	issuer         string
+	audience       string
+	accessTokenTTL time.Duration
+
+	clients        client.Registry
+	resources      resource.Registry
+	roles          rbac.Store
+	policies       policy.Source
+	users          user.Store
+	sessions       session.Store
+	consents       consent.Store
+	codes          authcode.Store
+	devices        device.Store
+	pushedRequests par.Store
+	replays        replay.Cache
+	refreshTokens  refresh.Store
+	revocations    revocation.List
+	audit          audit.Recorder
+	tokenHook      tokenhook.Hook
+
+	remoteKeys  *jose.RemoteKeySets
+	clientCerts bool
+	clientCAs   *x509.CertPool
+	dpopNonces  dpop.NonceSource
+	dpop        *dpop.Verifier
+
+	metrics      *Metrics
+	keyCreatedAt time.Time
 }
 
-func New(srv service.SignatureService) Handler {
-	return Handler{
-		srv: srv,
+func New(srv service.SignatureService, opts ...Option) Handler {
+	h := Handler{
+		srv:            srv,
+		issuer:         defaultIssuer,
+		audience:       defaultAudience,
+		accessTokenTTL: defaultAccessTokenTTL,
+		clients:        client.NewMemoryRegistry(),
+		resources:      resource.NewMemoryRegistry(),
+		roles:          rbac.NewMemoryStore(),
+		policies:       policy.NewStaticSource(policy.Policy{}),
+		users:          user.NewMemoryStore(),
+		audit:          audit.NewWriterRecorder(io.Discard),
+		remoteKeys:     jose.NewRemoteKeySets(&http.Client{Timeout: remoteKeysTimeout, Transport: tracing.NewTransport(nil)}, remoteKeysTTL),
 	}
+
+	// Sessions, codes and the other state of the flows are kept in process memory unless shared storage is
+	// configured.
+	opts = append([]Option{WithStorage(storage.NewMemoryStore())}, opts...)
+	for _, opt := range opts {
+		opt(&h)
+	}
+
+	var dpopOpts []dpop.Option
+	if h.dpopNonces != nil {
+		dpopOpts = append(dpopOpts, dpop.WithNonces(h.dpopNonces))
+	}
+	h.dpop = dpop.NewVerifier(h.replays, dpopOpts...)
+
+	if !h.keyCreatedAt.IsZero() {
+		h.metrics.addSigningKey(h.issuer, h.srv.Algo(), h.keyCreatedAt)
+	}
+
+	return h
 }
 
 func (h Handler) GenerateToken() gin.HandlerFunc {
 	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
 		var req generateTokenRequest
-		if err := ctx.BindJSON(&req); err != nil {
+		if err := ctx.ShouldBind(&req); err != nil {
+			return errInvalidRequest
+		}
+
+		if req.GrantType == "" {
+			req.GrantType = client.GrantTypeClientCredentials
+		}
+
+		cl, err := h.authenticateClient(ctx, req.clientCredentials)
+		if err != nil {
 			return err
 		}
 
-		if req.ClientID != clientID || req.ClientSecret != clientSecret {
-			return errInvalidClientIDOrSecret
+		if !cl.AllowsGrantType(req.GrantType) {
+			return errUnauthorizedClient
+		}
+
+		grantFn, ok := h.grantHandlers()[req.GrantType]
+		if !ok {
+			return errUnsupportedGrantType
 		}
 
-		payloadStr, err := json.Marshal(payload)
+		// The proofs binding the token are checked before the grant, which may use up a one-time code or
+		// assertion: a client retrying with a DPoP nonce can still redeem it.
+		var cnf confirmation
+		if cnf.X5TS256, err = certificateBinding(ctx, cl); err != nil {
+			return err
+		}
+		if cnf.JKT, err = h.dpopBinding(ctx, cl); err != nil {
+			return err
+		}
+
+		grant, err := grantFn(ctx, cl, req, cnf)
 		if err != nil {
-			return fmt.Errorf("could not marshal payload: %w", err)
+			return err
+		}
+		grant.GrantType = req.GrantType
+
+		if cnf != (confirmation{}) {
+			grant.Claims["cnf"] = cnf
 		}
 
-		token, err := h.srv.GenerateToken(payloadStr)
+		token, err := h.signAccessToken(ctx, grant)
 		if err != nil {
 			return err
 		}
+		h.metrics.countTokenIssued(h.issuer, cl.ID, req.GrantType, h.srv.Algo())
 
-		ctx.JSON(http.StatusOK, generateTokenResponse{
-			AccessToken: token,
-		})
+		var refreshToken string
+		if grant.Authentication != nil && cl.AllowsGrantType(client.GrantTypeRefreshToken) {
+			if refreshToken, err = h.issueRefreshToken(ctx, cl, grant, cnf); err != nil {
+				return err
+			}
+		}
+
+		resp := generateTokenResponse{
+			AccessToken:     token,
+			RefreshToken:    refreshToken,
+			IssuedTokenType: grant.IssuedTokenType,
+			TokenType:       "Bearer",
+			ExpiresIn:       grant.Claims["exp"].(int64) - grant.Claims["iat"].(int64),
+		}
+		if cnf.JKT != "" {
+			resp.TokenType = dpop.TokenType
+		}
+		resp.Scope, _ = grant.Claims["scope"].(string)
+
+		if grant.Authentication != nil && slices.Contains(grant.Authentication.Scopes, scopeOpenID) {
+			if resp.IDToken, err = h.generateIDToken(ctx, *grant.Authentication, token, ""); err != nil {
+				return err
+			}
+		}
+
+		ctx.JSON(http.StatusOK, resp)
 		return nil
 	})
 }
 
 func (h Handler) GetJWKs() gin.HandlerFunc {
 	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
+		h.metrics.countJWKSRequest(h.issuer)
+
 		jwks, err := h.srv.GetJWKs()
 		if err != nil {
 			return err
@@ -77,3 +220,94 @@ func (h Handler) GetJWKs() gin.HandlerFunc {
 		return nil
 	})
 }
+
+// cnf binds the token to the certificate or DPoP key the client proved it holds.
+type grantHandler func(ctx *gin.Context, cl client.Client, req generateTokenRequest, cnf confirmation) (tokenGrant, error)
+
+// The grant types advertised by Discovery are derived from grantHandlers.
+func (h Handler) grantHandlers() map[string]grantHandler {
+	return map[string]grantHandler{
+		client.GrantTypeClientCredentials: h.clientCredentialsGrant,
+		client.GrantTypeAuthorizationCode: h.authorizationCodeGrant,
+		client.GrantTypeDeviceCode:        h.deviceCodeGrant,
+		client.GrantTypeTokenExchange:     h.tokenExchangeGrant,
+		client.GrantTypeJWTBearer:         h.jwtBearerGrant,
+		client.GrantTypePassword:          h.passwordGrant,
+		client.GrantTypeRefreshToken:      h.refreshTokenGrant,
+	}
+}
+
+func (h Handler) clientCredentialsGrant(_ *gin.Context, cl client.Client, req generateTokenRequest, _ confirmation) (tokenGrant, error) {
+	if cl.Public() {
+		return tokenGrant{}, errUnauthorizedClient
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if !cl.AllowsScopes(scopes) {
+		return tokenGrant{}, errInvalidScope
+	}
+
+	claims := h.accessTokenClaims(cl.ID+"@clients", cl.ID, scopes)
+	claims["gty"] = "client-credentials"
+
+	return tokenGrant{Claims: claims}, nil
+}
+
+func (h Handler) authorizationCodeGrant(ctx *gin.Context, cl client.Client, req generateTokenRequest, _ confirmation) (tokenGrant, error) {
+	code, err := h.codes.Consume(ctx, req.Code)
+	if errors.Is(err, authcode.ErrNotFound) {
+		return tokenGrant{}, errInvalidGrant
+	}
+	if err != nil {
+		return tokenGrant{}, err
+	}
+
+	if code.ClientID != cl.ID || code.RedirectURI != req.RedirectURI || !code.VerifyChallenge(req.CodeVerifier) {
+		return tokenGrant{}, errInvalidGrant
+	}
+
+	return tokenGrant{
+		Claims: h.accessTokenClaims(code.UserID, cl.ID, code.Scopes),
+		Authentication: &authentication{
+			UserID:   code.UserID,
+			ClientID: cl.ID,
+			Scopes:   code.Scopes,
+			Nonce:    code.Nonce,
+			AuthTime: code.AuthTime,
+			ACR:      code.ACR,
+			AMR:      code.AMR,
+		},
+	}, nil
+}
+
+func (h Handler) accessTokenClaims(subject, clientID string, scopes []string) map[string]interface{} {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": h.issuer,
+		"sub": subject,
+		"aud": h.audience,
+		"iat": now.Unix(),
+		"exp": now.Add(h.accessTokenTTL).Unix(),
+		"azp": clientID,
+	}
+
+	if len(scopes) > 0 {
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+
+	return claims
+}
+
+// signClaims leaves the typ header out when typ is empty.
+func (h Handler) signClaims(ctx context.Context, typ string, claims map[string]interface{}) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal payload: %w", err)
+	}
+
+	_, span := tracing.StartSpan(ctx, "jws.sign", tracing.String("jws.alg", h.srv.Algo()))
+	defer span.End()
+	defer h.metrics.observeSigning(h.issuer, h.srv.Algo(), time.Now())
+
+	return h.srv.GenerateTypedToken(typ, payload)
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/metrics"
+)
+
+// Reasons of the authentication failures counted by the metrics.
+const (
+	failureUnknownClient         = "unknown_client"
+	failureAuthMethodNotAllowed  = "auth_method_not_allowed"
+	failureInvalidClientSecret   = "invalid_client_secret"
+	failureInvalidAssertion      = "invalid_client_assertion"
+	failureInvalidCertificate    = "invalid_client_certificate"
+	failureInvalidUserCredential = "invalid_credentials"
+	failureUserLocked            = "locked"
+)
+
+// Metrics are shared by the handlers of several issuers, with the issuer as label.
+type Metrics struct {
+	tokensIssued   *metrics.Counter
+	authFailures   *metrics.Counter
+	signingLatency *metrics.Histogram
+	jwksRequests   *metrics.Counter
+
+	mu   sync.Mutex
+	keys map[signingKey]time.Time
+}
+
+type signingKey struct {
+	issuer string
+	alg    string
+}
+
+func NewMetrics(reg *metrics.Registry) *Metrics {
+	m := &Metrics{
+		tokensIssued: reg.NewCounter("serverd_tokens_issued_total",
+			"Access tokens issued.", "issuer", "client_id", "grant_type", "alg"),
+		authFailures: reg.NewCounter("serverd_auth_failures_total",
+			"Failed client and user authentications.", "issuer", "reason"),
+		signingLatency: reg.NewHistogram("serverd_token_signing_duration_seconds",
+			"Time spent signing tokens.", metrics.DefaultBuckets, "issuer", "alg"),
+		jwksRequests: reg.NewCounter("serverd_jwks_requests_total",
+			"Requests for the JSON Web Key Set.", "issuer"),
+		keys: map[signingKey]time.Time{},
+	}
+
+	reg.NewGaugeFunc("serverd_signing_key_age_seconds", "Age of the signing keys.", []string{"issuer", "alg"},
+		func(emit func(float64, ...string)) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			for key, createdAt := range m.keys {
+				emit(time.Since(createdAt).Seconds(), key.issuer, key.alg)
+			}
+		})
+
+	return m
+}
+
+// The methods recording metrics do nothing on nil Metrics, for handlers without metrics.
+
+func (m *Metrics) addSigningKey(issuer, alg string, createdAt time.Time) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys[signingKey{issuer: issuer, alg: alg}] = createdAt
+}
+
+func (m *Metrics) countTokenIssued(issuer, clientID, grantType, alg string) {
+	if m != nil {
+		m.tokensIssued.Inc(issuer, clientID, grantType, alg)
+	}
+}
+
+func (m *Metrics) countAuthFailure(issuer, reason string) {
+	if m != nil {
+		m.authFailures.Inc(issuer, reason)
+	}
+}
+
+func (m *Metrics) observeSigning(issuer, alg string, start time.Time) {
+	if m != nil {
+		m.signingLatency.ObserveSince(start, issuer, alg)
+	}
+}
+
+func (m *Metrics) countJWKSRequest(issuer string) {
+	if m != nil {
+		m.jwksRequests.Inc(issuer)
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	m := NewMetrics(reg)
+
+	// Handlers of several issuers share the metrics.
+	clients := WithClients(client.NewMemoryRegistry(
+		client.Client{ID: "app", Secret: "secret", GrantTypes: []string{client.GrantTypeClientCredentials}},
+	))
+	h := newTestHandler(t, clients, WithMetrics(m), WithSigningKeyCreatedAt(time.Now().Add(-time.Hour)))
+	tenantHandler := newTestHandler(t, clients, WithMetrics(m), WithIssuer("http://localhost:8080/t/acme/"))
+
+	for _, secret := range []string{"secret", "secret", "wrong"} {
+		serveTokenRequest(h, nil, url.Values{
+			"grant_type":    {client.GrantTypeClientCredentials},
+			"client_id":     {"app"},
+			"client_secret": {secret},
+		})
+	}
+	serveTokenRequest(tenantHandler, nil, url.Values{
+		"grant_type":    {client.GrantTypeClientCredentials},
+		"client_id":     {"unknown"},
+		"client_secret": {"secret"},
+	})
+
+	router := gin.New()
+	router.GET(PathJWKS, h.GetJWKs())
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, PathJWKS, nil))
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	exposition := rec.Body.String()
+
+	for _, want := range []string{
+		`serverd_tokens_issued_total{issuer="http://localhost:8080/",client_id="app",grant_type="client_credentials",alg="RS256"} 2`,
+		`serverd_auth_failures_total{issuer="http://localhost:8080/",reason="invalid_client_secret"} 1`,
+		`serverd_auth_failures_total{issuer="http://localhost:8080/t/acme/",reason="unknown_client"} 1`,
+		`serverd_jwks_requests_total{issuer="http://localhost:8080/"} 1`,
+		`serverd_token_signing_duration_seconds_count{issuer="http://localhost:8080/",alg="RS256"} 2`,
+	} {
+		if !strings.Contains(exposition, want+"\n") {
+			t.Errorf("exposition lacks %s:\n%s", want, exposition)
+		}
+	}
+
+	// Only the keys with a known creation time have an age.
+	age := regexp.MustCompile(`(?m)^serverd_signing_key_age_seconds\{issuer="([^"]*)",alg="RS256"\} (\S+)$`).FindAllStringSubmatch(exposition, -1)
+	if len(age) != 1 || age[0][1] != "http://localhost:8080/" {
+		t.Fatalf("signing key ages = %v, want the key of the default issuer", age)
+	}
+	if seconds, err := strconv.ParseFloat(age[0][2], 64); err != nil || seconds < 3600 || seconds > 3660 {
+		t.Errorf("signing key age = %s, want about an hour", age[0][2])
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+)
+
+// CA-issued certificates must chain to a trusted authority and be issued to the registered subject,
+// self-signed certificates must be registered in the client key set.
+func (h Handler) verifyClientCertificate(ctx *gin.Context, cl client.Client, method string) error {
+	cert := clientCertificate(ctx)
+	if cert == nil {
+		return errInvalidClientIDOrSecret
+	}
+
+	switch method {
+	case client.AuthMethodTLSClientAuth:
+		if h.clientCAs == nil {
+			return errInvalidClientIDOrSecret
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, c := range ctx.Request.TLS.PeerCertificates[1:] {
+			intermediates.AddCert(c)
+		}
+
+		if _, err := cert.Verify(x509.VerifyOptions{
+			Roots:         h.clientCAs,
+			Intermediates: intermediates,
+			CurrentTime:   time.Now(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}); err != nil {
+			return errInvalidClientIDOrSecret
+		}
+
+		if !cl.TLSClientAuth.Matches(cert) {
+			return errInvalidClientIDOrSecret
+		}
+	case client.AuthMethodSelfSignedTLS:
+		now := time.Now()
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			return errInvalidClientIDOrSecret
+		}
+
+		registered, err := h.registeredCertificate(ctx, cl, cert)
+		if err != nil {
+			return err
+		}
+		if !registered {
+			return errInvalidClientIDOrSecret
+		}
+	default:
+		return errInvalidClientIDOrSecret
+	}
+
+	return nil
+}
+
+// registeredCertificate matches the first x5c entry of each key the client registered.
+func (h Handler) registeredCertificate(ctx context.Context, cl client.Client, cert *x509.Certificate) (bool, error) {
+	var jwks jose.JWKS
+	switch {
+	case cl.JWKS != nil:
+		jwks = *cl.JWKS
+	case cl.JWKSURI != "":
+		var err error
+		if jwks, err = h.remoteKeys.Get(ctx, cl.JWKSURI); err != nil {
+			return false, err
+		}
+	default:
+		return false, nil
+	}
+
+	for _, key := range jwks.Keys {
+		if len(key.X5c) == 0 {
+			continue
+		}
+
+		der, err := base64.StdEncoding.DecodeString(key.X5c[0])
+		if err == nil && bytes.Equal(der, cert.Raw) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// certificateBinding binds the token when the client authenticated with a certificate or asked for bound tokens.
+func certificateBinding(ctx *gin.Context, cl client.Client) (string, error) {
+	if !cl.CertificateBoundAccessTokens && !isTLSAuthMethod(cl.TokenEndpointAuthMethod) {
+		return "", nil
+	}
+
+	cert := clientCertificate(ctx)
+	if cert == nil {
+		return "", errInvalidRequest
+	}
+
+	return certificateThumbprint(cert), nil
+}
+
+func verifyCertificateBinding(ctx *gin.Context, claims accessTokenClaimSet) error {
+	if claims.Cnf.X5TS256 == "" {
+		return nil
+	}
+
+	cert := clientCertificate(ctx)
+	if cert == nil || certificateThumbprint(cert) != claims.Cnf.X5TS256 {
+		return errInvalidToken
+	}
+
+	return nil
+}
+
+func clientCertificate(ctx *gin.Context) *x509.Certificate {
+	if ctx.Request.TLS == nil || len(ctx.Request.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+
+	return ctx.Request.TLS.PeerCertificates[0]
+}
+
+func certificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func isTLSAuthMethod(method string) bool {
+	return method == client.AuthMethodTLSClientAuth || method == client.AuthMethodSelfSignedTLS
+}
@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/service"
+)
+
+func TestMutualTLSClientAuthentication(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test CA")
+	otherCA, otherCAKey := newTestCA(t, "Other CA")
+
+	partner := issueTestCertificate(t, ca, caKey, func(c *x509.Certificate) { c.DNSNames = []string{"partner.example.com"} })
+	stranger := issueTestCertificate(t, ca, caKey, func(c *x509.Certificate) { c.DNSNames = []string{"stranger.example.com"} })
+	untrusted := issueTestCertificate(t, otherCA, otherCAKey, func(c *x509.Certificate) { c.DNSNames = []string{"partner.example.com"} })
+	expired := issueTestCertificate(t, ca, caKey, func(c *x509.Certificate) {
+		c.DNSNames = []string{"partner.example.com"}
+		c.NotAfter = time.Now().Add(-time.Hour)
+	})
+	selfSigned := issueTestCertificate(t, nil, nil, nil)
+	unregistered := issueTestCertificate(t, nil, nil, nil)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	h := newTestHandler(t,
+		WithClientCertificates(),
+		WithClientCAs(clientCAs),
+		WithClients(client.NewMemoryRegistry(
+			client.Client{
+				ID:                      "partner",
+				TokenEndpointAuthMethod: client.AuthMethodTLSClientAuth,
+				TLSClientAuth:           client.TLSClientAuth{SANDNS: "partner.example.com"},
+			},
+			client.Client{
+				ID:                      "device",
+				TokenEndpointAuthMethod: client.AuthMethodSelfSignedTLS,
+				JWKS: &jose.JWKS{Keys: []jose.JWK{{
+					Kty: "EC",
+					X5c: []string{base64.StdEncoding.EncodeToString(selfSigned.Raw)},
+				}}},
+			},
+		)),
+	)
+
+	tests := []struct {
+		name     string
+		clientID string
+		certs    []*x509.Certificate
+		wantCode int
+	}{
+		{name: "CA-issued certificate of the subject", clientID: "partner", certs: []*x509.Certificate{partner}, wantCode: http.StatusOK},
+		{name: "CA-issued certificate of another subject", clientID: "partner", certs: []*x509.Certificate{stranger}, wantCode: http.StatusUnauthorized},
+		{name: "certificate of an untrusted CA", clientID: "partner", certs: []*x509.Certificate{untrusted}, wantCode: http.StatusUnauthorized},
+		{name: "expired certificate", clientID: "partner", certs: []*x509.Certificate{expired}, wantCode: http.StatusUnauthorized},
+		{name: "no certificate", clientID: "partner", wantCode: http.StatusUnauthorized},
+		{name: "registered self-signed certificate", clientID: "device", certs: []*x509.Certificate{selfSigned}, wantCode: http.StatusOK},
+		{name: "unregistered self-signed certificate", clientID: "device", certs: []*x509.Certificate{unregistered}, wantCode: http.StatusUnauthorized},
+		{name: "CA-issued certificate for a self-signed client", clientID: "device", certs: []*x509.Certificate{partner}, wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveTokenRequest(h, tt.certs, url.Values{
+				"grant_type": {client.GrantTypeClientCredentials},
+				"client_id":  {tt.clientID},
+			})
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			// The token is bound to the certificate the client authenticated with.
+			claims := tokenClaims(t, rec)
+			if want := certificateThumbprint(tt.certs[0]); claims.Cnf.X5TS256 != want {
+				t.Errorf("cnf x5t#S256 = %q, want %q", claims.Cnf.X5TS256, want)
+			}
+		})
+	}
+}
+
+func TestCertificateBoundAccessTokens(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test CA")
+	holder := issueTestCertificate(t, ca, caKey, func(c *x509.Certificate) { c.DNSNames = []string{"partner.example.com"} })
+	other := issueTestCertificate(t, ca, caKey, func(c *x509.Certificate) { c.DNSNames = []string{"partner.example.com"} })
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	h := newTestHandler(t,
+		WithClientCertificates(),
+		WithClientCAs(clientCAs),
+		WithClients(client.NewMemoryRegistry(client.Client{
+			ID:                      "partner",
+			TokenEndpointAuthMethod: client.AuthMethodTLSClientAuth,
+			TLSClientAuth:           client.TLSClientAuth{SANDNS: "partner.example.com"},
+		})),
+	)
+
+	rec := serveTokenRequest(h, []*x509.Certificate{holder}, url.Values{
+		"grant_type": {client.GrantTypeClientCredentials},
+		"client_id":  {"partner"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	var resp generateTokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("could not decode token response: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		certs   []*x509.Certificate
+		wantErr bool
+	}{
+		{name: "certificate the token is bound to", certs: []*x509.Certificate{holder}},
+		{name: "another certificate", certs: []*x509.Certificate{other}, wantErr: true},
+		{name: "no certificate", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodGet, PathUserInfo, nil)
+			ctx.Request.TLS = connectionState(tt.certs)
+
+			claims, err := h.verifyAccessToken(ctx, resp.AccessToken)
+			if err != nil {
+				t.Fatalf("verifyAccessToken() = %v", err)
+			}
+
+			err = h.verifyProofOfPossession(ctx, PathUserInfo, "Bearer", resp.AccessToken, claims)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyProofOfPossession() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func newTestHandler(t *testing.T, opts ...Option) Handler {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate signing key: %v", err)
+	}
+
+	srv, err := service.NewRSASignatureService(key)
+	if err != nil {
+		t.Fatalf("could not create signature service: %v", err)
+	}
+
+	return New(srv, opts...)
+}
+
+func serveTokenRequest(h Handler, certs []*x509.Certificate, form url.Values) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST(PathToken, h.GenerateToken())
+
+	req := httptest.NewRequest(http.MethodPost, PathToken, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.TLS = connectionState(certs)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func connectionState(certs []*x509.Certificate) *tls.ConnectionState {
+	return &tls.ConnectionState{HandshakeComplete: true, PeerCertificates: certs}
+}
+
+func tokenClaims(t *testing.T, rec *httptest.ResponseRecorder) accessTokenClaimSet {
+	t.Helper()
+
+	var resp generateTokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("could not decode token response: %v", err)
+	}
+
+	token, err := jose.Parse(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("could not parse access token: %v", err)
+	}
+
+	var claims accessTokenClaimSet
+	if err := token.Claims(&claims); err != nil {
+		t.Fatalf("could not decode access token claims: %v", err)
+	}
+
+	return claims
+}
+
+func newTestCA(t *testing.T, name string) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate CA key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("could not create CA certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("could not parse CA certificate: %v", err)
+	}
+
+	return cert, key
+}
+
+// issueTestCertificate self-signs the certificate when ca is nil.
+func issueTestCertificate(t *testing.T, ca *x509.Certificate, caKey crypto.Signer, customize func(*x509.Certificate)) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate client key: %v", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("could not generate serial number: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if customize != nil {
+		customize(template)
+	}
+
+	parent, signer := ca, caKey
+	if ca == nil {
+		parent, signer = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	if err != nil {
+		t.Fatalf("could not create client certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("could not parse client certificate: %v", err)
+	}
+
+	return cert
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/tracing"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+	"github.com/the-witcher-knight/jwt-encryption-server/pkg/dpop"
+)
+
+const (
+	scopeOpenID  = "openid"
+	scopeProfile = "profile"
+	scopeEmail   = "email"
+
+	// acrPassword is the authentication context class of a single-factor password sign in.
+	acrPassword = "1"
+	amrPassword = "pwd"
+
+	idTokenTTL = time.Hour
+)
+
+var (
+	claimsSupported = []string{
+		"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "acr", "amr", "azp", "at_hash", "c_hash",
+		"name", "given_name", "family_name", "preferred_username", "picture", "locale", "email", "email_verified",
+	}
+)
+
+type authentication struct {
+	UserID   string
+	ClientID string
+	Scopes   []string
+	Nonce    string
+	AuthTime time.Time
+	ACR      string
+	AMR      []string
+}
+
+func (h Handler) UserInfo() gin.HandlerFunc {
+	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
+		scheme, token := accessToken(ctx)
+		claims, err := h.verifyAccessToken(ctx, token)
+		if err == nil {
+			err = h.verifyProofOfPossession(ctx, PathUserInfo, scheme, token, claims)
+		}
+		if errors.Is(err, errDPoPNonceRequired) {
+			ctx.Header("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+			return err
+		}
+		if err != nil {
+			ctx.Header("WWW-Authenticate", scheme+` error="invalid_token"`)
+			return err
+		}
+
+		scopes := strings.Fields(claims.Scope)
+		if !slices.Contains(scopes, scopeOpenID) {
+			ctx.Header("WWW-Authenticate", scheme+` error="insufficient_scope", scope="openid"`)
+			return errInsufficientScope
+		}
+
+		u, err := h.users.Get(ctx, claims.Subject)
+		if errors.Is(err, user.ErrNotFound) {
+			ctx.Header("WWW-Authenticate", scheme+` error="invalid_token"`)
+			return errInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		ctx.Header("Cache-Control", "no-store")
+		ctx.JSON(http.StatusOK, userClaims(u, scopes))
+		return nil
+	})
+}
+
+// generateIDToken binds the access token and code, when given, with at_hash and c_hash.
+func (h Handler) generateIDToken(ctx context.Context, auth authentication, accessToken, code string) (token string, err error) {
+	ctx, span := tracing.StartSpan(ctx, "id_token.issue", tracing.String("oauth.client_id", auth.ClientID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	u, err := h.users.Get(ctx, auth.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := userClaims(u, auth.Scopes)
+	claims["iss"] = h.issuer
+	claims["aud"] = auth.ClientID
+	claims["azp"] = auth.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(idTokenTTL).Unix()
+	claims["auth_time"] = auth.AuthTime.Unix()
+
+	if auth.Nonce != "" {
+		claims["nonce"] = auth.Nonce
+	}
+	if auth.ACR != "" {
+		claims["acr"] = auth.ACR
+	}
+	if len(auth.AMR) > 0 {
+		claims["amr"] = auth.AMR
+	}
+	if accessToken != "" {
+		claims["at_hash"] = leftHalfHash(accessToken)
+	}
+	if code != "" {
+		claims["c_hash"] = leftHalfHash(code)
+	}
+
+	// ID tokens have no typ header so that they cannot pass for access tokens, which have one.
+	return h.signClaims(ctx, "", claims)
+}
+
+func userClaims(u user.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": u.ID,
+	}
+
+	set := func(k string, v string) {
+		if v != "" {
+			claims[k] = v
+		}
+	}
+
+	if slices.Contains(scopes, scopeProfile) {
+		set("name", u.Name)
+		set("given_name", u.GivenName)
+		set("family_name", u.FamilyName)
+		set("preferred_username", u.Username)
+		set("picture", u.Picture)
+		set("locale", u.Locale)
+	}
+
+	if slices.Contains(scopes, scopeEmail) && u.Email != "" {
+		claims["email"] = u.Email
+		claims["email_verified"] = u.EmailVerified
+	}
+
+	return claims
+}
+
+// leftHalfHash computes at_hash and c_hash for RS256.
+func leftHalfHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+// accessToken returns the authorization scheme and the access token of the request.
+func accessToken(ctx *gin.Context) (string, string) {
+	header := ctx.GetHeader("Authorization")
+	for _, scheme := range []string{"Bearer", dpop.TokenType} {
+		prefix := scheme + " "
+		if len(header) > len(prefix) && strings.EqualFold(header[:len(prefix)], prefix) {
+			return scheme, strings.TrimSpace(header[len(prefix):])
+		}
+	}
+
+	if ctx.Request.Method == http.MethodPost {
+		return "Bearer", ctx.PostForm("access_token")
+	}
+
+	return "Bearer", ""
+}
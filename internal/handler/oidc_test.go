@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/session"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+	"github.com/the-witcher-knight/jwt-encryption-server/pkg/dpop"
+)
+
+func TestUserInfo(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler(t,
+		WithUsers(user.NewMemoryStore(user.User{ID: "u1", Username: "alice", Name: "Alice"})),
+		WithClients(client.NewMemoryRegistry(client.Client{ID: "app"}, client.Client{ID: "api"})),
+	)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate DPoP key: %v", err)
+	}
+	jkt, err := ecJWK(&key.PublicKey).Thumbprint()
+	if err != nil {
+		t.Fatalf("Thumbprint() = %v", err)
+	}
+
+	accessToken := func(scope string, customize func(claims map[string]interface{})) string {
+		claims := h.accessTokenClaims("u1", "app", strings.Fields(scope))
+		if customize != nil {
+			customize(claims)
+		}
+
+		token, err := h.signAccessToken(ctx, tokenGrant{GrantType: client.GrantTypeClientCredentials, Claims: claims})
+		if err != nil {
+			t.Fatalf("signAccessToken() = %v", err)
+		}
+		return token
+	}
+	dpopBound := func(claims map[string]interface{}) { claims["cnf"] = confirmation{JKT: jkt} }
+
+	idToken, err := h.generateIDToken(ctx, authentication{UserID: "u1", ClientID: "app", Scopes: []string{scopeOpenID}}, "", "")
+	if err != nil {
+		t.Fatalf("generateIDToken() = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		scheme     string
+		token      string
+		wantCode   int
+		wantHeader string
+	}{
+		{
+			name:     "access token",
+			scheme:   "Bearer",
+			token:    accessToken("openid profile", nil),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "access token for a client audience",
+			scheme:   "Bearer",
+			token:    accessToken("openid", func(claims map[string]interface{}) { claims["aud"] = "api" }),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "DPoP-bound access token",
+			scheme:   dpop.TokenType,
+			token:    accessToken("openid", dpopBound),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "ID token",
+			scheme:     "Bearer",
+			token:      idToken,
+			wantCode:   http.StatusUnauthorized,
+			wantHeader: `Bearer error="invalid_token"`,
+		},
+		{
+			name:       "DPoP-bound access token as a bearer token",
+			scheme:     "Bearer",
+			token:      accessToken("openid", dpopBound),
+			wantCode:   http.StatusUnauthorized,
+			wantHeader: `Bearer error="invalid_token"`,
+		},
+		{
+			name:       "access token without openid",
+			scheme:     "Bearer",
+			token:      accessToken("profile", nil),
+			wantCode:   http.StatusForbidden,
+			wantHeader: `Bearer error="insufficient_scope", scope="openid"`,
+		},
+		{
+			name:       "DPoP-bound access token without openid",
+			scheme:     dpop.TokenType,
+			token:      accessToken("profile", dpopBound),
+			wantCode:   http.StatusForbidden,
+			wantHeader: `DPoP error="insufficient_scope", scope="openid"`,
+		},
+		{
+			name:   "DPoP-bound access token of an unknown user",
+			scheme: dpop.TokenType,
+			token: accessToken("openid", func(claims map[string]interface{}) {
+				dpopBound(claims)
+				claims["sub"] = "u2"
+			}),
+			wantCode:   http.StatusUnauthorized,
+			wantHeader: `DPoP error="invalid_token"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET(PathUserInfo, h.UserInfo())
+
+			req := httptest.NewRequest(http.MethodGet, PathUserInfo, nil)
+			req.Header.Set("Authorization", tt.scheme+" "+tt.token)
+			if tt.scheme == dpop.TokenType {
+				req.Header.Set(dpop.HeaderName, signDPoPProof(t, key, http.MethodGet, strings.TrimSuffix(defaultIssuer, "/")+PathUserInfo, tt.token))
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.wantHeader {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func ecJWK(pub *ecdsa.PublicKey) jose.JWK {
+	return jose.JWK{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func signDPoPProof(t *testing.T, key *ecdsa.PrivateKey, method, uri, accessToken string) string {
+	t.Helper()
+
+	jwk := ecJWK(&key.PublicKey)
+	jti, err := session.NewID()
+	if err != nil {
+		t.Fatalf("NewID() = %v", err)
+	}
+
+	claims := map[string]interface{}{"jti": jti, "htm": method, "htu": uri, "iat": time.Now().Unix()}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+
+	return signJWT(t, key, jose.Header{Typ: "dpop+jwt", JWK: &jwk}, claims)
+}
+
+func signJWT(t *testing.T, key *ecdsa.PrivateKey, header jose.Header, claims map[string]interface{}) string {
+	t.Helper()
+
+	header.Alg = jose.AlgES256
+	headerBytes, _ := json.Marshal(header)
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(headerBytes) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("could not sign JWT: %v", err)
+	}
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
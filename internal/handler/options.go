@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"crypto/x509"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/audit"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/authcode"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/consent"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/device"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/par"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/policy"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/rbac"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/refresh"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/replay"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/resource"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/revocation"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/session"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+	"github.com/the-witcher-knight/jwt-encryption-server/pkg/dpop"
+	"github.com/the-witcher-knight/jwt-encryption-server/pkg/tokenhook"
+)
+
+type Option func(*Handler)
+
+func WithIssuer(issuer string) Option {
+	return func(h *Handler) {
+		h.issuer = issuer
+	}
+}
+
+func WithAudience(audience string) Option {
+	return func(h *Handler) {
+		h.audience = audience
+	}
+}
+
+func WithAccessTokenTTL(ttl time.Duration) Option {
+	return func(h *Handler) {
+		h.accessTokenTTL = ttl
+	}
+}
+
+func WithClients(clients client.Registry) Option {
+	return func(h *Handler) {
+		h.clients = clients
+	}
+}
+
+func WithResources(resources resource.Registry) Option {
+	return func(h *Handler) {
+		h.resources = resources
+	}
+}
+
+func WithRoles(roles rbac.Store) Option {
+	return func(h *Handler) {
+		h.roles = roles
+	}
+}
+
+func WithPolicy(policies policy.Source) Option {
+	return func(h *Handler) {
+		h.policies = policies
+	}
+}
+
+func WithUsers(users user.Store) Option {
+	return func(h *Handler) {
+		h.users = users
+	}
+}
+
+func WithSessions(sessions session.Store) Option {
+	return func(h *Handler) {
+		h.sessions = sessions
+	}
+}
+
+func WithConsents(consents consent.Store) Option {
+	return func(h *Handler) {
+		h.consents = consents
+	}
+}
+
+func WithAuthorizationCodes(codes authcode.Store) Option {
+	return func(h *Handler) {
+		h.codes = codes
+	}
+}
+
+func WithDeviceAuthorizations(devices device.Store) Option {
+	return func(h *Handler) {
+		h.devices = devices
+	}
+}
+
+func WithPushedAuthorizationRequests(requests par.Store) Option {
+	return func(h *Handler) {
+		h.pushedRequests = requests
+	}
+}
+
+func WithAuditRecorder(recorder audit.Recorder) Option {
+	return func(h *Handler) {
+		h.audit = recorder
+	}
+}
+
+// WithStorage keeps the state of the flows in the storage. Handlers sharing a storage must be given
+// prefixed ones.
+func WithStorage(kv storage.Store) Option {
+	return func(h *Handler) {
+		h.sessions = session.NewStore(kv)
+		h.consents = consent.NewStore(kv)
+		h.codes = authcode.NewStore(kv)
+		h.devices = device.NewStore(kv)
+		h.pushedRequests = par.NewStore(kv)
+		h.replays = replay.NewCache(kv)
+		h.refreshTokens = refresh.NewStore(kv)
+		h.revocations = revocation.NewList(kv)
+	}
+}
+
+func WithReplayCache(replays replay.Cache) Option {
+	return func(h *Handler) {
+		h.replays = replays
+	}
+}
+
+// WithClientCertificates tells the handler that clients can present certificates in the TLS handshake.
+func WithClientCertificates() Option {
+	return func(h *Handler) {
+		h.clientCerts = true
+	}
+}
+
+func WithClientCAs(pool *x509.CertPool) Option {
+	return func(h *Handler) {
+		h.clientCAs = pool
+	}
+}
+
+func WithDPoPNonces(nonces dpop.NonceSource) Option {
+	return func(h *Handler) {
+		h.dpopNonces = nonces
+	}
+}
+
+func WithTokenHook(hook tokenhook.Hook) Option {
+	return func(h *Handler) {
+		h.tokenHook = hook
+	}
+}
+
+// WithMetrics labels the metrics of the handler with its issuer.
+func WithMetrics(m *Metrics) Option {
+	return func(h *Handler) {
+		h.metrics = m
+	}
+}
+
+// WithSigningKeyCreatedAt lets the metrics report the age of the signing key.
+func WithSigningKeyCreatedAt(createdAt time.Time) Option {
+	return func(h *Handler) {
+		h.keyCreatedAt = createdAt
+	}
+}
@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/par"
+)
+
+const (
+	PathPushedAuthorizationRequest = "/par"
+
+	// pushedRequestTTL includes the time the user takes to sign in and consent.
+	pushedRequestTTL = 10 * time.Minute
+)
+
+var (
+	// requestObjectClaims are the JWT claims of a request object that are not authorization parameters.
+	requestObjectClaims = []string{"iss", "aud", "exp", "nbf", "iat", "jti", "request", "request_uri"}
+	clientAuthParams    = []string{"client_secret", "client_assertion", "client_assertion_type"}
+)
+
+func (h Handler) PushedAuthorizationRequest() gin.HandlerFunc {
+	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
+		var creds clientCredentials
+		if err := ctx.ShouldBind(&creds); err != nil {
+			return errInvalidRequest
+		}
+
+		cl, err := h.authenticateClient(ctx, creds)
+		if err != nil {
+			return err
+		}
+
+		params := url.Values{}
+		for k, v := range ctx.Request.PostForm {
+			if !slices.Contains(clientAuthParams, k) {
+				params[k] = v
+			}
+		}
+
+		if params.Has("request_uri") || (params.Has("client_id") && params.Get("client_id") != cl.ID) {
+			return errInvalidRequest
+		}
+		params.Set("client_id", cl.ID)
+
+		if params.Has("request") {
+			if params, err = h.requestObjectParameters(ctx, cl, params.Get("request")); err != nil {
+				return err
+			}
+		}
+
+		if _, err := validateAuthorizeRequest(cl, params); err != nil {
+			var redirectErr *redirectError
+			if errors.As(err, &redirectErr) {
+				return &httpserver.HTTPError{Code: http.StatusBadRequest, Message: redirectErr.Code, Detail: redirectErr.Description}
+			}
+
+			return err
+		}
+
+		requestURI, err := h.storeAuthorizeRequest(ctx, cl, params)
+		if err != nil {
+			return err
+		}
+
+		ctx.Header("Cache-Control", "no-store")
+		ctx.JSON(http.StatusCreated, pushedAuthorizationResponse{
+			RequestURI: requestURI,
+			ExpiresIn:  int64(pushedRequestTTL.Seconds()),
+		})
+		return nil
+	})
+}
+
+// authorizeParameters takes the parameters from a request URI, a request object or, unless the client must
+// push its requests, the query.
+func (h Handler) authorizeParameters(ctx context.Context, cl client.Client, query url.Values) (url.Values, error) {
+	switch {
+	case query.Has("request_uri"):
+		if query.Has("request") {
+			return nil, errInvalidRequest
+		}
+
+		stored, err := h.pushedRequests.Get(ctx, query.Get("request_uri"))
+		if errors.Is(err, par.ErrNotFound) {
+			return nil, errInvalidRequestURI
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if stored.ClientID != cl.ID {
+			return nil, errInvalidRequestURI
+		}
+
+		return stored.Params, nil
+	case cl.RequirePushedAuthorizationRequests:
+		return nil, errPushedAuthorizationRequired
+	case query.Has("request"):
+		return h.requestObjectParameters(ctx, cl, query.Get("request"))
+	default:
+		return query, nil
+	}
+}
+
+// requestObjectParameters ignores the parameters outside of the object. Request objects must expire and can
+// only be used once.
+func (h Handler) requestObjectParameters(ctx context.Context, cl client.Client, request string) (url.Values, error) {
+	token, err := jose.Parse(request)
+	if err != nil {
+		return nil, errInvalidRequestObject
+	}
+
+	if err := h.verifyClientSignature(ctx, cl, token); errors.Is(err, errInvalidClientIDOrSecret) {
+		return nil, errInvalidRequestObject
+	} else if err != nil {
+		return nil, err
+	}
+
+	var registered assertionClaims
+	if err := token.Claims(&registered); err != nil {
+		return nil, errInvalidRequestObject
+	}
+
+	now := time.Now()
+	switch {
+	case registered.Issuer != cl.ID || registered.JWTID == "":
+		return nil, errInvalidRequestObject
+	case !slices.ContainsFunc(registered.Audience, h.isAssertionAudience):
+		return nil, errInvalidRequestObject
+	case registered.ExpiresAt == 0 || now.After(time.Unix(registered.ExpiresAt, 0).Add(assertionLeeway)):
+		return nil, errInvalidRequestObject
+	case time.Unix(registered.ExpiresAt, 0).After(now.Add(maxAssertionLifetime)):
+		return nil, errInvalidRequestObject
+	case registered.NotBefore != 0 && now.Add(assertionLeeway).Before(time.Unix(registered.NotBefore, 0)):
+		return nil, errInvalidRequestObject
+	}
+
+	fresh, err := h.replays.Use(ctx, "request_object:"+cl.ID+":"+registered.JWTID, time.Unix(registered.ExpiresAt, 0).Add(assertionLeeway))
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, errInvalidRequestObject
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(token.Payload))
+	decoder.UseNumber()
+
+	var claims map[string]interface{}
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, errInvalidRequestObject
+	}
+
+	params := url.Values{}
+	for k, v := range claims {
+		if slices.Contains(requestObjectClaims, k) {
+			continue
+		}
+
+		switch v := v.(type) {
+		case string:
+			params.Set(k, v)
+		case json.Number, bool:
+			params.Set(k, fmt.Sprint(v))
+		default:
+			// Structured parameters, such as claims, keep their JSON form.
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, errInvalidRequestObject
+			}
+			params.Set(k, string(b))
+		}
+	}
+
+	if params.Has("client_id") && params.Get("client_id") != cl.ID {
+		return nil, errInvalidRequestObject
+	}
+	params.Set("client_id", cl.ID)
+
+	return params, nil
+}
+
+func (h Handler) storeAuthorizeRequest(ctx context.Context, cl client.Client, params url.Values) (string, error) {
+	requestURI, err := par.NewRequestURI()
+	if err != nil {
+		return "", err
+	}
+
+	if err := h.pushedRequests.Save(ctx, par.Request{
+		RequestURI: requestURI,
+		ClientID:   cl.ID,
+		Params:     params,
+		ExpiresAt:  time.Now().Add(pushedRequestTTL),
+	}); err != nil {
+		return "", err
+	}
+
+	return requestURI, nil
+}
+
+func (h Handler) clearLoginPrompt(ctx context.Context, returnTo *url.URL) error {
+	query := returnTo.Query()
+	if !query.Has("request_uri") {
+		stripLoginPrompt(query)
+		returnTo.RawQuery = query.Encode()
+		return nil
+	}
+
+	stored, err := h.pushedRequests.Get(ctx, query.Get("request_uri"))
+	if errors.Is(err, par.ErrNotFound) {
+		// The authorization endpoint reports the expired request.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	stripLoginPrompt(stored.Params)
+	return h.pushedRequests.Save(ctx, stored)
+}
+
+func stripLoginPrompt(params url.Values) {
+	prompts := slices.DeleteFunc(strings.Fields(params.Get("prompt")), func(p string) bool { return p == promptLogin })
+	params.Del("prompt")
+	params.Del("max_age")
+	if len(prompts) > 0 {
+		params.Set("prompt", strings.Join(prompts, " "))
+	}
+}
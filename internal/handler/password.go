@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/audit"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+)
+
+// passwordGrant is only meant for legacy clients that cannot use a browser.
+func (h Handler) passwordGrant(ctx *gin.Context, cl client.Client, req generateTokenRequest, _ confirmation) (tokenGrant, error) {
+	if cl.Public() {
+		return tokenGrant{}, errUnauthorizedClient
+	}
+
+	if req.Username == "" || req.Password == "" {
+		return tokenGrant{}, errInvalidRequest
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if !cl.AllowsScopes(scopes) {
+		return tokenGrant{}, errInvalidScope
+	}
+
+	u, err := h.users.Authenticate(ctx, req.Username, req.Password)
+	if err := h.auditAuthentication(ctx, audit.TypePasswordGrant, cl.ID, req.Username, u, err); err != nil {
+		return tokenGrant{}, err
+	}
+	switch {
+	case errors.Is(err, user.ErrInvalidCredentials):
+		return tokenGrant{}, errInvalidGrant
+	case errors.Is(err, user.ErrLocked):
+		return tokenGrant{}, errAccountLocked
+	case err != nil:
+		return tokenGrant{}, err
+	}
+
+	return tokenGrant{
+		Claims: h.accessTokenClaims(u.ID, cl.ID, scopes),
+		Authentication: &authentication{
+			UserID:   u.ID,
+			ClientID: cl.ID,
+			Scopes:   scopes,
+			AuthTime: time.Now(),
+			ACR:      acrPassword,
+			AMR:      []string{amrPassword},
+		},
+	}, nil
+}
+
+// auditAuthentication does not audit unexpected errors since they say nothing about the credentials.
+func (h Handler) auditAuthentication(ctx *gin.Context, eventType, clientID, username string, u user.User, err error) error {
+	event := audit.Event{
+		Type:       eventType,
+		Outcome:    audit.OutcomeSuccess,
+		UserID:     u.ID,
+		Username:   username,
+		ClientID:   clientID,
+		RemoteAddr: ctx.ClientIP(),
+	}
+
+	switch {
+	case errors.Is(err, user.ErrInvalidCredentials):
+		event.Outcome, event.Reason = audit.OutcomeFailure, failureInvalidUserCredential
+	case errors.Is(err, user.ErrLocked):
+		event.Outcome, event.Reason = audit.OutcomeFailure, failureUserLocked
+	case err != nil:
+		return nil
+	}
+
+	if event.Outcome == audit.OutcomeFailure {
+		h.metrics.countAuthFailure(h.issuer, event.Reason)
+	}
+
+	return h.audit.Record(ctx, event)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+)
+
+func TestPasswordGrant(t *testing.T) {
+	hash, err := user.HashPassword("password")
+	if err != nil {
+		t.Fatalf("HashPassword() = %v", err)
+	}
+
+	users := user.NewMemoryStore(
+		user.User{ID: "u1", Username: "alice", PasswordHash: hash},
+		user.User{ID: "u2", Username: "bob", PasswordHash: hash},
+	)
+	h := newTestHandler(t,
+		WithUsers(user.NewLockoutStore(users, storage.NewMemoryStore(), user.LockoutPolicy{
+			Threshold: 1, BaseDelay: time.Hour, MaxDelay: time.Hour, ResetAfter: time.Hour,
+		})),
+		WithClients(client.NewMemoryRegistry(
+			client.Client{ID: "legacy-tool", Secret: "secret", GrantTypes: []string{client.GrantTypePassword}, Scopes: []string{"read"}},
+			client.Client{ID: "cli", TokenEndpointAuthMethod: client.AuthMethodNone, GrantTypes: []string{client.GrantTypePassword}},
+			client.Client{ID: "web", Secret: "secret", GrantTypes: []string{client.GrantTypeAuthorizationCode}},
+		)),
+	)
+
+	tests := []struct {
+		name     string
+		clientID string
+		username string
+		password string
+		scope    string
+		// wantError is the error of the response, a token is wanted when it is empty.
+		wantError  string
+		wantDetail string
+	}{
+		{name: "password", clientID: "legacy-tool", username: "alice", password: "password", scope: "read"},
+		{name: "public client", clientID: "cli", username: "alice", password: "password", wantError: "unauthorized_client"},
+		{name: "client without the grant", clientID: "web", username: "alice", password: "password", wantError: "unauthorized_client"},
+		{name: "missing password", clientID: "legacy-tool", username: "alice", wantError: "invalid_request"},
+		{name: "scope of another client", clientID: "legacy-tool", username: "alice", password: "password", scope: "admin", wantError: "invalid_scope"},
+		{name: "unknown user", clientID: "legacy-tool", username: "mallory", password: "password", wantError: "invalid_grant"},
+		{name: "wrong password", clientID: "legacy-tool", username: "bob", password: "wrong", wantError: "invalid_grant"},
+		{name: "locked account", clientID: "legacy-tool", username: "bob", password: "password", wantError: "invalid_grant", wantDetail: "locked"},
+	}
+
+	// The cases run in order: the wrong password locks the account of the next one.
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{
+				"grant_type": {client.GrantTypePassword},
+				"client_id":  {tt.clientID},
+				"username":   {tt.username},
+				"password":   {tt.password},
+				"scope":      {tt.scope},
+			}
+			if tt.clientID != "cli" {
+				form.Set("client_secret", "secret")
+			}
+			rec := serveTokenRequest(h, nil, form)
+
+			if tt.wantError == "" {
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+				}
+				if claims := tokenClaims(t, rec); claims.Subject != "u1" {
+					t.Errorf("sub = %q, want the user u1", claims.Subject)
+				}
+				return
+			}
+
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"error":"`+tt.wantError+`"`) {
+				t.Fatalf("status = %d, body = %s, want %s", rec.Code, rec.Body, tt.wantError)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantDetail) {
+				t.Errorf("body = %s, want it to mention %q", rec.Body, tt.wantDetail)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/rbac"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/resource"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+)
+
+// addAuthorizationClaims only includes the permissions on the resources in the audience, and the roles
+// granting them, so resource servers can authorize requests from the token alone.
+func (h Handler) addAuthorizationClaims(ctx context.Context, claims map[string]interface{}) error {
+	subject, _ := claims["sub"].(string)
+	names, err := h.subjectRoles(ctx, subject)
+	if err != nil || len(names) == 0 {
+		return err
+	}
+
+	declared := map[string][]string{}
+	for _, id := range audiences(claims["aud"]) {
+		res, err := h.resources.Get(ctx, id)
+		if errors.Is(err, resource.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		declared[res.Identifier] = res.Permissions
+	}
+
+	var roles, permissions []string
+	for _, name := range names {
+		role, err := h.roles.Get(ctx, name)
+		if errors.Is(err, rbac.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		granted := false
+		for _, p := range role.Permissions {
+			if slices.Contains(declared[p.Resource], p.Name) {
+				permissions = append(permissions, p.Name)
+				granted = true
+			}
+		}
+
+		if granted {
+			roles = append(roles, role.Name)
+		}
+	}
+
+	slices.Sort(permissions)
+	setNonEmpty(claims, "roles", roles)
+	setNonEmpty(claims, "permissions", slices.Compact(permissions))
+
+	return nil
+}
+
+// subjectRoles treats subjects of the form <client_id>@clients as clients and others as users.
+func (h Handler) subjectRoles(ctx context.Context, subject string) ([]string, error) {
+	if clientID, ok := strings.CutSuffix(subject, "@clients"); ok {
+		cl, err := h.clients.Get(ctx, clientID)
+		if errors.Is(err, client.ErrNotFound) {
+			return nil, nil
+		}
+
+		return cl.Roles, err
+	}
+
+	u, err := h.users.Get(ctx, subject)
+	if errors.Is(err, user.ErrNotFound) {
+		return nil, nil
+	}
+
+	return u.Roles, err
+}
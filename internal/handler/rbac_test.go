@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/rbac"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/resource"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+)
+
+func TestAddAuthorizationClaims(t *testing.T) {
+	h := newTestHandler(t,
+		WithResources(resource.NewMemoryRegistry(
+			resource.Resource{Identifier: "https://orders.example.com", Permissions: []string{"orders:read", "orders:write"}},
+			resource.Resource{Identifier: "https://billing.example.com", Permissions: []string{"invoices:read"}},
+		)),
+		WithRoles(rbac.NewMemoryStore(
+			rbac.Role{Name: "clerk", Permissions: []rbac.Permission{
+				{Resource: "https://orders.example.com", Name: "orders:read"},
+				{Resource: "https://orders.example.com", Name: "orders:write"},
+			}},
+			rbac.Role{Name: "accountant", Permissions: []rbac.Permission{
+				{Resource: "https://billing.example.com", Name: "invoices:read"},
+				{Resource: "https://orders.example.com", Name: "orders:read"},
+			}},
+			// Permissions the resource does not declare are never granted.
+			rbac.Role{Name: "intruder", Permissions: []rbac.Permission{
+				{Resource: "https://orders.example.com", Name: "orders:delete"},
+				{Resource: "https://billing.example.com", Name: "orders:write"},
+			}},
+		)),
+		WithUsers(user.NewMemoryStore(
+			user.User{ID: "u1", Roles: []string{"clerk", "accountant", "intruder", "removed"}},
+			user.User{ID: "u2"},
+		)),
+		WithClients(client.NewMemoryRegistry(
+			client.Client{ID: "batch", Secret: "secret", Roles: []string{"accountant"}},
+		)),
+	)
+
+	tests := []struct {
+		name            string
+		subject         string
+		aud             interface{}
+		wantRoles       []string
+		wantPermissions []string
+	}{
+		{
+			name:            "one resource",
+			subject:         "u1",
+			aud:             "https://orders.example.com",
+			wantRoles:       []string{"clerk", "accountant"},
+			wantPermissions: []string{"orders:read", "orders:write"},
+		},
+		{
+			name:            "another resource",
+			subject:         "u1",
+			aud:             "https://billing.example.com",
+			wantRoles:       []string{"accountant"},
+			wantPermissions: []string{"invoices:read"},
+		},
+		{
+			name:            "several resources",
+			subject:         "u1",
+			aud:             []string{"https://billing.example.com", "https://orders.example.com"},
+			wantRoles:       []string{"clerk", "accountant"},
+			wantPermissions: []string{"invoices:read", "orders:read", "orders:write"},
+		},
+		{name: "unregistered resource", subject: "u1", aud: "https://unknown.example.com"},
+		{name: "user without roles", subject: "u2", aud: "https://orders.example.com"},
+		{name: "unknown user", subject: "u3", aud: "https://orders.example.com"},
+		{
+			name:            "client",
+			subject:         "batch@clients",
+			aud:             "https://billing.example.com",
+			wantRoles:       []string{"accountant"},
+			wantPermissions: []string{"invoices:read"},
+		},
+		{name: "unknown client", subject: "gone@clients", aud: "https://billing.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]interface{}{"sub": tt.subject, "aud": tt.aud}
+			if err := h.addAuthorizationClaims(context.Background(), claims); err != nil {
+				t.Fatalf("addAuthorizationClaims() = %v", err)
+			}
+
+			for _, c := range []struct {
+				name string
+				want []string
+			}{
+				{"roles", tt.wantRoles},
+				{"permissions", tt.wantPermissions},
+			} {
+				got, _ := claims[c.name].([]string)
+				if _, set := claims[c.name]; set && len(c.want) == 0 {
+					t.Errorf("%s = %v, want the claim left out", c.name, claims[c.name])
+				} else if !slices.Equal(got, c.want) {
+					t.Errorf("%s = %v, want %v", c.name, got, c.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/refresh"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/session"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+)
+
+// refreshTokenGrant rotates the token: it is used up and the client gets a new one of the same family.
+func (h Handler) refreshTokenGrant(ctx *gin.Context, cl client.Client, req generateTokenRequest, cnf confirmation) (tokenGrant, error) {
+	rt, err := h.refreshTokens.Get(ctx, req.RefreshToken)
+	if errors.Is(err, refresh.ErrNotFound) {
+		// Consuming a token that was used already revokes the tokens rotated from it.
+		if _, err := h.refreshTokens.Consume(ctx, req.RefreshToken); err != nil && !errors.Is(err, refresh.ErrNotFound) {
+			return tokenGrant{}, err
+		}
+		return tokenGrant{}, errInvalidGrant
+	}
+	if err != nil {
+		return tokenGrant{}, err
+	}
+
+	// The token is checked before it is used up, so that another client or key cannot burn it.
+	if rt.ClientID != cl.ID || !keepsBinding(confirmation{JKT: rt.JKT, X5TS256: rt.X5TS256}, cnf) {
+		return tokenGrant{}, errInvalidGrant
+	}
+
+	scopes := rt.Scopes
+	if req.Scope != "" {
+		scopes = strings.Fields(req.Scope)
+		for _, scope := range scopes {
+			if !slices.Contains(rt.Scopes, scope) {
+				return tokenGrant{}, errInvalidScope
+			}
+		}
+	}
+
+	if _, err := h.users.Get(ctx, rt.UserID); errors.Is(err, user.ErrNotFound) {
+		return tokenGrant{}, errInvalidGrant
+	} else if err != nil {
+		return tokenGrant{}, err
+	}
+
+	if _, err := h.refreshTokens.Consume(ctx, req.RefreshToken); errors.Is(err, refresh.ErrNotFound) {
+		return tokenGrant{}, errInvalidGrant
+	} else if err != nil {
+		return tokenGrant{}, err
+	}
+
+	return tokenGrant{
+		Claims: h.accessTokenClaims(rt.UserID, cl.ID, scopes),
+		Authentication: &authentication{
+			UserID:   rt.UserID,
+			ClientID: cl.ID,
+			Scopes:   scopes,
+			AuthTime: rt.AuthTime,
+			ACR:      rt.ACR,
+			AMR:      rt.AMR,
+		},
+		Refreshed: &rt,
+	}, nil
+}
+
+// issueRefreshToken keeps the family, scopes and expiry of the token being rotated. Public clients
+// cannot authenticate, so their tokens are bound to the key or certificate they proved possession of.
+func (h Handler) issueRefreshToken(ctx context.Context, cl client.Client, grant tokenGrant, cnf confirmation) (string, error) {
+	token, err := session.NewID()
+	if err != nil {
+		return "", err
+	}
+
+	auth := grant.Authentication
+	rt := refresh.Token{
+		Token:     token,
+		ClientID:  cl.ID,
+		UserID:    auth.UserID,
+		Scopes:    auth.Scopes,
+		AuthTime:  auth.AuthTime,
+		ACR:       auth.ACR,
+		AMR:       auth.AMR,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+
+	if prev := grant.Refreshed; prev != nil {
+		rt.Family, rt.Scopes, rt.ExpiresAt = prev.Family, prev.Scopes, prev.ExpiresAt
+	} else if rt.Family, err = session.NewID(); err != nil {
+		return "", err
+	}
+
+	if cl.Public() {
+		rt.JKT, rt.X5TS256 = cnf.JKT, cnf.X5TS256
+	}
+
+	if err := h.refreshTokens.Save(ctx, rt); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
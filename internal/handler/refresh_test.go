@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/user"
+)
+
+func TestRefreshTokenGrant(t *testing.T) {
+	h := newRefreshTestHandler(t)
+
+	if resp := passwordGrant(t, h, "legacy-tool"); resp.RefreshToken != "" {
+		t.Error("refresh token issued to a client that may not use the refresh_token grant")
+	}
+
+	first := passwordGrant(t, h, "app")
+	if first.RefreshToken == "" {
+		t.Fatal("no refresh token issued")
+	}
+
+	tests := []struct {
+		name      string
+		clientID  string
+		scope     string
+		wantError string
+	}{
+		{name: "another client", clientID: "other-app", wantError: "invalid_grant"},
+		{name: "wider scope", clientID: "app", scope: "read admin", wantError: "invalid_scope"},
+		{name: "narrower scope", clientID: "app", scope: "read"},
+	}
+
+	// Rejected requests leave the token usable; the last case uses it up.
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveRefresh(h, tt.clientID, first.RefreshToken, tt.scope)
+			if tt.wantError != "" {
+				if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.wantError) {
+					t.Errorf("status = %d, body = %s, want %s", rec.Code, rec.Body, tt.wantError)
+				}
+				return
+			}
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+			if claims := tokenClaims(t, rec); claims.Scope != tt.scope || claims.Subject != "u1" {
+				t.Errorf("refreshed token has sub %q and scope %q, want u1 and %q", claims.Subject, claims.Scope, tt.scope)
+			}
+		})
+	}
+
+	// The token was rotated: the next one keeps the scopes of the authorization, not the narrowed ones.
+	second := refreshResponse(t, serveRefresh(h, "app", first.RefreshToken, ""))
+	if second.RefreshToken != "" {
+		t.Fatal("used refresh token was accepted again")
+	}
+
+	third := passwordGrant(t, h, "app")
+	rotated := refreshResponse(t, serveRefresh(h, "app", third.RefreshToken, ""))
+	if rotated.RefreshToken == "" || rotated.RefreshToken == third.RefreshToken {
+		t.Fatalf("refresh token was not rotated: %+v", rotated)
+	}
+	if rotated.Scope != "read write" {
+		t.Errorf("rotated token scope = %q, want %q", rotated.Scope, "read write")
+	}
+
+	// Reusing a rotated token revokes the tokens rotated from it.
+	if rec := serveRefresh(h, "app", third.RefreshToken, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("reused refresh token: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := serveRefresh(h, "app", rotated.RefreshToken, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("refresh token of a reused family: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	h := newRefreshTestHandler(t)
+
+	tests := []struct {
+		name     string
+		clientID string
+		token    func(generateTokenResponse) string
+		wantCode int
+		// wantAccess and wantRefresh tell whether the tokens still work after the request.
+		wantAccess, wantRefresh bool
+	}{
+		{
+			name:     "access token",
+			clientID: "app",
+			token:    func(resp generateTokenResponse) string { return resp.AccessToken },
+			wantCode: http.StatusOK, wantRefresh: true,
+		},
+		{
+			name:     "refresh token",
+			clientID: "app",
+			token:    func(resp generateTokenResponse) string { return resp.RefreshToken },
+			wantCode: http.StatusOK, wantAccess: true,
+		},
+		{
+			name:     "token of another client",
+			clientID: "other-app",
+			token:    func(resp generateTokenResponse) string { return resp.AccessToken },
+			wantCode: http.StatusBadRequest, wantAccess: true, wantRefresh: true,
+		},
+		{
+			name:     "invalid token",
+			clientID: "app",
+			token:    func(generateTokenResponse) string { return "not-a-token" },
+			wantCode: http.StatusOK, wantAccess: true, wantRefresh: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := passwordGrant(t, h, "app")
+
+			rec := serveForm(h.Revoke(), PathRevoke, url.Values{
+				"client_id":     {tt.clientID},
+				"client_secret": {"secret"},
+				"token":         {tt.token(resp)},
+			})
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+
+			gin.SetMode(gin.TestMode)
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodGet, PathUserInfo, nil)
+			if _, err := h.verifyAccessToken(ctx, resp.AccessToken); (err == nil) != tt.wantAccess {
+				t.Errorf("verifyAccessToken() = %v, want access %v", err, tt.wantAccess)
+			}
+
+			if rec := serveRefresh(h, "app", resp.RefreshToken, ""); (rec.Code == http.StatusOK) != tt.wantRefresh {
+				t.Errorf("refresh: status = %d, want refresh %v", rec.Code, tt.wantRefresh)
+			}
+		})
+	}
+}
+
+func newRefreshTestHandler(t *testing.T) Handler {
+	t.Helper()
+
+	hash, err := user.HashPassword("password")
+	if err != nil {
+		t.Fatalf("HashPassword() = %v", err)
+	}
+
+	grantTypes := []string{client.GrantTypePassword, client.GrantTypeRefreshToken}
+	return newTestHandler(t,
+		WithUsers(user.NewMemoryStore(user.User{ID: "u1", Username: "alice", PasswordHash: hash})),
+		WithClients(client.NewMemoryRegistry(
+			client.Client{ID: "app", Secret: "secret", GrantTypes: grantTypes, Scopes: []string{"read", "write"}},
+			client.Client{ID: "other-app", Secret: "secret", GrantTypes: grantTypes, Scopes: []string{"read", "write"}},
+			client.Client{ID: "legacy-tool", Secret: "secret", GrantTypes: []string{client.GrantTypePassword}},
+		)),
+	)
+}
+
+func passwordGrant(t *testing.T, h Handler, clientID string) generateTokenResponse {
+	t.Helper()
+
+	scope := "read write"
+	if clientID == "legacy-tool" {
+		scope = ""
+	}
+
+	rec := serveTokenRequest(h, nil, url.Values{
+		"grant_type":    {client.GrantTypePassword},
+		"client_id":     {clientID},
+		"client_secret": {"secret"},
+		"username":      {"alice"},
+		"password":      {"password"},
+		"scope":         {scope},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("password grant: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	return refreshResponse(t, rec)
+}
+
+func serveRefresh(h Handler, clientID, refreshToken, scope string) *httptest.ResponseRecorder {
+	return serveTokenRequest(h, nil, url.Values{
+		"grant_type":    {client.GrantTypeRefreshToken},
+		"client_id":     {clientID},
+		"client_secret": {"secret"},
+		"refresh_token": {refreshToken},
+		"scope":         {scope},
+	})
+}
+
+// refreshResponse is empty when the request failed.
+func refreshResponse(t *testing.T, rec *httptest.ResponseRecorder) generateTokenResponse {
+	t.Helper()
+
+	var resp generateTokenResponse
+	if rec.Code != http.StatusOK {
+		return resp
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("could not decode token response: %v", err)
+	}
+
+	return resp
+}
+
+func serveForm(handler gin.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST(path, handler)
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
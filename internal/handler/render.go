@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+)
+
+var (
+	//go:embed templates/*.html
+	templateFS embed.FS
+
+	templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+)
+
+type loginPage struct {
+	Title     string
+	ReturnTo  string
+	CSRFToken string
+	Error     string
+}
+
+type consentPage struct {
+	ClientName string
+	Scopes     []string
+	Request    string
+	CSRFToken  string
+}
+
+type devicePage struct {
+	UserCode   string
+	ClientName string
+	Scopes     []string
+	CSRFToken  string
+	Error      string
+	Message    string
+}
+
+type errorPage struct {
+	Error       string
+	Description string
+	RequestID   string
+}
+
+func (h Handler) renderLogin(ctx *gin.Context, returnTo *url.URL, title string, status int, message string) error {
+	token, err := h.csrfToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	return render(ctx, status, "login.html", loginPage{
+		Title:     title,
+		ReturnTo:  returnTo.String(),
+		CSRFToken: token,
+		Error:     message,
+	})
+}
+
+func (h Handler) renderConsent(ctx *gin.Context, req authorizeRequest) error {
+	token, err := h.csrfToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	return render(ctx, http.StatusOK, "consent.html", consentPage{
+		ClientName: clientName(req.Client),
+		Scopes:     req.Scopes,
+		Request:    req.Query.Encode(),
+		CSRFToken:  token,
+	})
+}
+
+func (h Handler) renderDevice(ctx *gin.Context, status int, page devicePage) error {
+	token, err := h.csrfToken(ctx)
+	if err != nil {
+		return err
+	}
+	page.CSRFToken = token
+
+	return render(ctx, status, "device.html", page)
+}
+
+// renderError renders HTTP errors as a page and leaves other errors to httpserver.ErrorHandler.
+func (h Handler) renderError(ctx *gin.Context, err error) error {
+	var httpErr *httpserver.HTTPError
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+
+	return render(ctx, httpErr.Code, "error.html", errorPage{
+		Error:       httpErr.Message,
+		Description: httpErr.Detail,
+		RequestID:   httpserver.RequestID(ctx),
+	})
+}
+
+func render(ctx *gin.Context, status int, name string, data interface{}) error {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("X-Frame-Options", "DENY")
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	ctx.Status(status)
+
+	return templates.ExecuteTemplate(ctx.Writer, name, data)
+}
+
+func clientName(cl client.Client) string {
+	if cl.Name != "" {
+		return cl.Name
+	}
+
+	return cl.ID
+}
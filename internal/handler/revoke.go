@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/refresh"
+)
+
+const (
+	PathRevoke = "/revoke"
+)
+
+// Revoke ignores tokens that are invalid already, as RFC 7009 requires.
+func (h Handler) Revoke() gin.HandlerFunc {
+	return httpserver.ErrorHandler(func(ctx *gin.Context) error {
+		var req revocationRequest
+		if err := ctx.ShouldBind(&req); err != nil || req.Token == "" {
+			return errInvalidRequest
+		}
+
+		cl, err := h.authenticateClient(ctx, req.clientCredentials)
+		if err != nil {
+			return err
+		}
+
+		if err := h.revoke(ctx, cl, req.Token); err != nil {
+			return err
+		}
+
+		ctx.Status(http.StatusOK)
+		return nil
+	})
+}
+
+// revoke looks the token up as a refresh token first, so the token type hint is not needed.
+func (h Handler) revoke(ctx context.Context, cl client.Client, token string) error {
+	rt, err := h.refreshTokens.Get(ctx, token)
+	switch {
+	case err == nil:
+		if rt.ClientID != cl.ID {
+			return errRevokeForeignToken
+		}
+		return h.refreshTokens.Revoke(ctx, rt)
+	case !errors.Is(err, refresh.ErrNotFound):
+		return err
+	}
+
+	claims, err := h.verifyAccessToken(ctx, token)
+	if errors.Is(err, errInvalidToken) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if claims.Raw["azp"] != cl.ID {
+		return errRevokeForeignToken
+	}
+
+	// Tokens issued before access tokens had an ID cannot be revoked.
+	if claims.ID == "" {
+		return errUnsupportedRevocation
+	}
+
+	return h.revocations.Revoke(ctx, claims.ID, time.Unix(claims.ExpiresAt, 0))
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+)
+
+const (
+	tokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	tokenTypeJWT         = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// Sender-constrained subject and actor tokens can only be exchanged by their holder, for a token
+// bound to the same key or certificate.
+func (h Handler) tokenExchangeGrant(ctx *gin.Context, cl client.Client, req generateTokenRequest, cnf confirmation) (tokenGrant, error) {
+	if req.RequestedTokenType != "" && req.RequestedTokenType != tokenTypeAccessToken && req.RequestedTokenType != tokenTypeJWT {
+		return tokenGrant{}, errUnsupportedTokenType
+	}
+
+	subject, err := h.verifyExchangedToken(ctx, req.SubjectToken, req.SubjectTokenType)
+	if err != nil || !keepsBinding(subject.Cnf, cnf) {
+		return tokenGrant{}, errInvalidSubjectToken
+	}
+
+	targets := append(slices.Clone(req.Audience), req.Resource...)
+	if len(targets) != 1 {
+		return tokenGrant{}, errInvalidTarget
+	}
+
+	rule, ok := cl.TokenExchangeRule(subject.Audience, targets[0])
+	if !ok {
+		return tokenGrant{}, errInvalidTarget
+	}
+
+	scopes := strings.Fields(subject.Scope)
+	if req.Scope != "" {
+		requested := strings.Fields(req.Scope)
+		for _, scope := range requested {
+			if !slices.Contains(scopes, scope) {
+				return tokenGrant{}, errInvalidScope
+			}
+		}
+		scopes = requested
+	}
+
+	claims := h.accessTokenClaims(subject.Subject, cl.ID, scopes)
+	claims["aud"] = targets[0]
+	if exp := claims["exp"].(int64); subject.ExpiresAt < exp {
+		claims["exp"] = subject.ExpiresAt
+	}
+
+	switch {
+	case req.ActorToken != "":
+		actor, err := h.verifyExchangedToken(ctx, req.ActorToken, req.ActorTokenType)
+		if err != nil || !keepsBinding(actor.Cnf, cnf) {
+			return tokenGrant{}, errInvalidActorToken
+		}
+
+		if mayAct, ok := subject.MayAct["sub"]; ok && mayAct != actor.Subject {
+			return tokenGrant{}, errInvalidActorToken
+		}
+
+		claims["act"] = actClaim(actor.Subject, subject.Act)
+	case !rule.Impersonation:
+		claims["act"] = actClaim(cl.ID+"@clients", subject.Act)
+	case subject.Act != nil:
+		claims["act"] = subject.Act
+	}
+
+	return tokenGrant{
+		Claims:          claims,
+		IssuedTokenType: tokenTypeAccessToken,
+	}, nil
+}
+
+func (h Handler) verifyExchangedToken(ctx context.Context, token, tokenType string) (accessTokenClaimSet, error) {
+	if tokenType != tokenTypeAccessToken && tokenType != tokenTypeJWT {
+		return accessTokenClaimSet{}, errUnsupportedTokenType
+	}
+
+	return h.verifyAccessToken(ctx, token)
+}
+
+func keepsBinding(exchanged, cnf confirmation) bool {
+	return (exchanged.JKT == "" || exchanged.JKT == cnf.JKT) &&
+		(exchanged.X5TS256 == "" || exchanged.X5TS256 == cnf.X5TS256)
+}
+
+func actClaim(actor string, prior map[string]interface{}) map[string]interface{} {
+	act := map[string]interface{}{
+		"sub": actor,
+	}
+
+	if prior != nil {
+		act["act"] = prior
+	}
+
+	return act
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/claimmap"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/httpserver"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/tracing"
+	"github.com/the-witcher-knight/jwt-encryption-server/pkg/tokenhook"
+)
+
+// callTokenHook leaves it to the failure policy of the client whether tokens are issued while the hook
+// is unavailable.
+func (h Handler) callTokenHook(ctx context.Context, grantType string, claims map[string]interface{}) error {
+	if h.tokenHook == nil {
+		return nil
+	}
+
+	cl, attrs, err := h.issuanceAttributes(ctx, claims)
+	if err != nil {
+		return err
+	}
+
+	req := tokenhook.Request{
+		GrantType: grantType,
+		Claims:    claims,
+	}
+	req.Client, _ = attrs["client"].(map[string]interface{})
+	req.User, _ = attrs["user"].(map[string]interface{})
+
+	hookCtx, span := tracing.StartSpan(ctx, "token_hook.call")
+	resp, err := h.tokenHook.Call(hookCtx, req)
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		if cl.TokenHookFailurePolicy == client.TokenHookFailOpen {
+			return nil
+		}
+
+		return errTokenHookUnavailable
+	}
+
+	if resp.Deny {
+		if resp.Reason == "" {
+			return errTokenIssuanceDenied
+		}
+
+		return &httpserver.HTTPError{Code: http.StatusBadRequest, Message: errTokenIssuanceDenied.Message, Detail: resp.Reason}
+	}
+
+	// Like claim mappings, the hook cannot change the reserved claims.
+	for name, value := range resp.Claims {
+		if !slices.Contains(claimmap.Reserved, name) {
+			claims[name] = value
+		}
+	}
+
+	return nil
+}
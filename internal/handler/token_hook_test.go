@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/client"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+	"github.com/the-witcher-knight/jwt-encryption-server/pkg/tokenhook"
+)
+
+type stubHook struct {
+	resp tokenhook.Response
+	err  error
+	req  *tokenhook.Request
+}
+
+func (s stubHook) Call(_ context.Context, req tokenhook.Request) (tokenhook.Response, error) {
+	*s.req = req
+	return s.resp, s.err
+}
+
+func TestCallTokenHook(t *testing.T) {
+	clients := client.NewMemoryRegistry(
+		client.Client{ID: "strict", Secret: "secret", GrantTypes: []string{client.GrantTypeClientCredentials}},
+		client.Client{
+			ID:                     "lenient",
+			Secret:                 "secret",
+			GrantTypes:             []string{client.GrantTypeClientCredentials},
+			TokenHookFailurePolicy: client.TokenHookFailOpen,
+		},
+	)
+
+	tests := []struct {
+		name     string
+		hook     stubHook
+		clientID string
+		// wantCode and wantError describe the response, wantClaims the claims of the token when one is issued.
+		wantCode   int
+		wantError  string
+		wantClaims map[string]interface{}
+	}{
+		{
+			name:       "added claims",
+			hook:       stubHook{resp: tokenhook.Response{Claims: map[string]interface{}{"tier": "gold"}}},
+			clientID:   "strict",
+			wantCode:   http.StatusOK,
+			wantClaims: map[string]interface{}{"tier": "gold", "sub": "strict@clients"},
+		},
+		{
+			name:       "reserved claims are kept",
+			hook:       stubHook{resp: tokenhook.Response{Claims: map[string]interface{}{"sub": "admin", "scope": "admin"}}},
+			clientID:   "strict",
+			wantCode:   http.StatusOK,
+			wantClaims: map[string]interface{}{"sub": "strict@clients", "scope": nil},
+		},
+		{
+			name:      "denied with a reason",
+			hook:      stubHook{resp: tokenhook.Response{Deny: true, Reason: "client is suspended"}},
+			clientID:  "strict",
+			wantCode:  http.StatusBadRequest,
+			wantError: "client is suspended",
+		},
+		{
+			name:      "denied",
+			hook:      stubHook{resp: tokenhook.Response{Deny: true}},
+			clientID:  "strict",
+			wantCode:  http.StatusBadRequest,
+			wantError: errTokenIssuanceDenied.Detail,
+		},
+		{
+			name:      "unavailable, failing closed",
+			hook:      stubHook{err: tokenhook.ErrUnavailable},
+			clientID:  "strict",
+			wantCode:  http.StatusServiceUnavailable,
+			wantError: "temporarily_unavailable",
+		},
+		{
+			name:       "unavailable, failing open",
+			hook:       stubHook{err: tokenhook.ErrUnavailable},
+			clientID:   "lenient",
+			wantCode:   http.StatusOK,
+			wantClaims: map[string]interface{}{"sub": "lenient@clients", "tier": nil},
+		},
+		{
+			// The failure policy only applies to an unavailable hook, not to its decisions.
+			name:      "denied, failing open",
+			hook:      stubHook{resp: tokenhook.Response{Deny: true}},
+			clientID:  "lenient",
+			wantCode:  http.StatusBadRequest,
+			wantError: "access_denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.hook.req = &tokenhook.Request{}
+			h := newTestHandler(t, WithClients(clients), WithTokenHook(tt.hook))
+
+			rec := serveTokenRequest(h, nil, url.Values{
+				"grant_type":    {client.GrantTypeClientCredentials},
+				"client_id":     {tt.clientID},
+				"client_secret": {"secret"},
+			})
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+
+			if req := tt.hook.req; req.GrantType != client.GrantTypeClientCredentials || req.Client["client_id"] != tt.clientID {
+				t.Errorf("hook called with grant %q and client %v", req.GrantType, req.Client["client_id"])
+			}
+
+			if tt.wantError != "" {
+				if !strings.Contains(rec.Body.String(), tt.wantError) {
+					t.Errorf("body = %s, want %q", rec.Body, tt.wantError)
+				}
+				return
+			}
+
+			var resp generateTokenResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("could not decode token response: %v", err)
+			}
+			token, err := jose.Parse(resp.AccessToken)
+			if err != nil {
+				t.Fatalf("could not parse access token: %v", err)
+			}
+			var claims map[string]interface{}
+			if err := token.Claims(&claims); err != nil {
+				t.Fatalf("could not decode access token claims: %v", err)
+			}
+			for name, want := range tt.wantClaims {
+				if claims[name] != want {
+					t.Errorf("%s = %v, want %v", name, claims[name], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+)
+
+// audience is the aud claim, which is either a single string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+
+	return nil
+}
+
+type confirmation struct {
+	X5TS256 string `json:"x5t#S256,omitempty"`
+	JKT     string `json:"jkt,omitempty"`
+}
+
+type accessTokenClaimSet struct {
+	ID        string                 `json:"jti"`
+	Issuer    string                 `json:"iss"`
+	Subject   string                 `json:"sub"`
+	Audience  audience               `json:"aud"`
+	ExpiresAt int64                  `json:"exp"`
+	Scope     string                 `json:"scope"`
+	Act       map[string]interface{} `json:"act"`
+	MayAct    map[string]interface{} `json:"may_act"`
+	Cnf       confirmation           `json:"cnf"`
+	Raw       map[string]interface{} `json:"-"`
+}
+
+// ID tokens, signed with the same key, are told apart from access tokens by their typ header and claims.
+func (h Handler) verifyAccessToken(ctx context.Context, token string) (accessTokenClaimSet, error) {
+	if token == "" {
+		return accessTokenClaimSet{}, errInvalidToken
+	}
+
+	payload, err := h.srv.VerifyToken(token)
+	if err != nil {
+		return accessTokenClaimSet{}, errInvalidToken
+	}
+
+	var claims accessTokenClaimSet
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return accessTokenClaimSet{}, errInvalidToken
+	}
+
+	if err := json.Unmarshal(payload, &claims.Raw); err != nil {
+		return accessTokenClaimSet{}, errInvalidToken
+	}
+
+	if claims.Issuer != h.issuer || time.Now().Unix() >= claims.ExpiresAt {
+		return accessTokenClaimSet{}, errInvalidToken
+	}
+
+	parsed, err := jose.Parse(token)
+	if err != nil || !isAccessTokenType(parsed.Header.Typ) {
+		return accessTokenClaimSet{}, errInvalidToken
+	}
+
+	for _, claim := range []string{"nonce", "at_hash", "c_hash"} {
+		if _, ok := claims.Raw[claim]; ok {
+			return accessTokenClaimSet{}, errInvalidToken
+		}
+	}
+
+	if claims.ID != "" {
+		revoked, err := h.revocations.Revoked(ctx, claims.ID)
+		if err != nil {
+			return accessTokenClaimSet{}, err
+		}
+		if revoked {
+			return accessTokenClaimSet{}, errInvalidToken
+		}
+	}
+
+	return claims, nil
+}
+
+func isAccessTokenType(typ string) bool {
+	typ = strings.TrimPrefix(strings.ToLower(typ), "application/")
+	return typ == accessTokenType || typ == strings.ToLower(jwtType)
+}
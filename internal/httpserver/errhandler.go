@@ -9,12 +9,20 @@ import (
 	"github.com/the-witcher-knight/jwt-encryption-server/internal/tracing"
 )
 
+type errorBody struct {
+	*HTTPError
+	RequestID string `json:"request_id,omitempty"`
+}
+
 func ErrorHandler(fn func(*gin.Context) error) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if err := fn(c); err != nil {
+			// Middlewares inspect the error once the handler returns.
+			_ = c.Error(err)
+
 			var httpErr *HTTPError
 			if errors.As(err, &httpErr) {
-				c.JSON(httpErr.Code, httpErr)
+				c.JSON(httpErr.Code, errorBody{HTTPError: httpErr, RequestID: RequestID(c)})
 				return
 			}
 
@@ -22,6 +30,7 @@ func ErrorHandler(fn func(*gin.Context) error) gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{
 				"error":             "internal server error",
 				"error_description": "internal server error",
+				"request_id":        RequestID(c),
 			})
 		}
 	}
@@ -0,0 +1,28 @@
+package httpserver
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/metrics"
+)
+
+// RequestMetrics labels the latency histogram of requests with their method, route and status code.
+func RequestMetrics(reg *metrics.Registry) gin.HandlerFunc {
+	latency := reg.NewHistogram("serverd_http_request_duration_seconds", "Time spent handling requests.",
+		metrics.DefaultBuckets, "method", "route", "status")
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Requests for unknown paths share a route, so arbitrary paths do not create series.
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		latency.ObserveSince(start, c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+	}
+}
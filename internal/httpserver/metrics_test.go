@@ -0,0 +1,41 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/metrics"
+)
+
+func TestRequestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := metrics.NewRegistry()
+
+	router := gin.New()
+	router.Use(RequestMetrics(reg))
+	router.GET("/clients/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for _, path := range []string{"/clients/a", "/clients/b", "/random/1", "/random/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Series are labeled with the route, not the path, so paths do not create series.
+	for _, want := range []string{
+		`serverd_http_request_duration_seconds_count{method="GET",route="/clients/:id",status="204"} 2`,
+		`serverd_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 2`,
+	} {
+		if !strings.Contains(rec.Body.String(), want+"\n") {
+			t.Errorf("exposition lacks %s:\n%s", want, rec.Body)
+		}
+	}
+	if strings.Contains(rec.Body.String(), "/random") {
+		t.Errorf("exposition has a series of an unmatched path:\n%s", rec.Body)
+	}
+}
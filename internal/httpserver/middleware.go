@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"runtime/debug"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -13,10 +14,34 @@ import (
 
 func rootMiddleware(rootCtx context.Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// The ID correlates the logs of the request with the response the caller got.
+		reqID := requestID(c)
+		c.Set(contextKeyRequestID, reqID)
+		c.Header(HeaderRequestID, reqID)
+
+		// The request continues the trace of the caller, if any.
+		spanName := c.Request.Method
+		if route := c.FullPath(); route != "" {
+			spanName += " " + route
+		}
+		spanCtx, span := tracing.FromContext(rootCtx).StartServerSpan(tracing.Extract(c.Request.Context(), c.Request.Header), spanName,
+			tracing.String("http.request.method", c.Request.Method),
+			tracing.String("http.request.id", reqID),
+			tracing.String("http.route", c.FullPath()),
+			tracing.String("url.path", c.Request.URL.Path),
+			tracing.String("server.address", c.Request.Host),
+			tracing.String("client.address", c.ClientIP()),
+			tracing.String("user_agent.original", c.Request.UserAgent()),
+		)
+		defer span.End()
+
+		start := time.Now()
 		tracer := tracing.FromContext(rootCtx).WithAttributes(
+			tracing.String("http.request.id", reqID),
 			tracing.String("host.name", c.Request.Host),
-			tracing.String("url.path", c.Request.URL.String()),
-			tracing.String("url.query", c.Request.URL.RawQuery),
+			// Secrets such as codes or client secrets may be sent as query parameters.
+			tracing.String("url.path", c.Request.URL.Path),
+			tracing.String("url.query", tracing.RedactQuery(c.Request.URL.RawQuery)),
 			tracing.String("http.request.method", c.Request.Method),
 			tracing.Int("http.request.body.size", int(c.Request.ContentLength)),
 			tracing.String("http.request.proto", c.Request.Proto),
@@ -31,25 +56,32 @@ func rootMiddleware(rootCtx context.Context) gin.HandlerFunc {
 					err = fmt.Errorf("%v", p)
 				}
 
-				tracer.Error(err, "caught a panic", debug.Stack())
+				tracer.Error(err, "caught a panic: %s", debug.Stack())
+				span.RecordError(err)
+				span.SetAttributes(tracing.Int("http.response.status_code", http.StatusInternalServerError))
 				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 					"error":             "internal server error",
 					"error_description": "internal server error",
+					"request_id":        reqID,
 				})
 			}
 		}()
 
-		updateReqCtx := func(ctx context.Context) context.Context {
-			return tracing.SetInContext(ctx, tracer)
-		}
-		c.Request.WithContext(updateReqCtx(c.Request.Context()))
+		// Handlers log with the tracer of the request and trace within its span.
+		c.Request = c.Request.WithContext(tracing.SetInContext(spanCtx, tracer))
 
 		// Go next step
 		c.Next()
 
+		span.SetAttributes(tracing.Int("http.response.status_code", c.Writer.Status()))
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, http.StatusText(c.Writer.Status()))
+		}
+
 		tracer.WithAttributes(
 			tracing.Int("http.response.status_code", c.Writer.Status()),
 			tracing.Int("http.response.body.size", c.Writer.Size()),
+			tracing.Duration("http.server.request.duration", time.Since(start)),
 		).Info("request handled successfully")
 	}
 }
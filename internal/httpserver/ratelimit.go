@@ -0,0 +1,201 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+const (
+	invalidClient = "invalid_client"
+)
+
+var (
+	errTooManyRequests = &HTTPError{Code: http.StatusTooManyRequests, Message: "too_many_requests", Detail: "too many requests, retry later"}
+)
+
+// Limit is a token bucket of Burst tokens refilled with Rate tokens per second; a zero Rate disables it.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) interval() time.Duration {
+	return max(time.Duration(float64(time.Second)/l.Rate), time.Microsecond)
+}
+
+type FailurePolicy struct {
+	Threshold int
+	// BaseDelay is doubled by each failure after the threshold.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// ResetAfter forgets the failures of a key this long after the first one.
+	ResetAfter time.Duration
+}
+
+func (p FailurePolicy) blockDuration(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+
+	d := p.BaseDelay
+	for i := p.Threshold; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+
+	return min(d, p.MaxDelay)
+}
+
+type RateLimitConfig struct {
+	// Scope separates the client IDs of issuers sharing a store.
+	Scope     string
+	PerClient Limit
+	PerIP     Limit
+	Failures  FailurePolicy
+}
+
+// RateLimitStore shares the limits of the replicas using it.
+type RateLimitStore interface {
+	// Take returns how long until a request is allowed again when the limit is reached.
+	Take(ctx context.Context, key string, limit Limit) (time.Duration, error)
+	Blocked(ctx context.Context, key string) (time.Duration, error)
+	Fail(ctx context.Context, key string, policy FailurePolicy) error
+	// Reset forgets the failures of key. A block already in force is left to expire: a success racing
+	// failures that blocked the key must not lift the block.
+	Reset(ctx context.Context, key string) error
+}
+
+// RateLimit counts invalid_client failures against both the source IP and the client ID. The source IP is
+// the address of the peer; behind a proxy, the limit applies to the proxy.
+func RateLimit(store RateLimitStore, cfg RateLimitConfig) gin.HandlerFunc {
+	return ErrorHandler(func(c *gin.Context) error {
+		keys := []string{"ip:" + c.RemoteIP()}
+		limits := []Limit{cfg.PerIP}
+		clientID := requestClientID(c)
+		if clientID != "" {
+			keys = append(keys, "client:"+cfg.Scope+":"+clientID)
+			limits = append(limits, cfg.PerClient)
+		}
+
+		var wait time.Duration
+		for i, key := range keys {
+			blocked, err := store.Blocked(c, key)
+			if err != nil {
+				return err
+			}
+
+			if blocked == 0 && limits[i].Rate > 0 {
+				if blocked, err = store.Take(c, key, limits[i]); err != nil {
+					return err
+				}
+			}
+
+			wait = max(wait, blocked)
+		}
+
+		if wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.Abort()
+			return errTooManyRequests
+		}
+
+		c.Next()
+
+		switch {
+		case failedWith(c, invalidClient):
+			for _, key := range keys {
+				if err := store.Fail(c, key, cfg.Failures); err != nil {
+					return err
+				}
+			}
+		case clientID != "" && c.Writer.Status() < http.StatusBadRequest:
+			// Only the client is reset, a client of the attacker must not unblock the address.
+			return store.Reset(c, keys[1])
+		}
+
+		return nil
+	})
+}
+
+// requestClientID returns the client a request claims to come from, before it is authenticated.
+func requestClientID(c *gin.Context) string {
+	if id, _, ok := c.Request.BasicAuth(); ok {
+		return id
+	}
+
+	if id := c.PostForm("client_id"); id != "" {
+		return id
+	}
+
+	if assertion := c.PostForm("client_assertion"); assertion != "" {
+		if token, err := jose.Parse(assertion); err == nil {
+			var claims struct {
+				Issuer string `json:"iss"`
+			}
+			if token.Claims(&claims) == nil {
+				return claims.Issuer
+			}
+		}
+	}
+
+	return ""
+}
+
+func failedWith(c *gin.Context, message string) bool {
+	for _, e := range c.Errors {
+		var httpErr *HTTPError
+		if errors.As(e.Err, &httpErr) && httpErr.Message == message {
+			return true
+		}
+	}
+
+	return false
+}
+
+type rateLimitStore struct {
+	kv storage.Store
+}
+
+func NewRateLimitStore(kv storage.Store) RateLimitStore {
+	return rateLimitStore{kv: kv}
+}
+
+func (s rateLimitStore) Take(ctx context.Context, key string, limit Limit) (time.Duration, error) {
+	return s.kv.Throttle(ctx, "ratelimit:bucket:"+key, limit.interval(), limit.Burst)
+}
+
+func (s rateLimitStore) Blocked(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.kv.TTL(ctx, "ratelimit:blocked:"+key)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(ttl, 0), nil
+}
+
+func (s rateLimitStore) Fail(ctx context.Context, key string, policy FailurePolicy) error {
+	n, err := s.kv.Incr(ctx, "ratelimit:failures:"+key, policy.ResetAfter)
+	if err != nil {
+		return err
+	}
+
+	if d := policy.blockDuration(int(n)); d > 0 {
+		return s.kv.Set(ctx, "ratelimit:blocked:"+key, nil, d)
+	}
+
+	return nil
+}
+
+func (s rateLimitStore) Reset(ctx context.Context, key string) error {
+	// ratelimit:blocked: is kept, see RateLimitStore.
+	_, err := s.kv.Delete(ctx, "ratelimit:failures:"+key)
+	return err
+}
@@ -0,0 +1,307 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+func TestRateLimitStoreReset(t *testing.T) {
+	ctx := context.Background()
+	store := NewRateLimitStore(storage.NewMemoryStore())
+	policy := FailurePolicy{Threshold: 2, BaseDelay: 50 * time.Millisecond, MaxDelay: time.Second, ResetAfter: time.Hour}
+
+	for i := 0; i < 2; i++ {
+		if err := store.Fail(ctx, "client", policy); err != nil {
+			t.Fatalf("Fail() = %v", err)
+		}
+	}
+	if err := store.Reset(ctx, "client"); err != nil {
+		t.Fatalf("Reset() = %v", err)
+	}
+
+	// The block stays in force.
+	blocked, err := store.Blocked(ctx, "client")
+	if err != nil || blocked <= 0 {
+		t.Fatalf("Blocked() after Reset() = %v, %v, want the block to remain", blocked, err)
+	}
+	time.Sleep(blocked)
+
+	// The failures are forgotten: one more failure is below the threshold again instead of doubling the block.
+	if err := store.Fail(ctx, "client", policy); err != nil {
+		t.Fatalf("Fail() = %v", err)
+	}
+	if blocked, err := store.Blocked(ctx, "client"); err != nil || blocked != 0 {
+		t.Errorf("Blocked() after a failure following Reset() = %v, %v, want 0", blocked, err)
+	}
+}
+
+// newRateLimitedServer authenticates clients by the secret "secret".
+func newRateLimitedServer(store RateLimitStore, cfg RateLimitConfig) http.Handler {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/token", RateLimit(store, cfg), ErrorHandler(func(c *gin.Context) error {
+		if _, secret, _ := c.Request.BasicAuth(); secret != "secret" && c.PostForm("client_secret") != "secret" {
+			return &HTTPError{Code: http.StatusUnauthorized, Message: invalidClient}
+		}
+
+		c.Status(http.StatusOK)
+		return nil
+	}))
+
+	return router
+}
+
+func serveClient(srv http.Handler, ip, clientID string, fail bool) *httptest.ResponseRecorder {
+	form := url.Values{}
+	if clientID != "" {
+		form.Set("client_id", clientID)
+	}
+	form.Set("client_secret", "secret")
+	if fail {
+		form.Set("client_secret", "wrong")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = ip + ":40000"
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	return rec
+}
+
+type rateLimitRequest struct {
+	ip, clientID string
+	fail         bool
+	wantCode     int
+}
+
+func TestRateLimit(t *testing.T) {
+	unlimited := Limit{Rate: 1000, Burst: 1000}
+	slow := Limit{Rate: 0.5, Burst: 2}
+
+	tests := []struct {
+		name string
+		cfg  RateLimitConfig
+		// requests are sent in order.
+		requests []rateLimitRequest
+	}{
+		{
+			name: "per address",
+			cfg:  RateLimitConfig{PerIP: slow, PerClient: unlimited},
+			requests: []rateLimitRequest{
+				{ip: "192.0.2.1", clientID: "a", wantCode: http.StatusOK},
+				{ip: "192.0.2.1", clientID: "b", wantCode: http.StatusOK},
+				{ip: "192.0.2.1", clientID: "c", wantCode: http.StatusTooManyRequests},
+				{ip: "192.0.2.1", wantCode: http.StatusTooManyRequests},
+				{ip: "192.0.2.2", clientID: "c", wantCode: http.StatusOK},
+			},
+		},
+		{
+			name: "per client",
+			cfg:  RateLimitConfig{PerIP: unlimited, PerClient: slow},
+			requests: []rateLimitRequest{
+				{ip: "192.0.2.1", clientID: "a", wantCode: http.StatusOK},
+				{ip: "192.0.2.2", clientID: "a", wantCode: http.StatusOK},
+				{ip: "192.0.2.3", clientID: "a", wantCode: http.StatusTooManyRequests},
+				{ip: "192.0.2.1", clientID: "b", wantCode: http.StatusOK},
+				{ip: "192.0.2.1", wantCode: http.StatusOK},
+			},
+		},
+		{
+			name: "disabled",
+			cfg:  RateLimitConfig{PerClient: Limit{Burst: 1}},
+			requests: []rateLimitRequest{
+				{ip: "192.0.2.1", clientID: "a", wantCode: http.StatusOK},
+				{ip: "192.0.2.1", clientID: "a", wantCode: http.StatusOK},
+				{ip: "192.0.2.1", clientID: "a", wantCode: http.StatusOK},
+			},
+		},
+		{
+			name: "failures block the address and the client",
+			cfg: RateLimitConfig{
+				PerIP:     unlimited,
+				PerClient: unlimited,
+				Failures:  FailurePolicy{Threshold: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour},
+			},
+			requests: []rateLimitRequest{
+				{ip: "192.0.2.1", clientID: "a", fail: true, wantCode: http.StatusUnauthorized},
+				{ip: "192.0.2.1", clientID: "a", fail: true, wantCode: http.StatusUnauthorized},
+				{ip: "192.0.2.1", clientID: "a", wantCode: http.StatusTooManyRequests},
+				{ip: "192.0.2.1", clientID: "b", wantCode: http.StatusTooManyRequests},
+				{ip: "192.0.2.2", clientID: "a", wantCode: http.StatusTooManyRequests},
+				{ip: "192.0.2.2", clientID: "b", wantCode: http.StatusOK},
+			},
+		},
+		{
+			name: "a success of the client does not reset the address",
+			cfg: RateLimitConfig{
+				PerIP:     unlimited,
+				PerClient: unlimited,
+				Failures:  FailurePolicy{Threshold: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour},
+			},
+			requests: []rateLimitRequest{
+				{ip: "192.0.2.1", clientID: "victim", fail: true, wantCode: http.StatusUnauthorized},
+				{ip: "192.0.2.1", clientID: "own", wantCode: http.StatusOK},
+				{ip: "192.0.2.1", clientID: "other-victim", fail: true, wantCode: http.StatusUnauthorized},
+				{ip: "192.0.2.1", clientID: "own", wantCode: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "a success resets the failures of the client",
+			cfg: RateLimitConfig{
+				PerIP:     unlimited,
+				PerClient: unlimited,
+				Failures:  FailurePolicy{Threshold: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour},
+			},
+			requests: []rateLimitRequest{
+				{ip: "192.0.2.1", clientID: "a", fail: true, wantCode: http.StatusUnauthorized},
+				{ip: "192.0.2.2", clientID: "a", wantCode: http.StatusOK},
+				{ip: "192.0.2.3", clientID: "a", fail: true, wantCode: http.StatusUnauthorized},
+				{ip: "192.0.2.4", clientID: "a", wantCode: http.StatusOK},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newRateLimitedServer(NewRateLimitStore(storage.NewMemoryStore()), tt.cfg)
+
+			for i, r := range tt.requests {
+				rec := serveClient(srv, r.ip, r.clientID, r.fail)
+				if rec.Code != r.wantCode {
+					t.Fatalf("request %d of %s from %s: status = %d, want %d", i+1, r.clientID, r.ip, rec.Code, r.wantCode)
+				}
+
+				retryAfter := rec.Header().Get("Retry-After")
+				if r.wantCode != http.StatusTooManyRequests {
+					if retryAfter != "" {
+						t.Errorf("request %d: Retry-After = %q on an allowed request", i+1, retryAfter)
+					}
+					continue
+				}
+				if seconds, err := strconv.Atoi(retryAfter); err != nil || seconds < 1 {
+					t.Errorf("request %d: Retry-After = %q, want a number of seconds", i+1, retryAfter)
+				}
+				if !strings.Contains(rec.Body.String(), "too_many_requests") {
+					t.Errorf("request %d: body = %s, want too_many_requests", i+1, rec.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	srv := newRateLimitedServer(NewRateLimitStore(storage.NewMemoryStore()), RateLimitConfig{
+		PerIP:     Limit{Rate: 1000, Burst: 1000},
+		PerClient: Limit{Rate: 1.0 / 30, Burst: 1},
+		Failures:  FailurePolicy{Threshold: 1, BaseDelay: 2 * time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour},
+	})
+
+	// The longest wait of the address and the client is announced, rounded up to a second.
+	serveClient(srv, "192.0.2.1", "a", false)
+	if rec := serveClient(srv, "192.0.2.1", "a", false); rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("Retry-After of an empty bucket = %q, want 30", rec.Header().Get("Retry-After"))
+	}
+
+	serveClient(srv, "192.0.2.2", "b", true)
+	if rec := serveClient(srv, "192.0.2.2", "c", false); rec.Header().Get("Retry-After") != "120" {
+		t.Errorf("Retry-After of a blocked address = %q, want 120", rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimitExponentialBlock(t *testing.T) {
+	ctx := context.Background()
+	store := NewRateLimitStore(storage.NewMemoryStore())
+	srv := newRateLimitedServer(store, RateLimitConfig{
+		Scope:     "tenant",
+		PerIP:     Limit{Rate: 1000, Burst: 1000},
+		PerClient: Limit{Rate: 1000, Burst: 1000},
+		Failures:  FailurePolicy{Threshold: 2, BaseDelay: 40 * time.Millisecond, MaxDelay: 100 * time.Millisecond, ResetAfter: time.Hour},
+	})
+
+	// Each failure past the threshold doubles the block of the address and the client, up to the maximum.
+	for i, want := range []time.Duration{0, 40 * time.Millisecond, 80 * time.Millisecond, 100 * time.Millisecond} {
+		if rec := serveClient(srv, "192.0.2.1", "a", true); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status = %d, want %d", i+1, rec.Code, http.StatusUnauthorized)
+		}
+
+		for _, key := range []string{"ip:192.0.2.1", "client:tenant:a"} {
+			blocked, err := store.Blocked(ctx, key)
+			if err != nil {
+				t.Fatalf("Blocked() = %v", err)
+			}
+			if blocked > want || blocked < want-20*time.Millisecond {
+				t.Errorf("failure %d: %s blocked for %v, want %v", i+1, key, blocked, want)
+			}
+		}
+
+		if want > 0 {
+			time.Sleep(want)
+		}
+	}
+}
+
+func TestFailurePolicyBlockDuration(t *testing.T) {
+	policy := FailurePolicy{Threshold: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	for failures, want := range []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if got := policy.blockDuration(failures); got != want {
+			t.Errorf("blockDuration(%d) = %v, want %v", failures, got, want)
+		}
+	}
+
+	if got := (FailurePolicy{BaseDelay: time.Second, MaxDelay: time.Minute}).blockDuration(100); got != 0 {
+		t.Errorf("blockDuration() without threshold = %v, want 0", got)
+	}
+}
+
+func TestRequestClientID(t *testing.T) {
+	assertion := func(claims string) string {
+		enc := base64.RawURLEncoding.EncodeToString
+		return enc([]byte(`{"alg":"ES256"}`)) + "." + enc([]byte(claims)) + "." + enc([]byte("signature"))
+	}
+
+	tests := []struct {
+		name  string
+		basic string
+		form  url.Values
+		want  string
+	}{
+		{name: "basic authentication", basic: "basic-client", form: url.Values{"client_id": {"form-client"}}, want: "basic-client"},
+		{name: "form", form: url.Values{"client_id": {"form-client"}}, want: "form-client"},
+		{name: "client assertion", form: url.Values{"client_assertion": {assertion(`{"iss":"jwt-client"}`)}}, want: "jwt-client"},
+		{name: "malformed client assertion", form: url.Values{"client_assertion": {"not.a.jwt"}}},
+		{name: "anonymous", form: url.Values{"grant_type": {"client_credentials"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.basic != "" {
+				req.SetBasicAuth(tt.basic, "secret")
+			}
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = req
+
+			if got := requestClientID(c); got != tt.want {
+				t.Errorf("requestClientID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
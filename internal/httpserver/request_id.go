@@ -0,0 +1,34 @@
+package httpserver
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+const HeaderRequestID = "X-Request-ID"
+
+const contextKeyRequestID = "httpserver.request_id"
+
+// validRequestID restricts the IDs accepted from callers to characters that are safe in logs and headers.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._~:@/+=-]{1,128}$`)
+
+func RequestID(c *gin.Context) string {
+	return c.GetString(contextKeyRequestID)
+}
+
+func requestID(c *gin.Context) string {
+	if id := c.GetHeader(HeaderRequestID); validRequestID.MatchString(id) {
+		return id
+	}
+
+	// A version 4 UUID.
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
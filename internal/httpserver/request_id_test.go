@@ -0,0 +1,89 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/tracing"
+)
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	tracer := tracing.New(tracing.WithOutput(&logs))
+	router := NewRouter(tracing.SetInContext(context.Background(), tracer))
+	router.GET("/fail", ErrorHandler(func(*gin.Context) error {
+		return &HTTPError{Code: http.StatusBadRequest, Message: "invalid_request"}
+	}))
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		// wantEcho is set when the ID of the caller is kept, a new one is generated otherwise.
+		wantEcho bool
+	}{
+		{name: "ID of the caller", path: "/fail", header: "req-42:retry/1", wantEcho: true},
+		{name: "longest ID", path: "/fail", header: strings.Repeat("a", 128), wantEcho: true},
+		{name: "no ID", path: "/fail"},
+		{name: "ID too long", path: "/fail", header: strings.Repeat("a", 129)},
+		{name: "ID with a space", path: "/fail", header: "req 42"},
+		{name: "ID with a quote", path: "/fail", header: `req"42`},
+		{name: "ID with a line break", path: "/fail", header: "req\r\n42"},
+		{name: "ID with non-ASCII characters", path: "/fail", header: "réq-42"},
+		{name: "panic", path: "/_/panic", header: "req-panic", wantEcho: true},
+	}
+
+	seen := map[string]bool{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(HeaderRequestID, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(HeaderRequestID)
+			switch {
+			case tt.wantEcho && id != tt.header:
+				t.Fatalf("%s = %q, want the ID of the caller", HeaderRequestID, id)
+			case !tt.wantEcho && !uuidV4.MatchString(id):
+				t.Fatalf("%s = %q, want a generated UUID", HeaderRequestID, id)
+			}
+			if seen[id] {
+				t.Errorf("%s = %q was already used", HeaderRequestID, id)
+			}
+			seen[id] = true
+
+			// Error bodies and logs carry the ID given to the caller.
+			var body struct {
+				RequestID string `json:"request_id"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.RequestID != id {
+				t.Errorf("request_id of the body = %q, %v, want %q", body.RequestID, err, id)
+			}
+
+			if err := tracer.Flush(); err != nil {
+				t.Fatalf("Flush() = %v", err)
+			}
+			var entry struct {
+				RequestID string `json:"http.request.id"`
+			}
+			line, _, _ := strings.Cut(logs.String(), "\n")
+			if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.RequestID != id {
+				t.Errorf("http.request.id of the log = %q, %v, want %q", entry.RequestID, err, id)
+			}
+		})
+	}
+}
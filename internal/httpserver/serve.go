@@ -10,6 +10,8 @@ import (
 func NewRouter(ctx context.Context) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
+	// Handlers pass the gin context on as their context, which must see the span of the request.
+	router.ContextWithFallback = true
 
 	router.Use(rootMiddleware(ctx))
 	blankGroup(router)
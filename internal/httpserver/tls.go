@@ -0,0 +1,156 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// CipherPolicyIntermediate allows TLS 1.2 with forward secret AEAD cipher suites and TLS 1.3.
+	CipherPolicyIntermediate = "intermediate"
+	// CipherPolicyModern only allows TLS 1.3, whose cipher suites are all considered secure.
+	CipherPolicyModern = "modern"
+)
+
+var (
+	tlsVersions = map[string]uint16{
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+)
+
+// TLSConfig negotiates HTTP/2 with ALPN.
+func TLSConfig(minVersion, cipherPolicy string) (*tls.Config, error) {
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS version %q", minVersion)
+	}
+
+	cfg := &tls.Config{
+		MinVersion: version,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	switch cipherPolicy {
+	case CipherPolicyIntermediate:
+		// TLS 1.3 suites are not configurable, these only apply to TLS 1.2.
+		cfg.CipherSuites = []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		}
+	case CipherPolicyModern:
+		if version != tls.VersionTLS13 {
+			return nil, fmt.Errorf("cipher policy %s requires TLS 1.3", CipherPolicyModern)
+		}
+	default:
+		return nil, fmt.Errorf("unknown cipher policy %q", cipherPolicy)
+	}
+
+	return cfg, nil
+}
+
+// CertificateReloader reloads the certificate when the files change, so a renewed one is used without a
+// restart.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	modTime time.Time
+	cert    *tls.Certificate
+}
+
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate keeps serving the previous certificate while the files cannot be loaded, for instance
+// while they are being replaced.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	_ = r.reload()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+func (r *CertificateReloader) reload() error {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	upToDate := modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if upToDate {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("could not load certificate %s: %w", r.certFile, err)
+	}
+
+	r.mu.Lock()
+	r.modTime, r.cert = modTime, &cert
+	r.mu.Unlock()
+
+	return nil
+}
+
+func latestModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// RedirectHandler only redirects GET and HEAD requests: other requests may already have sent
+// credentials in the clear, so they are refused to make the misconfigured client visible.
+func RedirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "use https", http.StatusForbidden)
+			return
+		}
+
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		u := *r.URL
+		u.Scheme, u.Host = "https", host
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+	})
+}
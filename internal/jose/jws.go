@@ -0,0 +1,169 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgRS384 = "RS384"
+	AlgRS512 = "RS512"
+	AlgPS256 = "PS256"
+	AlgPS384 = "PS384"
+	AlgPS512 = "PS512"
+	AlgES256 = "ES256"
+	AlgES384 = "ES384"
+	AlgES512 = "ES512"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrMalformed            = errors.New("malformed jws")
+	ErrUnsupportedAlgorithm = errors.New("unsupported jws algorithm")
+	ErrInvalidSignature     = errors.New("invalid jws signature")
+
+	SupportedAlgorithms = []string{
+		AlgRS256, AlgRS384, AlgRS512, AlgPS256, AlgPS384, AlgPS512, AlgES256, AlgES384, AlgES512, AlgEdDSA,
+	}
+)
+
+type Header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+	JWK *JWK   `json:"jwk,omitempty"`
+}
+
+// Token is a parsed JWS whose signature has not been verified yet.
+type Token struct {
+	Header  Header
+	Payload []byte
+
+	signingInput string
+	signature    []byte
+}
+
+func Parse(compact string) (Token, error) {
+	parts := strings.Split(compact, ".")
+	if len(parts) != 3 {
+		return Token{}, ErrMalformed
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Token{}, ErrMalformed
+	}
+
+	var header Header
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return Token{}, ErrMalformed
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Token{}, ErrMalformed
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Token{}, ErrMalformed
+	}
+
+	return Token{
+		Header:       header,
+		Payload:      payload,
+		signingInput: parts[0] + "." + parts[1],
+		signature:    signature,
+	}, nil
+}
+
+func (t Token) Claims(v interface{}) error {
+	if err := json.Unmarshal(t.Payload, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	return nil
+}
+
+// Verify never accepts symmetric or unsigned algorithms.
+func (t Token) Verify(key crypto.PublicKey) error {
+	hash, err := hashFor(t.Header.Alg)
+	if err != nil {
+		return err
+	}
+
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write([]byte(t.signingInput))
+		digest = h.Sum(nil)
+	}
+
+	switch t.Header.Alg {
+	case AlgRS256, AlgRS384, AlgRS512:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(pub, hash, digest, t.signature) != nil {
+			return ErrInvalidSignature
+		}
+	case AlgPS256, AlgPS384, AlgPS512:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPSS(pub, hash, digest, t.signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) != nil {
+			return ErrInvalidSignature
+		}
+	case AlgES256, AlgES384, AlgES512:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrInvalidSignature
+		}
+
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(t.signature) != 2*size || hash.Size()*8 != curveHashBits(pub) {
+			return ErrInvalidSignature
+		}
+
+		r := new(big.Int).SetBytes(t.signature[:size])
+		s := new(big.Int).SetBytes(t.signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return ErrInvalidSignature
+		}
+	case AlgEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(pub, []byte(t.signingInput), t.signature) {
+			return ErrInvalidSignature
+		}
+	}
+
+	return nil
+}
+
+func hashFor(alg string) (crypto.Hash, error) {
+	switch alg {
+	case AlgRS256, AlgPS256, AlgES256:
+		return crypto.SHA256, nil
+	case AlgRS384, AlgPS384, AlgES384:
+		return crypto.SHA384, nil
+	case AlgRS512, AlgPS512, AlgES512:
+		return crypto.SHA512, nil
+	case AlgEdDSA:
+		return 0, nil
+	default:
+		return 0, ErrUnsupportedAlgorithm
+	}
+}
+
+func curveHashBits(pub *ecdsa.PublicKey) int {
+	if bits := pub.Curve.Params().BitSize; bits != 521 {
+		return bits
+	}
+
+	return 512
+}
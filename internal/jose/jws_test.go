@@ -0,0 +1,237 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	otherRSAKey := newRSAKey(t)
+	p256Key := newECKey(t, elliptic.P256())
+	p384Key := newECKey(t, elliptic.P384())
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate Ed25519 key: %v", err)
+	}
+
+	// A token signed with HMAC, using the public key the verifier holds as the secret.
+	rsaPublicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("could not marshal public key: %v", err)
+	}
+	hmacInput := encodeSegment(t, Header{Alg: "HS256"}) + "." + encodeSegment(t, map[string]string{"sub": "alice"})
+	mac := hmac.New(sha256.New, rsaPublicDER)
+	mac.Write([]byte(hmacInput))
+	hmacToken := hmacInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	tampered := sign(t, AlgRS256, rsaKey, Header{})
+	parts := strings.Split(tampered, ".")
+	parts[1] = encodeSegment(t, map[string]string{"sub": "mallory"})
+	tampered = strings.Join(parts, ".")
+
+	tests := []struct {
+		name    string
+		token   string
+		key     crypto.PublicKey
+		wantErr error
+	}{
+		{name: "RS256", token: sign(t, AlgRS256, rsaKey, Header{}), key: &rsaKey.PublicKey},
+		{name: "PS256", token: sign(t, AlgPS256, rsaKey, Header{}), key: &rsaKey.PublicKey},
+		{name: "ES256", token: sign(t, AlgES256, p256Key, Header{}), key: &p256Key.PublicKey},
+		{name: "ES384", token: sign(t, AlgES384, p384Key, Header{}), key: &p384Key.PublicKey},
+		{name: "EdDSA", token: sign(t, AlgEdDSA, edKey, Header{}), key: edKey.Public()},
+		{
+			name:    "unsigned",
+			token:   encodeSegment(t, Header{Alg: "none"}) + "." + encodeSegment(t, map[string]string{"sub": "alice"}) + ".",
+			key:     &rsaKey.PublicKey,
+			wantErr: ErrUnsupportedAlgorithm,
+		},
+		{name: "HMAC with the public key as secret", token: hmacToken, key: &rsaKey.PublicKey, wantErr: ErrUnsupportedAlgorithm},
+		{name: "another key", token: sign(t, AlgRS256, rsaKey, Header{}), key: &otherRSAKey.PublicKey, wantErr: ErrInvalidSignature},
+		{name: "tampered payload", token: tampered, key: &rsaKey.PublicKey, wantErr: ErrInvalidSignature},
+		{name: "RSA algorithm with an EC key", token: sign(t, AlgRS256, rsaKey, Header{}), key: &p256Key.PublicKey, wantErr: ErrInvalidSignature},
+		{name: "ES256 with a P-384 key", token: sign(t, AlgES256, p384Key, Header{}), key: &p384Key.PublicKey, wantErr: ErrInvalidSignature},
+		{name: "EdDSA with an RSA key", token: sign(t, AlgEdDSA, edKey, Header{}), key: &rsaKey.PublicKey, wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := Parse(tt.token)
+			if err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+
+			if err := token.Verify(tt.key); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	valid := sign(t, AlgRS256, newRSAKey(t), Header{Kid: "k1", Typ: "JWT"})
+
+	token, err := Parse(valid)
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	var claims map[string]string
+	if err := token.Claims(&claims); err != nil || claims["sub"] != "alice" {
+		t.Errorf("Claims() = %v, %v", claims, err)
+	}
+	if token.Header.Kid != "k1" || token.Header.Typ != "JWT" {
+		t.Errorf("Header = %+v", token.Header)
+	}
+
+	parts := strings.Split(valid, ".")
+	for name, compact := range map[string]string{
+		"two segments":         parts[0] + "." + parts[1],
+		"four segments":        valid + ".x",
+		"header not base64url": "!." + parts[1] + "." + parts[2],
+		"header not JSON":      base64.RawURLEncoding.EncodeToString([]byte("alg")) + "." + parts[1] + "." + parts[2],
+		"payload not base64":   parts[0] + ".!." + parts[2],
+		"signature not base64": parts[0] + "." + parts[1] + ".!",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(compact); !errors.Is(err, ErrMalformed) {
+				t.Errorf("Parse() = %v, want %v", err, ErrMalformed)
+			}
+		})
+	}
+}
+
+func TestJWKSVerify(t *testing.T) {
+	key1, key2 := newRSAKey(t), newRSAKey(t)
+	set := JWKS{Keys: []JWK{rsaJWK(&key1.PublicKey, "k1"), rsaJWK(&key2.PublicKey, "k2")}}
+
+	encryption := rsaJWK(&key1.PublicKey, "enc")
+	encryption.Use = "enc"
+	restricted := rsaJWK(&key1.PublicKey, "ps")
+	restricted.Alg = AlgPS256
+
+	tests := []struct {
+		name    string
+		set     JWKS
+		token   string
+		wantErr error
+	}{
+		{name: "key named by kid", set: set, token: sign(t, AlgRS256, key2, Header{Kid: "k2"})},
+		{name: "any key without kid", set: set, token: sign(t, AlgRS256, key2, Header{})},
+		{name: "unknown kid", set: set, token: sign(t, AlgRS256, key1, Header{Kid: "k3"}), wantErr: ErrKeyNotFound},
+		{name: "kid of another key", set: set, token: sign(t, AlgRS256, key1, Header{Kid: "k2"}), wantErr: ErrInvalidSignature},
+		{name: "encryption key", set: JWKS{Keys: []JWK{encryption}}, token: sign(t, AlgRS256, key1, Header{}), wantErr: ErrKeyNotFound},
+		{name: "algorithm the key is not for", set: JWKS{Keys: []JWK{restricted}}, token: sign(t, AlgRS256, key1, Header{}), wantErr: ErrKeyNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := Parse(tt.token)
+			if err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+
+			if err := tt.set.Verify(token); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate RSA key: %v", err)
+	}
+	return key
+}
+
+func newECKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate EC key: %v", err)
+	}
+	return key
+}
+
+func rsaJWK(pub *rsa.PublicKey, kid string) JWK {
+	return JWK{
+		Kty: "RSA",
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("could not marshal %v: %v", v, err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func sign(t *testing.T, alg string, key crypto.Signer, header Header) string {
+	t.Helper()
+
+	header.Alg = alg
+	input := encodeSegment(t, header) + "." + encodeSegment(t, map[string]string{"sub": "alice"})
+
+	hash, _ := hashFor(alg)
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write([]byte(input))
+		digest = h.Sum(nil)
+	}
+
+	var (
+		signature []byte
+		err       error
+	)
+	switch alg {
+	case AlgEdDSA:
+		signature, err = key.Sign(rand.Reader, []byte(input), crypto.Hash(0))
+	case AlgPS256:
+		signature, err = key.Sign(rand.Reader, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash})
+	case AlgES256, AlgES384:
+		signature, err = rawECDSASignature(key.(*ecdsa.PrivateKey), digest)
+	default:
+		signature, err = key.Sign(rand.Reader, digest, hash)
+	}
+	if err != nil {
+		t.Fatalf("could not sign token: %v", err)
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// rawECDSASignature signs the digest, writing the signature as r || s rather than ASN.1 like crypto.Signer.
+func rawECDSASignature(key *ecdsa.PrivateKey, digest []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	size := (key.Curve.Params().BitSize + 7) / 8
+	return append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...), nil
+}
@@ -0,0 +1,110 @@
+package jose
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	maxJWKSSize = 1 << 20
+)
+
+type RemoteKeySets struct {
+	client *http.Client
+	ttl    time.Duration
+	// minRefresh bounds how often a cached set is refetched because a key was not found in it.
+	minRefresh time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedKeySet
+}
+
+type cachedKeySet struct {
+	jwks      JWKS
+	fetchedAt time.Time
+}
+
+func NewRemoteKeySets(client *http.Client, ttl time.Duration) *RemoteKeySets {
+	return &RemoteKeySets{
+		client:     client,
+		ttl:        ttl,
+		minRefresh: time.Minute,
+		cache:      map[string]cachedKeySet{},
+	}
+}
+
+// A cached set without a matching key is refetched, so rotated keys are picked up before the cache expires.
+func (r *RemoteKeySets) Verify(ctx context.Context, uri string, t Token) error {
+	jwks, fetchedAt, err := r.get(ctx, uri, false)
+	if err != nil {
+		return err
+	}
+
+	err = jwks.Verify(t)
+	if err != ErrKeyNotFound || time.Since(fetchedAt) < r.minRefresh {
+		return err
+	}
+
+	if jwks, _, err = r.get(ctx, uri, true); err != nil {
+		return err
+	}
+
+	return jwks.Verify(t)
+}
+
+func (r *RemoteKeySets) Get(ctx context.Context, uri string) (JWKS, error) {
+	jwks, _, err := r.get(ctx, uri, false)
+	return jwks, err
+}
+
+func (r *RemoteKeySets) get(ctx context.Context, uri string, refresh bool) (JWKS, time.Time, error) {
+	r.mu.Lock()
+	cached, ok := r.cache[uri]
+	r.mu.Unlock()
+
+	if ok && !refresh && time.Since(cached.fetchedAt) < r.ttl {
+		return cached.jwks, cached.fetchedAt, nil
+	}
+
+	jwks, err := r.fetch(ctx, uri)
+	if err != nil {
+		return JWKS{}, time.Time{}, err
+	}
+
+	cached = cachedKeySet{jwks: jwks, fetchedAt: time.Now()}
+	r.mu.Lock()
+	r.cache[uri] = cached
+	r.mu.Unlock()
+
+	return cached.jwks, cached.fetchedAt, nil
+}
+
+func (r *RemoteKeySets) fetch(ctx context.Context, uri string) (JWKS, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return JWKS{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return JWKS{}, fmt.Errorf("could not fetch jwks %s: %w", uri, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return JWKS{}, fmt.Errorf("could not fetch jwks %s: unexpected status %d", uri, resp.StatusCode)
+	}
+
+	var jwks JWKS
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&jwks); err != nil {
+		return JWKS{}, fmt.Errorf("could not decode jwks %s: %w", uri, err)
+	}
+
+	return jwks, nil
+}
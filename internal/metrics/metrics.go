@@ -0,0 +1,295 @@
+// Package metrics serves counters, histograms and gauges in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are in seconds.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// labelSeparator joins label values into series keys; it cannot appear in valid UTF-8.
+const labelSeparator = "\xff"
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry requires metric names to be unique.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metric %s registered twice", name))
+	}
+	r.metrics[name] = m
+}
+
+// Handler sorts the metrics by name.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		r.mu.Lock()
+		names := make([]string, 0, len(r.metrics))
+		for name := range r.metrics {
+			names = append(names, name)
+		}
+		metrics := make([]metric, 0, len(names))
+		slices.Sort(names)
+		for _, name := range names {
+			metrics = append(metrics, r.metrics[name])
+		}
+		r.mu.Unlock()
+
+		rw.Header().Set("Content-Type", contentType)
+		w := bufio.NewWriter(rw)
+		for _, m := range metrics {
+			m.write(w)
+		}
+		_ = w.Flush()
+	})
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d desc) writeHeader(w *bufio.Writer) {
+	w.WriteString("# HELP " + d.name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help) + "\n")
+	w.WriteString("# TYPE " + d.name + " " + d.typ + "\n")
+}
+
+// key requires the label values to match the labels of the metric.
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", d.name, len(d.labels), len(values)))
+	}
+
+	return strings.Join(values, labelSeparator)
+}
+
+// writeSample adds an extra label, such as le, when extraName is set.
+func (d desc) writeSample(w *bufio.Writer, name, key, extraName, extraValue string, value float64) {
+	w.WriteString(name)
+
+	var values []string
+	if len(d.labels) > 0 {
+		values = strings.Split(key, labelSeparator)
+	}
+	if len(values) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, label := range d.labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, label, values[i])
+		}
+		if extraName != "" {
+			if len(values) > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func writeLabel(w *bufio.Writer, name, value string) {
+	w.WriteString(name + `="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func sortedKeys[V any](series map[string]V) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// Counter counts nothing when nil.
+type Counter struct {
+	desc
+
+	mu     sync.Mutex
+	series map[string]float64
+}
+
+// NewCounter expects a name ending with _total.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc:   desc{name: name, help: help, typ: "counter", labels: labels},
+		series: map[string]float64{},
+	}
+	if len(labels) == 0 {
+		c.series[""] = 0
+	}
+	r.register(name, c)
+
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add expects v not to be negative.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if c == nil {
+		return
+	}
+
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.series[key] += v
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeHeader(w)
+	for _, key := range sortedKeys(c.series) {
+		c.writeSample(w, c.name, key, "", "", c.series[key])
+	}
+}
+
+// Histogram observes nothing when nil.
+type Histogram struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	// counts holds the observations of each bucket, the last one counting those above every bound.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram expects the upper bounds of the buckets in increasing order.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		series:  map[string]*histogramSeries{},
+	}
+	r.register(name, h)
+
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	if h == nil {
+		return
+	}
+
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+
+	i, _ := slices.BinarySearch(h.buckets, v)
+	s.counts[i]++
+	s.sum += v
+	s.count++
+}
+
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			h.writeSample(w, h.name+"_bucket", key, "le", formatFloat(bound), float64(cumulative))
+		}
+		h.writeSample(w, h.name+"_bucket", key, "le", "+Inf", float64(s.count))
+		h.writeSample(w, h.name+"_sum", key, "", "", s.sum)
+		h.writeSample(w, h.name+"_count", key, "", "", float64(s.count))
+	}
+}
+
+// gaugeFunc is a gauge whose values are collected when the metrics are served.
+type gaugeFunc struct {
+	desc
+	collect func(emit func(value float64, labelValues ...string))
+}
+
+// NewGaugeFunc calls collect to emit the series of the gauge whenever the metrics are served.
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, collect func(emit func(value float64, labelValues ...string))) {
+	r.register(name, &gaugeFunc{
+		desc:    desc{name: name, help: help, typ: "gauge", labels: labels},
+		collect: collect,
+	})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	series := map[string]float64{}
+	g.collect(func(value float64, labelValues ...string) {
+		series[g.key(labelValues)] = value
+	})
+
+	g.writeHeader(w)
+	for _, key := range sortedKeys(series) {
+		g.writeSample(w, g.name, key, "", "", series[key])
+	}
+}
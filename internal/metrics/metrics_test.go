@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := rec.Header().Get("Content-Type"); got != contentType {
+		t.Errorf("Content-Type = %q, want %q", got, contentType)
+	}
+
+	return rec.Body.String()
+}
+
+func TestExposition(t *testing.T) {
+	reg := NewRegistry()
+
+	requests := reg.NewCounter("test_requests_total", "Requests handled.", "method", "path")
+	requests.Inc("GET", "/b")
+	requests.Inc("GET", "/a")
+	requests.Add(2.5, "GET", "/a")
+	requests.Inc("POST", "say \"hi\"\\\n")
+
+	reg.NewCounter("test_restarts_total", "Restarts,\nwith a \\ in the help.")
+
+	latency := reg.NewHistogram("test_latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		latency.Observe(v, "/token")
+	}
+
+	reg.NewGaugeFunc("test_key_age_seconds", "Key age.", []string{"alg"}, func(emit func(float64, ...string)) {
+		emit(120, "RS256")
+		emit(1e-7, "ES256")
+	})
+
+	// Metrics are sorted by name and their series by label values.
+	want := `# HELP test_key_age_seconds Key age.
+# TYPE test_key_age_seconds gauge
+test_key_age_seconds{alg="ES256"} 1e-07
+test_key_age_seconds{alg="RS256"} 120
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="/token",le="0.1"} 2
+test_latency_seconds_bucket{route="/token",le="1"} 3
+test_latency_seconds_bucket{route="/token",le="+Inf"} 4
+test_latency_seconds_sum{route="/token"} 3.65
+test_latency_seconds_count{route="/token"} 4
+# HELP test_requests_total Requests handled.
+# TYPE test_requests_total counter
+test_requests_total{method="GET",path="/a"} 3.5
+test_requests_total{method="GET",path="/b"} 1
+test_requests_total{method="POST",path="say \"hi\"\\\n"} 1
+# HELP test_restarts_total Restarts,\nwith a \\ in the help.
+# TYPE test_restarts_total counter
+test_restarts_total 0
+`
+	if got := scrape(t, reg); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramWithoutLabels(t *testing.T) {
+	reg := NewRegistry()
+	h := reg.NewHistogram("test_seconds", "Durations.", []float64{1})
+
+	if got, want := scrape(t, reg), "# HELP test_seconds Durations.\n# TYPE test_seconds histogram\n"; got != want {
+		t.Errorf("exposition of an empty histogram:\n%s\nwant:\n%s", got, want)
+	}
+
+	h.Observe(2)
+	want := `# HELP test_seconds Durations.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="1"} 0
+test_seconds_bucket{le="+Inf"} 1
+test_seconds_sum 2
+test_seconds_count 1
+`
+	if got := scrape(t, reg); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var c *Counter
+	var h *Histogram
+
+	// Nil metrics record nothing, without panicking.
+	c.Inc("a")
+	h.Observe(1, "a")
+}
+
+func TestRegistryPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(reg *Registry)
+	}{
+		{
+			name: "name registered twice",
+			fn: func(reg *Registry) {
+				reg.NewCounter("test_total", "Test.")
+				reg.NewHistogram("test_total", "Test.", DefaultBuckets)
+			},
+		},
+		{
+			name: "missing label value",
+			fn:   func(reg *Registry) { reg.NewCounter("test_total", "Test.", "method", "path").Inc("GET") },
+		},
+		{
+			name: "extra label value",
+			fn:   func(reg *Registry) { reg.NewHistogram("test_seconds", "Test.", DefaultBuckets).Observe(1, "GET") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic")
+				}
+			}()
+
+			tt.fn(NewRegistry())
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package par
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/session"
+)
+
+const RequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+var (
+	ErrNotFound = errors.New("authorization request not found")
+)
+
+type Request struct {
+	RequestURI string
+	ClientID   string
+	Params     url.Values
+	ExpiresAt  time.Time
+}
+
+type Store interface {
+	Save(ctx context.Context, req Request) error
+	Get(ctx context.Context, requestURI string) (Request, error)
+	Delete(ctx context.Context, requestURI string) error
+}
+
+func NewRequestURI() (string, error) {
+	id, err := session.NewID()
+	if err != nil {
+		return "", err
+	}
+
+	return RequestURIPrefix + id, nil
+}
+
+func IsRequestURI(value string) bool {
+	return strings.HasPrefix(value, RequestURIPrefix) && len(value) > len(RequestURIPrefix)
+}
@@ -0,0 +1,70 @@
+package par
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+const (
+	keyPrefix = "par:"
+)
+
+type kvStore struct {
+	kv storage.Store
+}
+
+func NewStore(kv storage.Store) Store {
+	return kvStore{kv: kv}
+}
+
+func (s kvStore) Save(ctx context.Context, req Request) error {
+	ttl := time.Until(req.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	return s.kv.Set(ctx, keyPrefix+req.RequestURI, b, ttl)
+}
+
+func (s kvStore) Get(ctx context.Context, requestURI string) (Request, error) {
+	b, err := s.kv.Get(ctx, keyPrefix+requestURI)
+	if errors.Is(err, storage.ErrNotFound) {
+		return Request{}, ErrNotFound
+	}
+	if err != nil {
+		return Request{}, err
+	}
+
+	var r Request
+	if err := json.Unmarshal(b, &r); err != nil {
+		return Request{}, err
+	}
+
+	if time.Now().After(r.ExpiresAt) {
+		return Request{}, ErrNotFound
+	}
+
+	return r, nil
+}
+
+func (s kvStore) Delete(ctx context.Context, requestURI string) error {
+	ok, err := s.kv.Delete(ctx, keyPrefix+requestURI)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	return nil
+}
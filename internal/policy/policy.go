@@ -0,0 +1,264 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+
+	OperatorEquals          = "equals"
+	OperatorNotEquals       = "not_equals"
+	OperatorIn              = "in"
+	OperatorContains        = "contains"
+	OperatorExists          = "exists"
+	OperatorEqualsAttribute = "equals_attribute"
+)
+
+// Policy denies a request when a deny rule matches, allows it when an allow rule matches and denies it
+// otherwise.
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule actions and resources are patterns where * matches any sequence of characters.
+type Rule struct {
+	ID          string      `json:"id"`
+	Description string      `json:"description,omitempty"`
+	Effect      string      `json:"effect"`
+	Actions     []string    `json:"actions"`
+	Resources   []string    `json:"resources"`
+	Conditions  []Condition `json:"conditions,omitempty"`
+}
+
+// Condition.Value is the path of the other attribute for OperatorEqualsAttribute.
+type Condition struct {
+	Attribute string      `json:"attribute"`
+	Operator  string      `json:"operator"`
+	Value     interface{} `json:"value,omitempty"`
+}
+
+// Request attributes are addressed in conditions as subject.<name>, resource.<name> and context.<name>;
+// the resource is matched by its id.
+type Request struct {
+	Subject  map[string]interface{}
+	Action   string
+	Resource map[string]interface{}
+	Context  map[string]interface{}
+}
+
+type Decision struct {
+	Allowed bool
+	Reasons []string
+}
+
+func (p Policy) Validate() error {
+	ids := map[string]bool{}
+	for i, r := range p.Rules {
+		if r.ID == "" {
+			return fmt.Errorf("rule %d has no id", i)
+		}
+		if ids[r.ID] {
+			return fmt.Errorf("rule %q is defined twice", r.ID)
+		}
+		ids[r.ID] = true
+
+		if r.Effect != EffectAllow && r.Effect != EffectDeny {
+			return fmt.Errorf("rule %q has unknown effect %q", r.ID, r.Effect)
+		}
+
+		if len(r.Actions) == 0 || len(r.Resources) == 0 {
+			return fmt.Errorf("rule %q must list actions and resources", r.ID)
+		}
+
+		for _, c := range r.Conditions {
+			if err := c.Validate(); err != nil {
+				return fmt.Errorf("rule %q: %w", r.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p Policy) Evaluate(req Request) Decision {
+	attrs := map[string]interface{}{
+		"subject":  normalize(req.Subject),
+		"resource": normalize(req.Resource),
+		"context":  normalize(req.Context),
+		"action":   req.Action,
+	}
+	resourceID, _ := Lookup(attrs, "resource.id").(string)
+
+	var allows, denies []string
+	for _, r := range p.Rules {
+		if !matchesAny(r.Actions, req.Action) || !matchesAny(r.Resources, resourceID) || !conditionsHold(r.Conditions, attrs) {
+			continue
+		}
+
+		reason := r.ID
+		if r.Description != "" {
+			reason += ": " + r.Description
+		}
+
+		if r.Effect == EffectDeny {
+			denies = append(denies, "denied by rule "+reason)
+		} else {
+			allows = append(allows, "allowed by rule "+reason)
+		}
+	}
+
+	switch {
+	case len(denies) > 0:
+		return Decision{Allowed: false, Reasons: denies}
+	case len(allows) > 0:
+		return Decision{Allowed: true, Reasons: allows}
+	default:
+		return Decision{Allowed: false, Reasons: []string{"no rule allows the action"}}
+	}
+}
+
+func (c Condition) Validate() error {
+	switch c.Operator {
+	case OperatorEquals, OperatorNotEquals, OperatorContains, OperatorExists:
+	case OperatorIn:
+		if _, ok := c.Value.([]interface{}); !ok {
+			return fmt.Errorf("%s is compared with a value that is not a list", c.Attribute)
+		}
+	case OperatorEqualsAttribute:
+		if _, ok := c.Value.(string); !ok {
+			return fmt.Errorf("%s is compared with a value that is not an attribute", c.Attribute)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q", c.Operator)
+	}
+
+	return nil
+}
+
+func (c Condition) Holds(attrs map[string]interface{}) bool {
+	normalized, _ := normalize(attrs).(map[string]interface{})
+	return conditionHolds(c, normalized)
+}
+
+func conditionsHold(conditions []Condition, attrs map[string]interface{}) bool {
+	for _, c := range conditions {
+		if !conditionHolds(c, attrs) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func conditionHolds(c Condition, attrs map[string]interface{}) bool {
+	v := Lookup(attrs, c.Attribute)
+	switch c.Operator {
+	case OperatorExists:
+		return v != nil
+	case OperatorEquals:
+		return v != nil && equal(v, c.Value)
+	case OperatorNotEquals:
+		return !equal(v, c.Value)
+	case OperatorIn:
+		list, _ := c.Value.([]interface{})
+		return v != nil && containsValue(list, v)
+	case OperatorContains:
+		list, _ := v.([]interface{})
+		return containsValue(list, c.Value)
+	case OperatorEqualsAttribute:
+		path, _ := c.Value.(string)
+		other := Lookup(attrs, path)
+		return v != nil && other != nil && equal(v, other)
+	default:
+		return false
+	}
+}
+
+// Lookup returns nil when there is no attribute at the path.
+func Lookup(attrs map[string]interface{}, path string) interface{} {
+	var v interface{} = attrs
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+
+	return v
+}
+
+func containsValue(list []interface{}, v interface{}) bool {
+	for _, item := range list {
+		if equal(item, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	switch a.(type) {
+	case string, float64, bool, nil:
+		return a == b
+	default:
+		return false
+	}
+}
+
+// normalize converts attributes to the types JSON decoding produces, so attributes built in Go compare
+// like attributes read from a request body.
+func normalize(m map[string]interface{}) interface{} {
+	if m == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil
+	}
+
+	return v
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if match(p, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func match(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
@@ -0,0 +1,219 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	var p Policy
+	err := json.Unmarshal([]byte(`{"rules": [
+		{"id": "admins-read", "effect": "allow", "actions": ["documents:read"], "resources": ["doc:*"],
+		 "conditions": [{"attribute": "subject.roles", "operator": "contains", "value": "admin"}]},
+		{"id": "owner", "effect": "allow", "actions": ["documents:*"], "resources": ["doc:*"],
+		 "conditions": [{"attribute": "resource.owner", "operator": "equals_attribute", "value": "subject.id"}]},
+		{"id": "archived", "effect": "deny", "actions": ["documents:write"], "resources": ["doc:*:archived"]},
+		{"id": "clearance", "effect": "allow", "actions": ["reports:read"], "resources": ["report:*"],
+		 "conditions": [{"attribute": "subject.clearance", "operator": "equals", "value": 3}]},
+		{"id": "office-hours", "effect": "deny", "actions": ["*"], "resources": ["*"],
+		 "conditions": [{"attribute": "context.after_hours", "operator": "equals", "value": true}]}
+	]}`), &p)
+	if err != nil {
+		t.Fatalf("could not decode policy: %v", err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		req         Request
+		wantAllowed bool
+		wantReasons []string
+	}{
+		{
+			name: "allow rule matching",
+			req: Request{
+				Subject:  map[string]interface{}{"id": "u1", "roles": []string{"admin"}},
+				Action:   "documents:read",
+				Resource: map[string]interface{}{"id": "doc:1"},
+			},
+			wantAllowed: true,
+			wantReasons: []string{"allowed by rule admins-read"},
+		},
+		{
+			name: "no rule matching",
+			req: Request{
+				Subject:  map[string]interface{}{"id": "u1", "roles": []string{"reader"}},
+				Action:   "documents:read",
+				Resource: map[string]interface{}{"id": "doc:1"},
+			},
+			wantReasons: []string{"no rule allows the action"},
+		},
+		{
+			name: "wildcard in the middle of the resource",
+			req: Request{
+				Subject:  map[string]interface{}{"id": "u1"},
+				Action:   "documents:write",
+				Resource: map[string]interface{}{"id": "doc:7:archived", "owner": "u1"},
+			},
+			wantReasons: []string{"denied by rule archived"},
+		},
+		{
+			name: "wildcard in the action",
+			req: Request{
+				Subject:  map[string]interface{}{"id": "u1"},
+				Action:   "documents:delete",
+				Resource: map[string]interface{}{"id": "doc:7", "owner": "u1"},
+			},
+			wantAllowed: true,
+			wantReasons: []string{"allowed by rule owner"},
+		},
+		{
+			name: "equals_attribute with another subject",
+			req: Request{
+				Subject:  map[string]interface{}{"id": "u2"},
+				Action:   "documents:delete",
+				Resource: map[string]interface{}{"id": "doc:7", "owner": "u1"},
+			},
+			wantReasons: []string{"no rule allows the action"},
+		},
+		{
+			name: "equals_attribute without the attribute",
+			req: Request{
+				Subject:  map[string]interface{}{},
+				Action:   "documents:delete",
+				Resource: map[string]interface{}{"id": "doc:7"},
+			},
+			wantReasons: []string{"no rule allows the action"},
+		},
+		{
+			name: "deny overrides allow",
+			req: Request{
+				Subject:  map[string]interface{}{"id": "u1", "roles": []string{"admin"}},
+				Action:   "documents:read",
+				Resource: map[string]interface{}{"id": "doc:1", "owner": "u1"},
+				Context:  map[string]interface{}{"after_hours": true},
+			},
+			wantReasons: []string{"denied by rule office-hours"},
+		},
+		{
+			name: "Go integers compared with JSON numbers",
+			req: Request{
+				Subject:  map[string]interface{}{"id": "u1", "clearance": int64(3)},
+				Action:   "reports:read",
+				Resource: map[string]interface{}{"id": "report:q3"},
+			},
+			wantAllowed: true,
+			wantReasons: []string{"allowed by rule clearance"},
+		},
+		{
+			name: "numbers of another value",
+			req: Request{
+				Subject:  map[string]interface{}{"id": "u1", "clearance": 2},
+				Action:   "reports:read",
+				Resource: map[string]interface{}{"id": "report:q3"},
+			},
+			wantReasons: []string{"no rule allows the action"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.Evaluate(tt.req)
+			if got.Allowed != tt.wantAllowed {
+				t.Errorf("Evaluate().Allowed = %v, want %v", got.Allowed, tt.wantAllowed)
+			}
+			if len(got.Reasons) != len(tt.wantReasons) {
+				t.Fatalf("Evaluate().Reasons = %q, want %q", got.Reasons, tt.wantReasons)
+			}
+			for i := range got.Reasons {
+				if got.Reasons[i] != tt.wantReasons[i] {
+					t.Errorf("Evaluate().Reasons = %q, want %q", got.Reasons, tt.wantReasons)
+				}
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{pattern: "doc:1", value: "doc:1", want: true},
+		{pattern: "doc:1", value: "doc:10"},
+		{pattern: "*", value: "", want: true},
+		{pattern: "doc:*", value: "doc:", want: true},
+		{pattern: "doc:*", value: "document:1"},
+		{pattern: "*:read", value: "documents:read", want: true},
+		{pattern: "*:read", value: "documents:readme"},
+		{pattern: "doc:*:v*", value: "doc:1:v2", want: true},
+		{pattern: "a*a", value: "a"},
+		{pattern: "a*a", value: "aa", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := match(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("match(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		wantErr bool
+	}{
+		{
+			name:  "valid rule",
+			rules: []Rule{{ID: "r", Effect: EffectAllow, Actions: []string{"*"}, Resources: []string{"*"}}},
+		},
+		{
+			name:    "missing id",
+			rules:   []Rule{{Effect: EffectAllow, Actions: []string{"*"}, Resources: []string{"*"}}},
+			wantErr: true,
+		},
+		{
+			name: "duplicate id",
+			rules: []Rule{
+				{ID: "r", Effect: EffectAllow, Actions: []string{"*"}, Resources: []string{"*"}},
+				{ID: "r", Effect: EffectDeny, Actions: []string{"*"}, Resources: []string{"*"}},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unknown effect",
+			rules:   []Rule{{ID: "r", Effect: "maybe", Actions: []string{"*"}, Resources: []string{"*"}}},
+			wantErr: true,
+		},
+		{
+			name:    "no resources",
+			rules:   []Rule{{ID: "r", Effect: EffectAllow, Actions: []string{"*"}}},
+			wantErr: true,
+		},
+		{
+			name: "in without a list",
+			rules: []Rule{{ID: "r", Effect: EffectAllow, Actions: []string{"*"}, Resources: []string{"*"},
+				Conditions: []Condition{{Attribute: "subject.id", Operator: OperatorIn, Value: "u1"}}}},
+			wantErr: true,
+		},
+		{
+			name: "unknown operator",
+			rules: []Rule{{ID: "r", Effect: EffectAllow, Actions: []string{"*"}, Resources: []string{"*"},
+				Conditions: []Condition{{Attribute: "subject.id", Operator: "matches", Value: "u1"}}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Policy{Rules: tt.rules}.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
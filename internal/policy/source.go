@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+type Source interface {
+	Policy(ctx context.Context) (Policy, error)
+}
+
+type staticSource struct {
+	policy Policy
+}
+
+func NewStaticSource(p Policy) Source {
+	return staticSource{policy: p}
+}
+
+func (s staticSource) Policy(context.Context) (Policy, error) {
+	return s.policy, nil
+}
+
+type fileSource struct {
+	path string
+
+	mu      sync.RWMutex
+	modTime time.Time
+	policy  Policy
+}
+
+// NewFileSource reloads the file whenever its modification time changes; a missing file holds no rules,
+// so every request is denied.
+func NewFileSource(path string) (Source, error) {
+	s := &fileSource{path: path}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *fileSource) Policy(context.Context) (Policy, error) {
+	if err := s.reload(); err != nil {
+		return Policy{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.policy, nil
+}
+
+func (s *fileSource) reload() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.mu.Lock()
+		s.modTime, s.policy = time.Time{}, Policy{}
+		s.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	upToDate := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if upToDate {
+		return nil
+	}
+
+	fileBytes, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	var p Policy
+	if err := json.Unmarshal(fileBytes, &p); err != nil {
+		return fmt.Errorf("could not parse policy file %s: %w", s.path, err)
+	}
+
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("policy file %s: %w", s.path, err)
+	}
+
+	s.mu.Lock()
+	s.modTime, s.policy = info.ModTime(), p
+	s.mu.Unlock()
+
+	return nil
+}
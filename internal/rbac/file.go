@@ -0,0 +1,97 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+type fileStore struct {
+	path string
+
+	mu      sync.RWMutex
+	modTime time.Time
+	roles   map[string]Role
+}
+
+// NewFileStore reloads the file whenever its modification time changes; a missing file holds no roles.
+func NewFileStore(path string) (Store, error) {
+	r := &fileStore{path: path}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *fileStore) Get(_ context.Context, name string) (Role, error) {
+	if err := r.reload(); err != nil {
+		return Role{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	role, ok := r.roles[name]
+	if !ok {
+		return Role{}, ErrNotFound
+	}
+
+	return role, nil
+}
+
+func (r *fileStore) reload() error {
+	info, err := os.Stat(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.mu.Lock()
+		r.modTime, r.roles = time.Time{}, nil
+		r.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	upToDate := info.ModTime().Equal(r.modTime)
+	r.mu.RUnlock()
+	if upToDate {
+		return nil
+	}
+
+	fileBytes, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	var list []Role
+	if err := json.Unmarshal(fileBytes, &list); err != nil {
+		return fmt.Errorf("could not parse roles file %s: %w", r.path, err)
+	}
+
+	roles := make(map[string]Role, len(list))
+	for _, role := range list {
+		if role.Name == "" {
+			return fmt.Errorf("roles file %s: role without name", r.path)
+		}
+
+		for _, p := range role.Permissions {
+			if p.Resource == "" || p.Name == "" {
+				return fmt.Errorf("roles file %s: role %q has a permission without resource or name", r.path, role.Name)
+			}
+		}
+
+		roles[role.Name] = role
+	}
+
+	r.mu.Lock()
+	r.modTime, r.roles = info.ModTime(), roles
+	r.mu.Unlock()
+
+	return nil
+}
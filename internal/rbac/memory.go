@@ -0,0 +1,30 @@
+package rbac
+
+import (
+	"context"
+)
+
+type memoryStore struct {
+	roles map[string]Role
+}
+
+func NewMemoryStore(roles ...Role) Store {
+	m := memoryStore{
+		roles: make(map[string]Role, len(roles)),
+	}
+
+	for _, r := range roles {
+		m.roles[r.Name] = r
+	}
+
+	return m
+}
+
+func (m memoryStore) Get(_ context.Context, name string) (Role, error) {
+	r, ok := m.roles[name]
+	if !ok {
+		return Role{}, ErrNotFound
+	}
+
+	return r, nil
+}
@@ -0,0 +1,26 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrNotFound = errors.New("role not found")
+)
+
+type Role struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Permissions []Permission `json:"permissions,omitempty"`
+}
+
+// Permission must be declared by its resource.
+type Permission struct {
+	Resource string `json:"resource"`
+	Name     string `json:"name"`
+}
+
+type Store interface {
+	Get(ctx context.Context, name string) (Role, error)
+}
@@ -0,0 +1,39 @@
+package refresh
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("refresh token not found")
+)
+
+type Token struct {
+	Token string `json:"-"`
+	// Family is shared by the tokens rotated from one another.
+	Family    string
+	ClientID  string
+	UserID    string
+	Scopes    []string
+	AuthTime  time.Time
+	ACR       string
+	AMR       []string
+	JKT       string
+	X5TS256   string
+	ExpiresAt time.Time
+}
+
+type Store interface {
+	Save(ctx context.Context, token Token) error
+
+	Get(ctx context.Context, token string) (Token, error)
+
+	// Consume removes the token, so it can be consumed once; presenting it again revokes its family, since
+	// a copy of it was stolen.
+	Consume(ctx context.Context, token string) (Token, error)
+
+	// Revoke revokes every token of the family.
+	Revoke(ctx context.Context, token Token) error
+}
@@ -0,0 +1,143 @@
+package refresh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+const (
+	keyPrefix        = "refresh:"
+	usedKeyPrefix    = "refresh_used:"
+	revokedKeyPrefix = "refresh_revoked:"
+)
+
+type kvStore struct {
+	kv storage.Store
+}
+
+// NewStore stores tokens by their hash.
+func NewStore(kv storage.Store) Store {
+	return kvStore{kv: kv}
+}
+
+func (s kvStore) Save(ctx context.Context, token Token) error {
+	ttl := time.Until(token.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return s.kv.Set(ctx, keyPrefix+hash(token.Token), b, ttl)
+}
+
+func (s kvStore) Get(ctx context.Context, token string) (Token, error) {
+	b, err := s.kv.Get(ctx, keyPrefix+hash(token))
+	if err != nil {
+		return Token{}, s.notFound(err)
+	}
+
+	return s.decode(ctx, token, b)
+}
+
+func (s kvStore) Consume(ctx context.Context, token string) (Token, error) {
+	b, err := s.kv.Take(ctx, keyPrefix+hash(token))
+	if errors.Is(err, storage.ErrNotFound) {
+		return Token{}, s.detectReuse(ctx, token)
+	}
+	if err != nil {
+		return Token{}, err
+	}
+
+	t, err := s.decode(ctx, token, b)
+	if err != nil {
+		return Token{}, err
+	}
+
+	if err := s.kv.Set(ctx, usedKeyPrefix+hash(token), []byte(t.Family), time.Until(t.ExpiresAt)); err != nil {
+		return Token{}, err
+	}
+
+	return t, nil
+}
+
+func (s kvStore) Revoke(ctx context.Context, token Token) error {
+	if err := s.revokeFamily(ctx, token.Family, time.Until(token.ExpiresAt)); err != nil {
+		return err
+	}
+
+	_, err := s.kv.Delete(ctx, keyPrefix+hash(token.Token))
+	return err
+}
+
+// detectReuse revokes the family of a consumed token and returns ErrNotFound unless the storage fails.
+func (s kvStore) detectReuse(ctx context.Context, token string) error {
+	key := usedKeyPrefix + hash(token)
+
+	family, err := s.kv.Get(ctx, key)
+	if err != nil {
+		return s.notFound(err)
+	}
+
+	// The tokens of a family expire together, as does the mark of their use.
+	ttl, err := s.kv.TTL(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if err := s.revokeFamily(ctx, string(family), ttl); err != nil {
+		return err
+	}
+
+	return ErrNotFound
+}
+
+func (s kvStore) revokeFamily(ctx context.Context, family string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.kv.Set(ctx, revokedKeyPrefix+family, []byte("1"), ttl)
+}
+
+func (s kvStore) decode(ctx context.Context, token string, b []byte) (Token, error) {
+	var t Token
+	if err := json.Unmarshal(b, &t); err != nil {
+		return Token{}, err
+	}
+	t.Token = token
+
+	if time.Now().After(t.ExpiresAt) {
+		return Token{}, ErrNotFound
+	}
+
+	if _, err := s.kv.Get(ctx, revokedKeyPrefix+t.Family); err == nil {
+		return Token{}, ErrNotFound
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return Token{}, err
+	}
+
+	return t, nil
+}
+
+func (s kvStore) notFound(err error) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package refresh
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	kv, err := storage.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() = %v", err)
+	}
+
+	s := NewStore(kv)
+	expiresAt := time.Now().Add(time.Hour)
+	for _, token := range []Token{
+		{Token: "a1", Family: "a", ClientID: "app", ExpiresAt: expiresAt},
+		{Token: "b1", Family: "b", ClientID: "app", ExpiresAt: expiresAt},
+		{Token: "c1", Family: "c", ClientID: "app", ExpiresAt: expiresAt},
+		{Token: "expired", Family: "d", ClientID: "app", ExpiresAt: time.Now().Add(-time.Second)},
+	} {
+		if err := s.Save(ctx, token); err != nil {
+			t.Fatalf("Save() = %v", err)
+		}
+	}
+
+	// The tokens persist across restarts.
+	if err := kv.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if kv, err = storage.OpenFileStore(path); err != nil {
+		t.Fatalf("OpenFileStore() = %v", err)
+	}
+	defer kv.Close()
+	s = NewStore(kv)
+
+	if token, err := s.Get(ctx, "a1"); err != nil || token.Token != "a1" || token.ClientID != "app" {
+		t.Errorf("Get() = %+v, %v", token, err)
+	}
+	if _, err := s.Get(ctx, "expired"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of an expired token = %v, want ErrNotFound", err)
+	}
+
+	// A token is consumed once, and consuming it again revokes its family.
+	if _, err := s.Consume(ctx, "a1"); err != nil {
+		t.Fatalf("Consume() = %v", err)
+	}
+	if err := s.Save(ctx, Token{Token: "a2", Family: "a", ExpiresAt: expiresAt}); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+	if _, err := s.Consume(ctx, "a1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Consume() of a used token = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Get(ctx, "a2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a token of a reused family = %v, want ErrNotFound", err)
+	}
+
+	// Revoke revokes the family, including tokens saved later on.
+	b1, err := s.Get(ctx, "b1")
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if err := s.Revoke(ctx, b1); err != nil {
+		t.Fatalf("Revoke() = %v", err)
+	}
+	if err := s.Save(ctx, Token{Token: "b2", Family: "b", ExpiresAt: expiresAt}); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+	for _, token := range []string{"b1", "b2"} {
+		if _, err := s.Consume(ctx, token); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Consume() of revoked token %s = %v, want ErrNotFound", token, err)
+		}
+	}
+
+	// Other families are left alone.
+	if _, err := s.Consume(ctx, "c1"); err != nil {
+		t.Errorf("Consume() of another family = %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package replay
+
+import (
+	"context"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+const (
+	keyPrefix = "replay:"
+)
+
+type Cache interface {
+	// Use returns false when the identifier was already used and has not expired yet.
+	Use(ctx context.Context, id string, expiresAt time.Time) (bool, error)
+}
+
+type kvCache struct {
+	kv storage.Store
+}
+
+func NewCache(kv storage.Store) Cache {
+	return kvCache{kv: kv}
+}
+
+func (c kvCache) Use(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		// An identifier that already expired cannot be used again anyway.
+		return true, nil
+	}
+
+	return c.kv.SetNX(ctx, keyPrefix+id, []byte("1"), ttl)
+}
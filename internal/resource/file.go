@@ -0,0 +1,101 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+type fileRegistry struct {
+	path string
+
+	mu        sync.RWMutex
+	modTime   time.Time
+	resources map[string]Resource
+}
+
+// NewFileRegistry reloads the file whenever its modification time changes; a missing file holds no resources.
+func NewFileRegistry(path string) (Registry, error) {
+	r := &fileRegistry{path: path}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *fileRegistry) Get(_ context.Context, identifier string) (Resource, error) {
+	if err := r.reload(); err != nil {
+		return Resource{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res, ok := r.resources[identifier]
+	if !ok {
+		return Resource{}, ErrNotFound
+	}
+
+	return res, nil
+}
+
+func (r *fileRegistry) reload() error {
+	info, err := os.Stat(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.mu.Lock()
+		r.modTime, r.resources = time.Time{}, nil
+		r.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	upToDate := info.ModTime().Equal(r.modTime)
+	r.mu.RUnlock()
+	if upToDate {
+		return nil
+	}
+
+	fileBytes, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	var list []Resource
+	if err := json.Unmarshal(fileBytes, &list); err != nil {
+		return fmt.Errorf("could not parse resources file %s: %w", r.path, err)
+	}
+
+	resources := make(map[string]Resource, len(list))
+	for _, res := range list {
+		if res.Identifier == "" {
+			return fmt.Errorf("resources file %s: resource without identifier", r.path)
+		}
+
+		switch res.TokenProfile {
+		case "", ProfileJWT, ProfileRFC9068:
+		default:
+			return fmt.Errorf("resources file %s: unknown token profile %q", r.path, res.TokenProfile)
+		}
+
+		if err := res.ClaimMapping.Validate(); err != nil {
+			return fmt.Errorf("resources file %s: resource %s: %w", r.path, res.Identifier, err)
+		}
+
+		resources[res.Identifier] = res
+	}
+
+	r.mu.Lock()
+	r.modTime, r.resources = info.ModTime(), resources
+	r.mu.Unlock()
+
+	return nil
+}
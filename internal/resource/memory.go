@@ -0,0 +1,30 @@
+package resource
+
+import (
+	"context"
+)
+
+type memoryRegistry struct {
+	resources map[string]Resource
+}
+
+func NewMemoryRegistry(resources ...Resource) Registry {
+	m := memoryRegistry{
+		resources: make(map[string]Resource, len(resources)),
+	}
+
+	for _, r := range resources {
+		m.resources[r.Identifier] = r
+	}
+
+	return m
+}
+
+func (m memoryRegistry) Get(_ context.Context, identifier string) (Resource, error) {
+	r, ok := m.resources[identifier]
+	if !ok {
+		return Resource{}, ErrNotFound
+	}
+
+	return r, nil
+}
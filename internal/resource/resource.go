@@ -0,0 +1,31 @@
+package resource
+
+import (
+	"context"
+	"errors"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/claimmap"
+)
+
+const (
+	ProfileJWT     = "jwt"
+	ProfileRFC9068 = "rfc9068"
+)
+
+var (
+	ErrNotFound = errors.New("resource not found")
+)
+
+type Resource struct {
+	Identifier string `json:"identifier"`
+	Name       string `json:"name,omitempty"`
+	// TokenProfile is ProfileJWT by default.
+	TokenProfile string `json:"token_profile,omitempty"`
+	// Permissions declares the permissions roles can grant on the resource.
+	Permissions  []string         `json:"permissions,omitempty"`
+	ClaimMapping claimmap.Mapping `json:"claim_mapping,omitempty"`
+}
+
+type Registry interface {
+	Get(ctx context.Context, identifier string) (Resource, error)
+}
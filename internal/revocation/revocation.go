@@ -0,0 +1,46 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+const (
+	keyPrefix = "revoked:"
+)
+
+// List keeps the IDs of revoked access tokens until the tokens expire.
+type List interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+
+	Revoked(ctx context.Context, jti string) (bool, error)
+}
+
+type kvList struct {
+	kv storage.Store
+}
+
+func NewList(kv storage.Store) List {
+	return kvList{kv: kv}
+}
+
+func (l kvList) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return l.kv.Set(ctx, keyPrefix+jti, []byte("1"), ttl)
+}
+
+func (l kvList) Revoked(ctx context.Context, jti string) (bool, error) {
+	_, err := l.kv.Get(ctx, keyPrefix+jti)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
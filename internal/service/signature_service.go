@@ -8,6 +8,8 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"strings"
@@ -15,11 +17,22 @@ import (
 )
 
 type SignatureService interface {
+	Algo() string
+
 	GenerateToken(payload []byte) (string, error)
 
+	// GenerateTypedToken leaves the typ header out when typ is empty.
+	GenerateTypedToken(typ string, payload []byte) (string, error)
+
+	VerifyToken(token string) ([]byte, error)
+
 	GetJWKs() (JWKS, error)
 }
 
+var (
+	ErrInvalidToken = errors.New("invalid token")
+)
+
 type JWK struct {
 	Kty string   `json:"kty"`
 	X5c []string `json:"x5c"`
@@ -48,7 +61,14 @@ func (hdl rsaSignatureService) Algo() string {
 }
 
 func (hdl rsaSignatureService) GenerateToken(payload []byte) (string, error) {
-	header := fmt.Sprintf(`{"alg":"%s","typ":"JWT"}`, hdl.Algo())
+	return hdl.GenerateTypedToken("JWT", payload)
+}
+
+func (hdl rsaSignatureService) GenerateTypedToken(typ string, payload []byte) (string, error) {
+	header := fmt.Sprintf(`{"alg":"%s"}`, hdl.Algo())
+	if typ != "" {
+		header = fmt.Sprintf(`{"alg":"%s","typ":"%s"}`, hdl.Algo(), typ)
+	}
 
 	message := base64URLEncode([]byte(header)) + "." + base64URLEncode(payload)
 
@@ -62,6 +82,42 @@ func (hdl rsaSignatureService) GenerateToken(payload []byte) (string, error) {
 	return message + "." + base64URLEncode(sig), nil
 }
 
+func (hdl rsaSignatureService) VerifyToken(token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil || header.Alg != hdl.Algo() {
+		return nil, ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&hdl.privateKey.PublicKey, crypto.SHA256, hashed[:], sig); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return payload, nil
+}
+
 func (hdl rsaSignatureService) GetJWKs() (JWKS, error) {
 	template := x509.Certificate{
 		SerialNumber: big.NewInt(1),
@@ -0,0 +1,41 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("session not found")
+)
+
+type Session struct {
+	ID        string
+	UserID    string
+	AuthTime  time.Time
+	ACR       string
+	AMR       []string
+	ExpiresAt time.Time
+}
+
+type Store interface {
+	// Create starts a session for the user. ID, AuthTime and ExpiresAt are filled in by the store.
+	Create(ctx context.Context, sess Session, ttl time.Duration) (Session, error)
+
+	Get(ctx context.Context, id string) (Session, error)
+
+	Delete(ctx context.Context, id string) error
+}
+
+func NewID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate id: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,71 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+const (
+	keyPrefix = "session:"
+)
+
+type kvStore struct {
+	kv storage.Store
+}
+
+func NewStore(kv storage.Store) Store {
+	return kvStore{kv: kv}
+}
+
+func (s kvStore) Create(ctx context.Context, sess Session, ttl time.Duration) (Session, error) {
+	id, err := NewID()
+	if err != nil {
+		return Session{}, err
+	}
+
+	now := time.Now()
+	sess.ID = id
+	sess.AuthTime = now
+	sess.ExpiresAt = now.Add(ttl)
+
+	b, err := json.Marshal(sess)
+	if err != nil {
+		return Session{}, err
+	}
+
+	if err := s.kv.Set(ctx, keyPrefix+id, b, ttl); err != nil {
+		return Session{}, err
+	}
+
+	return sess, nil
+}
+
+func (s kvStore) Get(ctx context.Context, id string) (Session, error) {
+	b, err := s.kv.Get(ctx, keyPrefix+id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return Session{}, ErrNotFound
+	}
+	if err != nil {
+		return Session{}, err
+	}
+
+	var sess Session
+	if err := json.Unmarshal(b, &sess); err != nil {
+		return Session{}, err
+	}
+
+	if time.Now().After(sess.ExpiresAt) {
+		return Session{}, ErrNotFound
+	}
+
+	return sess, nil
+}
+
+func (s kvStore) Delete(ctx context.Context, id string) error {
+	_, err := s.kv.Delete(ctx, keyPrefix+id)
+	return err
+}
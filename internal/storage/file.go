@@ -0,0 +1,480 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// fileVersion covers the file format and the stored keys; older files are migrated when opened.
+	fileVersion = 1
+
+	fileMagic       = "serverd\x00"
+	fileHeaderSize  = len(fileMagic) + 4
+	recordHeaderLen = 8
+	maxRecordSize   = 64 << 20
+
+	opSet    byte = 1
+	opDelete byte = 2
+
+	// A file is compacted when its obsolete records take more space than its live ones, once it is large
+	// enough for that to matter.
+	minCompactSize = 4 << 20
+)
+
+var (
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	// fileMigrations upgrade the keys of older files: fileMigrations[i] upgrades version i+1 to i+2. They
+	// run once when a file is opened, before it is rewritten in the current version.
+	fileMigrations []func(entries map[string]entry) error
+)
+
+// FileStore appends and syncs every write; a record torn by a crash is discarded when the file is opened
+// again. Compaction rewrites the file and atomically replaces it.
+type FileStore struct {
+	path string
+	lock *os.File
+
+	mu        sync.Mutex
+	f         *os.File
+	size      int64
+	liveSize  int64
+	entries   map[string]entry
+	evictedAt time.Time
+}
+
+// OpenFileStore locks the file until Close is called so two servers cannot write it at once.
+func OpenFileStore(path string) (*FileStore, error) {
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(lock); err != nil {
+		_ = lock.Close()
+		return nil, fmt.Errorf("could not lock %s, is another server using it? %w", path, err)
+	}
+
+	s := &FileStore{path: path, lock: lock}
+	if err := s.load(); err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) load() error {
+	f, err := os.OpenFile(s.path, os.O_RDONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s.entries = map[string]entry{}
+	version := fileVersion
+
+	r := bufio.NewReader(f)
+	header := make([]byte, fileHeaderSize)
+	switch _, err := io.ReadFull(r, header); {
+	case errors.Is(err, io.EOF):
+		// A new file.
+	case err != nil:
+		return fmt.Errorf("could not read %s: %w", s.path, err)
+	case string(header[:len(fileMagic)]) != fileMagic:
+		return fmt.Errorf("%s is not a storage file", s.path)
+	default:
+		version = int(binary.BigEndian.Uint32(header[len(fileMagic):]))
+		if version < 1 || version > fileVersion {
+			return fmt.Errorf("%s has version %d, this server supports up to %d", s.path, version, fileVersion)
+		}
+
+		if err := s.replay(r); err != nil {
+			return fmt.Errorf("could not read %s: %w", s.path, err)
+		}
+	}
+
+	for v := version; v < fileVersion; v++ {
+		if err := fileMigrations[v-1](s.entries); err != nil {
+			return fmt.Errorf("could not migrate %s to version %d: %w", s.path, v+1, err)
+		}
+	}
+
+	// Rewriting the file drops expired keys and a record torn by a crash.
+	return s.rewrite()
+}
+
+// replay stops at the first incomplete or corrupt record, which can only be the last one unless the file
+// was damaged otherwise.
+func (s *FileStore) replay(r io.Reader) error {
+	now := time.Now()
+	for {
+		op, key, e, err := readRecord(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, errCorruptRecord) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case op == opDelete || e.expired(now):
+			delete(s.entries, key)
+		default:
+			s.entries[key] = e
+		}
+	}
+}
+
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rewrite()
+}
+
+// rewrite syncs the new file before renaming it over the old one, so a crash leaves either file intact.
+func (s *FileStore) rewrite() error {
+	tmp := s.path + ".compact"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	w := bufio.NewWriter(f)
+	header := make([]byte, fileHeaderSize)
+	copy(header, fileMagic)
+	binary.BigEndian.PutUint32(header[len(fileMagic):], fileVersion)
+	w.Write(header)
+
+	now := time.Now()
+	size := int64(fileHeaderSize)
+	for key, e := range s.entries {
+		if e.expired(now) {
+			delete(s.entries, key)
+			continue
+		}
+
+		b := encodeRecord(opSet, key, e)
+		w.Write(b)
+		size += int64(len(b))
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(s.path)); err != nil {
+		return err
+	}
+
+	if s.f != nil {
+		_ = s.f.Close()
+	}
+	if s.f, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return err
+	}
+
+	s.size, s.liveSize = size, size
+	return nil
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.f.Close()
+	if lockErr := s.lock.Close(); err == nil {
+		err = lockErr
+	}
+
+	return err
+}
+
+func (s *FileStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.get(key, time.Now())
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return slices.Clone(e.value), nil
+}
+
+func (s *FileStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.set(key, newEntry(value, ttl, time.Now()))
+}
+
+func (s *FileStore) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := s.get(key, now); ok {
+		return false, nil
+	}
+
+	return true, s.set(key, newEntry(value, ttl, now))
+}
+
+func (s *FileStore) Take(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.get(key, time.Now())
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if err := s.delete(key); err != nil {
+		return nil, err
+	}
+
+	return e.value, nil
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.get(key, time.Now()); !ok {
+		return false, nil
+	}
+
+	return true, s.delete(key)
+}
+
+func (s *FileStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	e, ok := s.get(key, now)
+	if !ok {
+		e = newEntry([]byte("0"), ttl, now)
+	}
+
+	n, err := strconv.ParseInt(string(e.value), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	n++
+	e.value = []byte(strconv.FormatInt(n, 10))
+	return n, s.set(key, e)
+}
+
+func (s *FileStore) TTL(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	e, ok := s.get(key, now)
+	switch {
+	case !ok:
+		return 0, nil
+	case e.expiresAt.IsZero():
+		return -1, nil
+	default:
+		return e.expiresAt.Sub(now), nil
+	}
+}
+
+func (s *FileStore) Throttle(_ context.Context, key string, interval time.Duration, burst int) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	tat, err := parseTAT(s.get(key, now))
+	if err != nil {
+		return 0, err
+	}
+
+	next, wait := gcra(tat, now, interval, burst)
+	if wait > 0 {
+		return wait, nil
+	}
+
+	return 0, s.set(key, newEntry(formatTAT(next), next.Sub(now), now))
+}
+
+// get evicts expired entries from memory at most once a second and from the file by compaction.
+func (s *FileStore) get(key string, now time.Time) (entry, bool) {
+	if now.Sub(s.evictedAt) > time.Second {
+		for k, e := range s.entries {
+			if e.expired(now) {
+				s.liveSize -= recordSize(k, e)
+				delete(s.entries, k)
+			}
+		}
+		s.evictedAt = now
+	}
+
+	e, ok := s.entries[key]
+	if !ok || e.expired(now) {
+		return entry{}, false
+	}
+
+	return e, true
+}
+
+func (s *FileStore) set(key string, e entry) error {
+	if err := s.append(encodeRecord(opSet, key, e)); err != nil {
+		return err
+	}
+
+	if old, ok := s.entries[key]; ok {
+		s.liveSize -= recordSize(key, old)
+	}
+	s.entries[key] = e
+	s.liveSize += recordSize(key, e)
+
+	return s.compactIfNeeded()
+}
+
+func (s *FileStore) delete(key string) error {
+	if err := s.append(encodeRecord(opDelete, key, entry{})); err != nil {
+		return err
+	}
+
+	if old, ok := s.entries[key]; ok {
+		s.liveSize -= recordSize(key, old)
+	}
+	delete(s.entries, key)
+
+	return s.compactIfNeeded()
+}
+
+// append truncates a failed write away, since records appended after a torn one would be lost when the
+// file is read.
+func (s *FileStore) append(record []byte) error {
+	_, err := s.f.Write(record)
+	if err == nil {
+		err = s.f.Sync()
+	}
+	if err != nil {
+		_ = s.f.Truncate(s.size)
+		return err
+	}
+
+	s.size += int64(len(record))
+	return nil
+}
+
+func (s *FileStore) compactIfNeeded() error {
+	if s.size < minCompactSize || s.size < 2*s.liveSize {
+		return nil
+	}
+
+	return s.rewrite()
+}
+
+var errCorruptRecord = errors.New("corrupt record")
+
+// A record is the length and CRC-32C of its payload followed by the payload: the operation, the expiry time
+// in Unix nanoseconds or 0, the key length, the key and the value.
+func encodeRecord(op byte, key string, e entry) []byte {
+	var expiresAt int64
+	if !e.expiresAt.IsZero() {
+		expiresAt = e.expiresAt.UnixNano()
+	}
+
+	payload := make([]byte, 0, 1+8+binary.MaxVarintLen64+len(key)+len(e.value))
+	payload = append(payload, op)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(expiresAt))
+	payload = binary.AppendUvarint(payload, uint64(len(key)))
+	payload = append(payload, key...)
+	payload = append(payload, e.value...)
+
+	b := make([]byte, recordHeaderLen, recordHeaderLen+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(len(payload)))
+	binary.BigEndian.PutUint32(b[4:], crc32.Checksum(payload, crcTable))
+
+	return append(b, payload...)
+}
+
+func recordSize(key string, e entry) int64 {
+	keyLen := len(binary.AppendUvarint(nil, uint64(len(key))))
+	return int64(recordHeaderLen + 1 + 8 + keyLen + len(key) + len(e.value))
+}
+
+func readRecord(r io.Reader) (op byte, key string, e entry, err error) {
+	header := make([]byte, recordHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, "", entry{}, errCorruptRecord
+		}
+		return 0, "", entry{}, err
+	}
+
+	n := binary.BigEndian.Uint32(header)
+	if n > maxRecordSize {
+		return 0, "", entry{}, errCorruptRecord
+	}
+
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, "", entry{}, errCorruptRecord
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:]) || len(payload) < 9 {
+		return 0, "", entry{}, errCorruptRecord
+	}
+
+	op = payload[0]
+	if expiresAt := int64(binary.BigEndian.Uint64(payload[1:9])); expiresAt != 0 {
+		e.expiresAt = time.Unix(0, expiresAt)
+	}
+
+	keyLen, m := binary.Uvarint(payload[9:])
+	if m <= 0 || uint64(len(payload)-9-m) < keyLen {
+		return 0, "", entry{}, errCorruptRecord
+	}
+
+	rest := payload[9+m:]
+	key, e.value = string(rest[:keyLen]), rest[keyLen:]
+	if op != opSet && op != opDelete {
+		return 0, "", entry{}, errCorruptRecord
+	}
+
+	return op, key, e, nil
+}
+
+// syncDir syncs a directory so a rename in it survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
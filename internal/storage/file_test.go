@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// reopen closes the store and opens its file again, as a server restart would.
+func reopen(t *testing.T, s *FileStore) *FileStore {
+	t.Helper()
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	s, err := OpenFileStore(s.path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() = %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+func openTestFileStore(t *testing.T) *FileStore {
+	t.Helper()
+
+	s, err := OpenFileStore(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("OpenFileStore() = %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	s := openTestFileStore(t)
+
+	if err := s.Set(ctx, "forever", []byte("v"), 0); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	if err := s.Set(ctx, "hour", []byte("v"), time.Hour); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	if err := s.Set(ctx, "brief", []byte("v"), 20*time.Millisecond); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	if err := s.Set(ctx, "deleted", []byte("v"), 0); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	if _, err := s.Delete(ctx, "deleted"); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.Incr(ctx, "counter", time.Hour); err != nil {
+			t.Fatalf("Incr() = %v", err)
+		}
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	s = reopen(t, s)
+
+	if v, err := s.Get(ctx, "forever"); err != nil || string(v) != "v" {
+		t.Errorf("Get() = %q, %v, want v", v, err)
+	}
+	if ttl, err := s.TTL(ctx, "forever"); err != nil || ttl != -1 {
+		t.Errorf("TTL() of a key without expiry = %v, %v, want -1", ttl, err)
+	}
+	if ttl, err := s.TTL(ctx, "hour"); err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("TTL() = %v, %v, want about an hour", ttl, err)
+	}
+	if n, err := s.Incr(ctx, "counter", time.Hour); err != nil || n != 4 {
+		t.Errorf("Incr() = %d, %v, want 4", n, err)
+	}
+	for _, key := range []string{"brief", "deleted"} {
+		if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) = %v, want ErrNotFound", key, err)
+		}
+		if ttl, err := s.TTL(ctx, key); err != nil || ttl != 0 {
+			t.Errorf("TTL(%q) = %v, %v, want 0", key, ttl, err)
+		}
+	}
+}
+
+func TestFileStoreDamagedTail(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		damage func(b []byte) []byte
+	}{
+		{
+			name:   "torn payload",
+			damage: func(b []byte) []byte { return b[:len(b)-3] },
+		},
+		{
+			name:   "torn header",
+			damage: func(b []byte) []byte { return b[:len(b)-int(recordSize("last", entry{value: []byte("v3")}))+4] },
+		},
+		{
+			name: "checksum mismatch",
+			damage: func(b []byte) []byte {
+				b[len(b)-1] ^= 0xff
+				return b
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openTestFileStore(t)
+			for key, value := range map[string]string{"a": "v1", "b": "v2"} {
+				if err := s.Set(ctx, key, []byte(value), 0); err != nil {
+					t.Fatalf("Set() = %v", err)
+				}
+			}
+
+			// The file is rewritten when opened, so the record written next is the last one.
+			s = reopen(t, s)
+			if err := s.Set(ctx, "last", []byte("v3"), 0); err != nil {
+				t.Fatalf("Set() = %v", err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close() = %v", err)
+			}
+
+			b, err := os.ReadFile(s.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(s.path, tt.damage(b), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			s, err = OpenFileStore(s.path)
+			if err != nil {
+				t.Fatalf("OpenFileStore() of a damaged file = %v", err)
+			}
+			t.Cleanup(func() { _ = s.Close() })
+
+			if _, err := s.Get(ctx, "last"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get() of the damaged record = %v, want ErrNotFound", err)
+			}
+			for key, want := range map[string]string{"a": "v1", "b": "v2"} {
+				if v, err := s.Get(ctx, key); err != nil || string(v) != want {
+					t.Errorf("Get(%q) = %q, %v, want %s", key, v, err, want)
+				}
+			}
+
+			// The damaged record is dropped from the file, so records written after it are not lost.
+			if err := s.Set(ctx, "after", []byte("v4"), 0); err != nil {
+				t.Fatalf("Set() = %v", err)
+			}
+			s = reopen(t, s)
+			if v, err := s.Get(ctx, "after"); err != nil || string(v) != "v4" {
+				t.Errorf("Get() of a record written after the damaged one = %q, %v, want v4", v, err)
+			}
+		})
+	}
+}
+
+func TestFileStoreNotAStorageFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	if err := os.WriteFile(path, []byte("issuer: http://localhost\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenFileStore(path); err == nil {
+		t.Fatal("OpenFileStore() of a file of another format succeeded")
+	}
+	if b, _ := os.ReadFile(path); string(b) != "issuer: http://localhost\n" {
+		t.Errorf("OpenFileStore() changed a file of another format: %q", b)
+	}
+}
+
+func TestFileStoreCompact(t *testing.T) {
+	ctx := context.Background()
+	s := openTestFileStore(t)
+
+	for i := 0; i < 100; i++ {
+		if err := s.Set(ctx, "k", []byte("value"), 0); err != nil {
+			t.Fatalf("Set() = %v", err)
+		}
+	}
+	if err := s.Set(ctx, "brief", []byte("value"), 20*time.Millisecond); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	if err := s.Set(ctx, "deleted", []byte("value"), 0); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	if _, err := s.Delete(ctx, "deleted"); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact() = %v", err)
+	}
+
+	// Only the last record of the live key is left.
+	want := int64(fileHeaderSize) + recordSize("k", entry{value: []byte("value")})
+	info, err := os.Stat(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != want {
+		t.Fatalf("file size after Compact() = %d, want %d", info.Size(), want)
+	}
+
+	// The store keeps working on the new file.
+	if err := s.Set(ctx, "new", []byte("value"), 0); err != nil {
+		t.Fatalf("Set() after Compact() = %v", err)
+	}
+	s = reopen(t, s)
+	for _, key := range []string{"k", "new"} {
+		if _, err := s.Get(ctx, key); err != nil {
+			t.Errorf("Get(%q) = %v", key, err)
+		}
+	}
+}
+
+func TestFileStoreCompactIfNeeded(t *testing.T) {
+	ctx := context.Background()
+	s := openTestFileStore(t)
+
+	// Overwriting a large value makes the obsolete records outgrow the live one.
+	value := bytes.Repeat([]byte("x"), minCompactSize/4)
+	for i := 0; i < 6; i++ {
+		if err := s.Set(ctx, "k", value, 0); err != nil {
+			t.Fatalf("Set() = %v", err)
+		}
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() >= minCompactSize {
+		t.Errorf("file size = %d, want it compacted below %d", info.Size(), minCompactSize)
+	}
+	if v, err := s.Get(ctx, "k"); err != nil || len(v) != len(value) {
+		t.Errorf("Get() after compaction = %d bytes, %v, want %d", len(v), err, len(value))
+	}
+}
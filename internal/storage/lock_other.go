@@ -0,0 +1,12 @@
+//go:build !unix
+
+package storage
+
+import (
+	"os"
+)
+
+// lockFile does nothing where flock is not available: running two servers on the same file is not detected.
+func lockFile(*os.File) error {
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package storage
+
+import (
+	"testing"
+)
+
+func TestFileStoreLock(t *testing.T) {
+	s := openTestFileStore(t)
+
+	if other, err := OpenFileStore(s.path); err == nil {
+		_ = other.Close()
+		t.Fatal("OpenFileStore() of a file in use succeeded")
+	}
+
+	// The lock is released on Close.
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	other, err := OpenFileStore(s.path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() after Close() = %v", err)
+	}
+	_ = other.Close()
+}
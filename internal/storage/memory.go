@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value []byte
+	// expiresAt is zero for keys that never expire.
+	expiresAt time.Time
+}
+
+func newEntry(value []byte, ttl time.Duration, now time.Time) entry {
+	e := entry{value: slices.Clone(value)}
+	if ttl > 0 {
+		e.expiresAt = now.Add(ttl)
+	}
+
+	return e
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]entry
+	evictedAt time.Time
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		entries: map[string]entry{},
+	}
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.get(key, time.Now())
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return slices.Clone(e.value), nil
+}
+
+func (s *memoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = newEntry(value, ttl, time.Now())
+	return nil
+}
+
+func (s *memoryStore) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := s.get(key, now); ok {
+		return false, nil
+	}
+
+	s.entries[key] = newEntry(value, ttl, now)
+	return true, nil
+}
+
+func (s *memoryStore) Take(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.get(key, time.Now())
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	delete(s.entries, key)
+	return e.value, nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.get(key, time.Now())
+	delete(s.entries, key)
+
+	return ok, nil
+}
+
+func (s *memoryStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	e, ok := s.get(key, now)
+	if !ok {
+		e = newEntry([]byte("0"), ttl, now)
+	}
+
+	n, err := strconv.ParseInt(string(e.value), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	n++
+	e.value = []byte(strconv.FormatInt(n, 10))
+	s.entries[key] = e
+
+	return n, nil
+}
+
+func (s *memoryStore) TTL(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	e, ok := s.get(key, now)
+	switch {
+	case !ok:
+		return 0, nil
+	case e.expiresAt.IsZero():
+		return -1, nil
+	default:
+		return e.expiresAt.Sub(now), nil
+	}
+}
+
+func (s *memoryStore) Throttle(_ context.Context, key string, interval time.Duration, burst int) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	tat, err := parseTAT(s.get(key, now))
+	if err != nil {
+		return 0, err
+	}
+
+	next, wait := gcra(tat, now, interval, burst)
+	if wait > 0 {
+		return wait, nil
+	}
+
+	s.entries[key] = newEntry(formatTAT(next), next.Sub(now), now)
+	return 0, nil
+}
+
+// get evicts expired entries at most once a second.
+func (s *memoryStore) get(key string, now time.Time) (entry, bool) {
+	if now.Sub(s.evictedAt) > time.Second {
+		for k, e := range s.entries {
+			if e.expired(now) {
+				delete(s.entries, k)
+			}
+		}
+		s.evictedAt = now
+	}
+
+	e, ok := s.entries[key]
+	if !ok || e.expired(now) {
+		return entry{}, false
+	}
+
+	return e, true
+}
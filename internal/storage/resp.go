@@ -0,0 +1,493 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRESPTimeout = 2 * time.Second
+	defaultRESPMaxIdle = 16
+	defaultRESPPort    = "6379"
+
+	maxRESPLineLength  = 64 << 10
+	maxRESPBulkLength  = 512 << 20
+	maxRESPArrayLength = 1 << 20
+)
+
+var (
+	errRESPClosed = errors.New("storage is closed")
+)
+
+// throttleScript runs the generic cell rate algorithm of Throttle on the server, in microseconds of the
+// server's clock so replicas with skewed clocks share a bucket.
+const throttleScript = `local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then tat = now end
+local next = tat + interval
+local wait = next - now - tonumber(ARGV[2]) * interval
+if wait > 0 then return wait end
+redis.call('SET', KEYS[1], string.format('%.0f', next), 'PX', math.ceil((next - now) / 1000))
+return 0`
+
+type RESPError string
+
+func (e RESPError) Error() string {
+	return "redis: " + string(e)
+}
+
+// RESPStore needs Redis 6.2 or a compatible server.
+type RESPStore struct {
+	addr     string
+	username string
+	password string
+	database int
+	tls      *tls.Config
+	timeout  time.Duration
+	maxIdle  int
+
+	mu     sync.Mutex
+	idle   []*respConn
+	closed bool
+}
+
+type RESPOption func(*RESPStore)
+
+// WithPassword authenticates as username when it is not empty.
+func WithPassword(username, password string) RESPOption {
+	return func(s *RESPStore) {
+		s.username, s.password = username, password
+	}
+}
+
+func WithDatabase(database int) RESPOption {
+	return func(s *RESPStore) {
+		s.database = database
+	}
+}
+
+func WithTLS(cfg *tls.Config) RESPOption {
+	return func(s *RESPStore) {
+		s.tls = cfg
+	}
+}
+
+// WithTimeout bounds the time a command may take, connecting included, when the context has no earlier
+// deadline.
+func WithTimeout(timeout time.Duration) RESPOption {
+	return func(s *RESPStore) {
+		s.timeout = timeout
+	}
+}
+
+func WithMaxIdleConns(n int) RESPOption {
+	return func(s *RESPStore) {
+		s.maxIdle = n
+	}
+}
+
+// NewRESPStore opens connections when needed.
+func NewRESPStore(addr string, opts ...RESPOption) *RESPStore {
+	s := &RESPStore{
+		addr:    addr,
+		timeout: defaultRESPTimeout,
+		maxIdle: defaultRESPMaxIdle,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func respOptions(u *url.URL) (string, []RESPOption, error) {
+	if u.Hostname() == "" {
+		return "", nil, fmt.Errorf("storage URL %s has no host", u.Redacted())
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultRESPPort)
+	}
+
+	var opts []RESPOption
+	if password, ok := u.User.Password(); ok {
+		opts = append(opts, WithPassword(u.User.Username(), password))
+	}
+
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil || n < 0 {
+			return "", nil, fmt.Errorf("invalid database %q in storage URL", db)
+		}
+		opts = append(opts, WithDatabase(n))
+	}
+
+	if u.Scheme == schemeRESPTLS {
+		opts = append(opts, WithTLS(&tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}))
+	}
+
+	return addr, opts, nil
+}
+
+func (s *RESPStore) Get(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.nil {
+		return nil, ErrNotFound
+	}
+
+	return r.bulk, nil
+}
+
+func (s *RESPStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := s.do(ctx, setArgs(key, value, ttl)...)
+	return err
+}
+
+func (s *RESPStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	r, err := s.do(ctx, append(setArgs(key, value, ttl), "NX")...)
+	if err != nil {
+		return false, err
+	}
+
+	return !r.nil, nil
+}
+
+func (s *RESPStore) Take(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.do(ctx, "GETDEL", key)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.nil {
+		return nil, ErrNotFound
+	}
+
+	return r.bulk, nil
+}
+
+func (s *RESPStore) Delete(ctx context.Context, key string) (bool, error) {
+	r, err := s.do(ctx, "DEL", key)
+	if err != nil {
+		return false, err
+	}
+
+	return r.integer > 0, nil
+}
+
+func (s *RESPStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	// The counter is created with its ttl first, since INCR would create it without one. INCR keeps the ttl.
+	replies, err := s.pipeline(ctx, append(setArgs(key, []byte("0"), ttl), "NX"), []string{"INCR", key})
+	if err != nil {
+		return 0, err
+	}
+
+	return replies[1].integer, nil
+}
+
+func (s *RESPStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	r, err := s.do(ctx, "PTTL", key)
+	if err != nil {
+		return 0, err
+	}
+
+	switch r.integer {
+	case -2:
+		return 0, nil
+	case -1:
+		return -1, nil
+	default:
+		return time.Duration(r.integer) * time.Millisecond, nil
+	}
+}
+
+func (s *RESPStore) Throttle(ctx context.Context, key string, interval time.Duration, burst int) (time.Duration, error) {
+	micros := strconv.FormatInt(max(interval.Microseconds(), 1), 10)
+	r, err := s.do(ctx, "EVAL", throttleScript, "1", key, micros, strconv.Itoa(burst))
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(r.integer) * time.Microsecond, nil
+}
+
+func (s *RESPStore) Ping(ctx context.Context) error {
+	_, err := s.do(ctx, "PING")
+	return err
+}
+
+// Close closes the idle connections. Commands fail once the store is closed.
+func (s *RESPStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for _, c := range s.idle {
+		_ = c.conn.Close()
+	}
+	s.idle = nil
+
+	return nil
+}
+
+func setArgs(key string, value []byte, ttl time.Duration) []string {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+
+	return args
+}
+
+func (s *RESPStore) do(ctx context.Context, args ...string) (respReply, error) {
+	replies, err := s.pipeline(ctx, args)
+	if err != nil {
+		return respReply{}, err
+	}
+
+	return replies[0], nil
+}
+
+// pipeline fails with the first error reply.
+func (s *RESPStore) pipeline(ctx context.Context, cmds ...[]string) ([]respReply, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	c, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, _ := ctx.Deadline()
+	replies, err := c.roundTrip(deadline, cmds...)
+
+	var replyErr RESPError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection is in an unknown state after a network or protocol error.
+		_ = c.conn.Close()
+		return nil, err
+	}
+
+	s.release(c)
+	return replies, err
+}
+
+func (s *RESPStore) conn(ctx context.Context) (*respConn, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, errRESPClosed
+	}
+	if n := len(s.idle); n > 0 {
+		c := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mu.Unlock()
+		return c, nil
+	}
+	s.mu.Unlock()
+
+	return s.dial(ctx)
+}
+
+func (s *RESPStore) release(c *respConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || len(s.idle) >= s.maxIdle {
+		_ = c.conn.Close()
+		return
+	}
+
+	s.idle = append(s.idle, c)
+}
+
+func (s *RESPStore) dial(ctx context.Context) (*respConn, error) {
+	var conn net.Conn
+	var err error
+	if s.tls != nil {
+		conn, err = (&tls.Dialer{Config: s.tls}).DialContext(ctx, "tcp", s.addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to storage: %w", err)
+	}
+
+	c := &respConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+
+	var setup [][]string
+	switch {
+	case s.password != "" && s.username != "":
+		setup = append(setup, []string{"AUTH", s.username, s.password})
+	case s.password != "":
+		setup = append(setup, []string{"AUTH", s.password})
+	}
+	if s.database != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.database)})
+	}
+
+	if len(setup) > 0 {
+		deadline, _ := ctx.Deadline()
+		if _, err := c.roundTrip(deadline, setup...); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("could not set up storage connection: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+type respConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+func (c *respConn) roundTrip(deadline time.Time, cmds ...[]string) ([]respReply, error) {
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	for _, args := range cmds {
+		writeCommand(c.w, args...)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	// Every reply is read, even after an error reply, to leave the connection usable.
+	replies := make([]respReply, len(cmds))
+	var replyErr error
+	for i := range cmds {
+		r, err := readReply(c.r)
+		if err != nil {
+			return nil, err
+		}
+		if r.err != "" && replyErr == nil {
+			replyErr = RESPError(r.err)
+		}
+		replies[i] = r
+	}
+
+	return replies, replyErr
+}
+
+func writeCommand(w *bufio.Writer, args ...string) {
+	w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+}
+
+// respReply arrays are only used by commands serverd does not send.
+type respReply struct {
+	simple  string
+	err     string
+	integer int64
+	bulk    []byte
+	nil     bool
+	array   []respReply
+}
+
+func readReply(r *bufio.Reader) (respReply, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return respReply{}, err
+	}
+	if len(line) == 0 {
+		return respReply{}, errors.New("empty RESP reply")
+	}
+
+	payload := line[1:]
+	switch line[0] {
+	case '+':
+		return respReply{simple: payload}, nil
+	case '-':
+		return respReply{err: payload}, nil
+	case ':':
+		n, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return respReply{}, fmt.Errorf("invalid RESP integer %q", payload)
+		}
+		return respReply{integer: n}, nil
+	case '$':
+		b, ok, err := readBulk(r, payload)
+		if err != nil {
+			return respReply{}, err
+		}
+		return respReply{bulk: b, nil: !ok}, nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n > maxRESPArrayLength {
+			return respReply{}, fmt.Errorf("invalid RESP array length %q", payload)
+		}
+		if n < 0 {
+			return respReply{nil: true}, nil
+		}
+
+		reply := respReply{array: make([]respReply, n)}
+		for i := range reply.array {
+			if reply.array[i], err = readReply(r); err != nil {
+				return respReply{}, err
+			}
+		}
+		return reply, nil
+	default:
+		return respReply{}, fmt.Errorf("unknown RESP type %q", line[0])
+	}
+}
+
+// readBulk returns false for the nil bulk string.
+func readBulk(r *bufio.Reader, length string) ([]byte, bool, error) {
+	n, err := strconv.Atoi(length)
+	if err != nil || n < -1 || n > maxRESPBulkLength {
+		return nil, false, fmt.Errorf("invalid RESP bulk length %q", length)
+	}
+	if n == -1 {
+		return nil, false, nil
+	}
+
+	b := make([]byte, n+2)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, false, err
+	}
+	if string(b[n:]) != "\r\n" {
+		return nil, false, errors.New("RESP bulk string is not terminated")
+	}
+
+	return b[:n], true, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) || len(line) > maxRESPLineLength {
+		return "", errors.New("RESP line too long")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return "", errors.New("RESP line is not terminated")
+	}
+
+	return s, nil
+}
@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// respServer understands the commands RESPStore sends, which is enough to stand in for Redis in tests.
+type respServer struct {
+	store    Store
+	listener net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// startRESPServer keeps each numbered database in a separate part of the store.
+func startRESPServer(addr string, store Store) (*respServer, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &respServer{
+		store:    store,
+		listener: l,
+		conns:    map[net.Conn]struct{}{},
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+func (s *respServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *respServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	err := s.listener.Close()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *respServer) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *respServer) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+
+		_ = conn.Close()
+		s.wg.Done()
+	}()
+
+	r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+	store := s.store
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		if strings.EqualFold(args[0], "QUIT") {
+			w.WriteString("+OK\r\n")
+			_ = w.Flush()
+			return
+		}
+
+		if strings.EqualFold(args[0], "SELECT") && len(args) == 2 {
+			if n, err := strconv.Atoi(args[1]); err == nil && n >= 0 {
+				store = s.store
+				if n > 0 {
+					store = Prefixed(s.store, "db"+args[1]+":")
+				}
+				w.WriteString("+OK\r\n")
+			} else {
+				writeError(w, "ERR invalid DB index")
+			}
+		} else {
+			execute(w, store, args)
+		}
+
+		// Pipelined commands are answered together.
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func execute(w *bufio.Writer, store Store, args []string) {
+	ctx := context.Background()
+	name := strings.ToUpper(args[0])
+
+	arity := map[string]int{"PING": 1, "AUTH": 2, "GET": 2, "GETDEL": 2, "DEL": 2, "INCR": 2, "PTTL": 2, "SET": 3, "EVAL": 6}
+	n, ok := arity[name]
+	if !ok {
+		writeError(w, "ERR unknown command '"+args[0]+"'")
+		return
+	}
+	if len(args) < n {
+		writeError(w, "ERR wrong number of arguments for '"+args[0]+"' command")
+		return
+	}
+
+	switch name {
+	case "PING":
+		w.WriteString("+PONG\r\n")
+	case "AUTH":
+		// Connections are not authenticated, any credentials are accepted.
+		w.WriteString("+OK\r\n")
+	case "GET", "GETDEL":
+		get := store.Get
+		if name == "GETDEL" {
+			get = store.Take
+		}
+
+		value, err := get(ctx, args[1])
+		if errors.Is(err, ErrNotFound) {
+			w.WriteString("$-1\r\n")
+			return
+		}
+		if err != nil {
+			writeError(w, "ERR "+err.Error())
+			return
+		}
+		writeBulk(w, value)
+	case "DEL":
+		var deleted int64
+		for _, key := range args[1:] {
+			if ok, _ := store.Delete(ctx, key); ok {
+				deleted++
+			}
+		}
+		writeInteger(w, deleted)
+	case "INCR":
+		v, err := store.Incr(ctx, args[1], 0)
+		if err != nil {
+			writeError(w, "ERR value is not an integer or out of range")
+			return
+		}
+		writeInteger(w, v)
+	case "PTTL":
+		ttl, _ := store.TTL(ctx, args[1])
+		switch {
+		case ttl == 0:
+			writeInteger(w, -2)
+		case ttl < 0:
+			writeInteger(w, -1)
+		default:
+			writeInteger(w, max(ttl.Milliseconds(), 1))
+		}
+	case "SET":
+		executeSet(ctx, w, store, args)
+	case "EVAL":
+		// Only the script of Throttle runs, by the store's own implementation.
+		interval, err1 := strconv.ParseInt(args[4], 10, 64)
+		burst, err2 := strconv.Atoi(args[5])
+		if args[1] != throttleScript || args[2] != "1" || err1 != nil || err2 != nil {
+			writeError(w, "ERR unsupported script")
+			return
+		}
+
+		wait, err := store.Throttle(ctx, args[3], time.Duration(interval)*time.Microsecond, burst)
+		if err != nil {
+			writeError(w, "ERR "+err.Error())
+			return
+		}
+		writeInteger(w, (wait + time.Microsecond - 1).Microseconds())
+	}
+}
+
+func executeSet(ctx context.Context, w *bufio.Writer, store Store, args []string) {
+	var ttl time.Duration
+	var nx bool
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "PX", "EX":
+			if i+1 == len(args) {
+				writeError(w, "ERR syntax error")
+				return
+			}
+
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				writeError(w, "ERR invalid expire time in 'set' command")
+				return
+			}
+
+			ttl = time.Duration(n) * time.Millisecond
+			if strings.EqualFold(args[i], "EX") {
+				ttl = time.Duration(n) * time.Second
+			}
+			i++
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+	}
+
+	if !nx {
+		if err := store.Set(ctx, args[1], []byte(args[2]), ttl); err != nil {
+			writeError(w, "ERR "+err.Error())
+			return
+		}
+		w.WriteString("+OK\r\n")
+		return
+	}
+
+	ok, err := store.SetNX(ctx, args[1], []byte(args[2]), ttl)
+	switch {
+	case err != nil:
+		writeError(w, "ERR "+err.Error())
+	case ok:
+		w.WriteString("+OK\r\n")
+	default:
+		w.WriteString("$-1\r\n")
+	}
+}
+
+func writeError(w *bufio.Writer, msg string) {
+	w.WriteString("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(msg) + "\r\n")
+}
+
+func writeInteger(w *bufio.Writer, n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func writeBulk(w *bufio.Writer, b []byte) {
+	w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(line, "*"))
+	if !strings.HasPrefix(line, "*") || err != nil || n < 1 || n > maxRESPArrayLength {
+		return nil, fmt.Errorf("invalid RESP command %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("invalid RESP command argument %q", line)
+		}
+
+		b, ok, err := readBulk(r, line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("nil RESP command argument")
+		}
+		args[i] = string(b)
+	}
+
+	return args, nil
+}
@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestRESPStore(t *testing.T, opts ...RESPOption) *RESPStore {
+	t.Helper()
+
+	srv, err := startRESPServer("127.0.0.1:0", NewMemoryStore())
+	if err != nil {
+		t.Fatalf("could not start RESP server: %v", err)
+	}
+	t.Cleanup(func() { _ = srv.Close() })
+
+	store := NewRESPStore(srv.Addr(), opts...)
+	t.Cleanup(func() { _ = store.Close() })
+
+	return store
+}
+
+func TestRESPStore(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s *RESPStore)
+	}{
+		{
+			name: "get and set",
+			run: func(t *testing.T, s *RESPStore) {
+				if _, err := s.Get(ctx, "k"); !errors.Is(err, ErrNotFound) {
+					t.Fatalf("Get() of a missing key = %v, want ErrNotFound", err)
+				}
+				if err := s.Set(ctx, "k", []byte("v1"), 0); err != nil {
+					t.Fatalf("Set() = %v", err)
+				}
+				if err := s.Set(ctx, "k", []byte("v2"), time.Minute); err != nil {
+					t.Fatalf("Set() = %v", err)
+				}
+				if v, err := s.Get(ctx, "k"); err != nil || string(v) != "v2" {
+					t.Errorf("Get() = %q, %v, want v2", v, err)
+				}
+			},
+		},
+		{
+			name: "binary values",
+			run: func(t *testing.T, s *RESPStore) {
+				value := []byte("a\r\nb\x00c")
+				if err := s.Set(ctx, "k", value, 0); err != nil {
+					t.Fatalf("Set() = %v", err)
+				}
+				if v, err := s.Get(ctx, "k"); err != nil || string(v) != string(value) {
+					t.Errorf("Get() = %q, %v, want %q", v, err, value)
+				}
+			},
+		},
+		{
+			name: "SetNX only sets missing keys",
+			run: func(t *testing.T, s *RESPStore) {
+				if ok, err := s.SetNX(ctx, "k", []byte("first"), time.Minute); err != nil || !ok {
+					t.Fatalf("SetNX() of a missing key = %v, %v, want true", ok, err)
+				}
+				if ok, err := s.SetNX(ctx, "k", []byte("second"), time.Minute); err != nil || ok {
+					t.Fatalf("SetNX() of an existing key = %v, %v, want false", ok, err)
+				}
+				if v, err := s.Get(ctx, "k"); err != nil || string(v) != "first" {
+					t.Errorf("Get() = %q, %v, want first", v, err)
+				}
+			},
+		},
+		{
+			name: "SetNX of an expired key",
+			run: func(t *testing.T, s *RESPStore) {
+				if ok, err := s.SetNX(ctx, "k", []byte("first"), time.Millisecond); err != nil || !ok {
+					t.Fatalf("SetNX() = %v, %v, want true", ok, err)
+				}
+				time.Sleep(5 * time.Millisecond)
+				if ok, err := s.SetNX(ctx, "k", []byte("second"), time.Minute); err != nil || !ok {
+					t.Errorf("SetNX() after expiry = %v, %v, want true", ok, err)
+				}
+			},
+		},
+		{
+			name: "Take returns the value once",
+			run: func(t *testing.T, s *RESPStore) {
+				if err := s.Set(ctx, "k", []byte("v"), time.Minute); err != nil {
+					t.Fatalf("Set() = %v", err)
+				}
+				if v, err := s.Take(ctx, "k"); err != nil || string(v) != "v" {
+					t.Fatalf("Take() = %q, %v, want v", v, err)
+				}
+				if _, err := s.Take(ctx, "k"); !errors.Is(err, ErrNotFound) {
+					t.Errorf("second Take() = %v, want ErrNotFound", err)
+				}
+				if _, err := s.Get(ctx, "k"); !errors.Is(err, ErrNotFound) {
+					t.Errorf("Get() after Take() = %v, want ErrNotFound", err)
+				}
+			},
+		},
+		{
+			name: "Delete",
+			run: func(t *testing.T, s *RESPStore) {
+				if err := s.Set(ctx, "k", []byte("v"), 0); err != nil {
+					t.Fatalf("Set() = %v", err)
+				}
+				if ok, err := s.Delete(ctx, "k"); err != nil || !ok {
+					t.Errorf("Delete() = %v, %v, want true", ok, err)
+				}
+				if ok, err := s.Delete(ctx, "k"); err != nil || ok {
+					t.Errorf("Delete() of a missing key = %v, %v, want false", ok, err)
+				}
+			},
+		},
+		{
+			name: "Incr counts and keeps the first ttl",
+			run: func(t *testing.T, s *RESPStore) {
+				for want := int64(1); want <= 3; want++ {
+					if n, err := s.Incr(ctx, "counter", time.Minute); err != nil || n != want {
+						t.Fatalf("Incr() = %d, %v, want %d", n, err, want)
+					}
+				}
+				if n, err := s.Incr(ctx, "counter", time.Hour); err != nil || n != 4 {
+					t.Fatalf("Incr() = %d, %v, want 4", n, err)
+				}
+				if ttl, err := s.TTL(ctx, "counter"); err != nil || ttl <= 0 || ttl > time.Minute {
+					t.Errorf("TTL() = %v, %v, want at most the first ttl", ttl, err)
+				}
+			},
+		},
+		{
+			name: "Incr restarts after expiry",
+			run: func(t *testing.T, s *RESPStore) {
+				if _, err := s.Incr(ctx, "counter", time.Millisecond); err != nil {
+					t.Fatalf("Incr() = %v", err)
+				}
+				time.Sleep(5 * time.Millisecond)
+				if n, err := s.Incr(ctx, "counter", time.Minute); err != nil || n != 1 {
+					t.Errorf("Incr() after expiry = %d, %v, want 1", n, err)
+				}
+			},
+		},
+		{
+			name: "Incr of a value that is not a number",
+			run: func(t *testing.T, s *RESPStore) {
+				if err := s.Set(ctx, "k", []byte("abc"), 0); err != nil {
+					t.Fatalf("Set() = %v", err)
+				}
+
+				var replyErr RESPError
+				if _, err := s.Incr(ctx, "k", 0); !errors.As(err, &replyErr) {
+					t.Fatalf("Incr() = %v, want an error reply", err)
+				}
+
+				// An error reply leaves the connection usable.
+				if err := s.Ping(ctx); err != nil {
+					t.Errorf("Ping() after an error reply = %v", err)
+				}
+			},
+		},
+		{
+			name: "TTL",
+			run: func(t *testing.T, s *RESPStore) {
+				if ttl, err := s.TTL(ctx, "missing"); err != nil || ttl != 0 {
+					t.Errorf("TTL() of a missing key = %v, %v, want 0", ttl, err)
+				}
+				if err := s.Set(ctx, "forever", []byte("v"), 0); err != nil {
+					t.Fatalf("Set() = %v", err)
+				}
+				if ttl, err := s.TTL(ctx, "forever"); err != nil || ttl >= 0 {
+					t.Errorf("TTL() of a key without expiry = %v, %v, want negative", ttl, err)
+				}
+				if err := s.Set(ctx, "soon", []byte("v"), time.Minute); err != nil {
+					t.Fatalf("Set() = %v", err)
+				}
+				if ttl, err := s.TTL(ctx, "soon"); err != nil || ttl <= 0 || ttl > time.Minute {
+					t.Errorf("TTL() = %v, %v, want up to a minute", ttl, err)
+				}
+			},
+		},
+		{
+			name: "Lock",
+			run: func(t *testing.T, s *RESPStore) {
+				unlock, err := Lock(ctx, s, "lock", time.Minute)
+				if err != nil {
+					t.Fatalf("Lock() = %v", err)
+				}
+
+				waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+				defer cancel()
+				if _, err := Lock(waitCtx, s, "lock", time.Minute); err == nil {
+					t.Fatal("Lock() of a held lock succeeded")
+				}
+
+				unlock()
+				unlock, err = Lock(ctx, s, "lock", time.Minute)
+				if err != nil {
+					t.Fatalf("Lock() after unlock = %v", err)
+				}
+				unlock()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestRESPStore(t)
+			tt.run(t, s)
+		})
+	}
+}
+
+func TestRESPStoreDatabases(t *testing.T) {
+	ctx := context.Background()
+
+	srv, err := startRESPServer("127.0.0.1:0", NewMemoryStore())
+	if err != nil {
+		t.Fatalf("could not start RESP server: %v", err)
+	}
+	defer srv.Close()
+
+	stores := map[int]*RESPStore{}
+	for _, db := range []int{0, 1, 2} {
+		s := NewRESPStore(srv.Addr(), WithDatabase(db), WithPassword("user", "secret"))
+		defer s.Close()
+
+		if err := s.Set(ctx, "k", []byte(strconv.Itoa(db)), 0); err != nil {
+			t.Fatalf("Set() in database %d = %v", db, err)
+		}
+		stores[db] = s
+	}
+
+	for db, s := range stores {
+		if v, err := s.Get(ctx, "k"); err != nil || string(v) != strconv.Itoa(db) {
+			t.Errorf("Get() in database %d = %q, %v", db, v, err)
+		}
+	}
+}
+
+func TestRESPStoreReconnect(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStore()
+
+	srv, err := startRESPServer("127.0.0.1:0", memory)
+	if err != nil {
+		t.Fatalf("could not start RESP server: %v", err)
+	}
+	addr := srv.Addr()
+
+	s := NewRESPStore(addr, WithTimeout(time.Second))
+	defer s.Close()
+
+	if err := s.Set(ctx, "k", []byte("v"), 0); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+
+	if err := srv.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if _, err := s.Get(ctx, "k"); err == nil {
+		t.Fatal("Get() succeeded while the server is down")
+	}
+	if err := s.Ping(ctx); err == nil {
+		t.Fatal("Ping() succeeded while the server is down")
+	}
+
+	srv, err = startRESPServer(addr, memory)
+	if err != nil {
+		t.Fatalf("could not restart RESP server: %v", err)
+	}
+	defer srv.Close()
+
+	// Broken connections are dropped rather than reused, so the store connects again.
+	if v, err := s.Get(ctx, "k"); err != nil || string(v) != "v" {
+		t.Errorf("Get() after the server restarted = %q, %v, want v", v, err)
+	}
+}
+
+func TestRESPStoreClosed(t *testing.T) {
+	s := newTestRESPStore(t)
+
+	if err := s.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() = %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if err := s.Ping(context.Background()); !errors.Is(err, errRESPClosed) {
+		t.Errorf("Ping() of a closed store = %v, want %v", err, errRESPClosed)
+	}
+}
+
+func TestRESPStoreCanceledContext(t *testing.T) {
+	s := newTestRESPStore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.Ping(ctx); err == nil {
+		t.Error("Ping() with a canceled context succeeded")
+	}
+}
@@ -0,0 +1,241 @@
+// Package storage keeps the state serverd shares between requests behind a key-value Store: in memory,
+// in a file across restarts, or in a Redis server shared by replicas.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	schemeFile    = "file"
+	schemeRESP    = "redis"
+	schemeRESPTLS = "rediss"
+
+	lockRetryDelay = 10 * time.Millisecond
+)
+
+var (
+	ErrNotFound = errors.New("key not found")
+	// ErrLocked reports a lock still held by another caller when the context is done.
+	ErrLocked = errors.New("key is locked")
+)
+
+// Store operations are atomic, so replicas sharing a store can rely on SetNX, Take and Incr to coordinate.
+// A zero ttl never expires.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Take removes key, so only one caller gets the value.
+	Take(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) (bool, error)
+	// Incr creates the counter at 0 with the ttl, which further increments do not extend.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// TTL returns 0 when key does not exist and a negative duration when it never expires.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// Throttle takes a token from a bucket of burst tokens gaining one every interval. It returns how long
+	// until a token is available when the bucket is empty.
+	Throttle(ctx context.Context, key string, interval time.Duration, burst int) (time.Duration, error)
+}
+
+// Open returns the store of a URL:
+//   - the memory store when it is empty,
+//   - a file store for file:path or file:///path,
+//   - a RESP store for redis://[[user]:password@]host[:port][/database], or rediss:// for TLS.
+//
+// The closer closes the store. RESP stores connect when first used.
+func Open(rawURL string) (Store, io.Closer, error) {
+	u, err := parseURL(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case u == nil:
+		return NewMemoryStore(), io.NopCloser(nil), nil
+	case u.Scheme == schemeFile:
+		s, err := OpenFileStore(filePath(u))
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, s, nil
+	default:
+		addr, opts, err := respOptions(u)
+		if err != nil {
+			return nil, nil, err
+		}
+		s := NewRESPStore(addr, opts...)
+		return s, s, nil
+	}
+}
+
+func ValidateURL(rawURL string) error {
+	u, err := parseURL(rawURL)
+	if err != nil || u == nil {
+		return err
+	}
+
+	if u.Scheme == schemeFile {
+		if filePath(u) == "" {
+			return errors.New("storage URL has no path")
+		}
+		return nil
+	}
+
+	_, _, err = respOptions(u)
+	return err
+}
+
+// FilePath returns "" for URLs other than file: ones.
+func FilePath(rawURL string) string {
+	u, err := parseURL(rawURL)
+	if err != nil || u == nil || u.Scheme != schemeFile {
+		return ""
+	}
+
+	return filePath(u)
+}
+
+func parseURL(rawURL string) (*url.URL, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// The error would repeat the URL and its password.
+		return nil, errors.New("invalid storage URL")
+	}
+
+	switch u.Scheme {
+	case schemeFile, schemeRESP, schemeRESPTLS:
+		return u, nil
+	default:
+		return nil, fmt.Errorf("unsupported storage URL scheme %q", u.Scheme)
+	}
+}
+
+func filePath(u *url.URL) string {
+	if u.Opaque != "" {
+		return u.Opaque
+	}
+
+	return u.Path
+}
+
+type prefixed struct {
+	store  Store
+	prefix string
+}
+
+// Prefixed keeps the state of issuers sharing a store apart.
+func Prefixed(store Store, prefix string) Store {
+	return prefixed{store: store, prefix: prefix}
+}
+
+func (p prefixed) Get(ctx context.Context, key string) ([]byte, error) {
+	return p.store.Get(ctx, p.prefix+key)
+}
+
+func (p prefixed) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return p.store.Set(ctx, p.prefix+key, value, ttl)
+}
+
+func (p prefixed) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return p.store.SetNX(ctx, p.prefix+key, value, ttl)
+}
+
+func (p prefixed) Take(ctx context.Context, key string) ([]byte, error) {
+	return p.store.Take(ctx, p.prefix+key)
+}
+
+func (p prefixed) Delete(ctx context.Context, key string) (bool, error) {
+	return p.store.Delete(ctx, p.prefix+key)
+}
+
+func (p prefixed) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return p.store.Incr(ctx, p.prefix+key, ttl)
+}
+
+func (p prefixed) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return p.store.TTL(ctx, p.prefix+key)
+}
+
+func (p prefixed) Throttle(ctx context.Context, key string, interval time.Duration, burst int) (time.Duration, error) {
+	return p.store.Throttle(ctx, p.prefix+key, interval, burst)
+}
+
+// gcra is the generic cell rate algorithm: a bucket is stored as the time it will be full again, tat, so
+// taking a token reads and writes a single key. It returns the new tat, or how long to wait when the bucket
+// is empty.
+func gcra(tat, now time.Time, interval time.Duration, burst int) (time.Time, time.Duration) {
+	if tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(interval)
+	if wait := next.Sub(now) - time.Duration(burst)*interval; wait > 0 {
+		return tat, wait
+	}
+
+	return next, 0
+}
+
+func parseTAT(e entry, ok bool) (time.Time, error) {
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	n, err := strconv.ParseInt(string(e.value), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, n), nil
+}
+
+func formatTAT(tat time.Time) []byte {
+	return []byte(strconv.FormatInt(tat.UnixNano(), 10))
+}
+
+// Lock expires after ttl so a crashed holder cannot keep it forever; the holder must release it well
+// before by calling unlock.
+func Lock(ctx context.Context, store Store, key string, ttl time.Duration) (unlock func(), err error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("could not generate lock token: %w", err)
+	}
+	token := []byte(base64.RawURLEncoding.EncodeToString(b))
+
+	key = "lock:" + key
+	for {
+		ok, err := store.SetNX(ctx, key, token, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrLocked, ctx.Err())
+		case <-time.After(lockRetryDelay):
+		}
+	}
+
+	return func() {
+		// A lock that expired may be held by another caller by now; it is only removed while it is ours.
+		if value, err := store.Get(context.Background(), key); err == nil && string(value) == string(token) {
+			_, _ = store.Delete(context.Background(), key)
+		}
+	}, nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testStores returns every Store implementation, the RESP store served by the stand-in server.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	file, err := OpenFileStore(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("OpenFileStore() = %v", err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+
+	return map[string]Store{
+		"memory":   NewMemoryStore(),
+		"file":     file,
+		"resp":     newTestRESPStore(t),
+		"prefixed": Prefixed(NewMemoryStore(), "tenant:"),
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			// A full bucket allows burst requests at once, then one per interval.
+			for i := 0; i < 3; i++ {
+				if wait, err := s.Throttle(ctx, "slow", time.Hour, 3); err != nil || wait != 0 {
+					t.Fatalf("Throttle() #%d = %v, %v, want a token", i+1, wait, err)
+				}
+			}
+			wait, err := s.Throttle(ctx, "slow", time.Hour, 3)
+			if err != nil || wait <= 59*time.Minute || wait > time.Hour {
+				t.Fatalf("Throttle() of an empty bucket = %v, %v, want about an hour", wait, err)
+			}
+
+			// Denied requests take no token, so the wait does not grow.
+			if again, _ := s.Throttle(ctx, "slow", time.Hour, 3); again > wait {
+				t.Errorf("Throttle() after a denied request = %v, want at most %v", again, wait)
+			}
+
+			// Buckets are kept apart.
+			if wait, err := s.Throttle(ctx, "other", time.Hour, 3); err != nil || wait != 0 {
+				t.Errorf("Throttle() of another bucket = %v, %v, want a token", wait, err)
+			}
+
+			// An empty bucket gains one token per interval, not a new burst.
+			for i := 0; i < 2; i++ {
+				if wait, _ := s.Throttle(ctx, "fast", 30*time.Millisecond, 2); wait != 0 {
+					t.Fatalf("Throttle() #%d = %v, want a token", i+1, wait)
+				}
+			}
+			wait, _ = s.Throttle(ctx, "fast", 30*time.Millisecond, 2)
+			if wait <= 0 {
+				t.Fatal("Throttle() of an empty bucket allowed a request")
+			}
+			time.Sleep(wait)
+			if wait, _ := s.Throttle(ctx, "fast", 30*time.Millisecond, 2); wait != 0 {
+				t.Errorf("Throttle() after the refill = %v, want a token", wait)
+			}
+			if wait, _ := s.Throttle(ctx, "fast", 30*time.Millisecond, 2); wait <= 0 {
+				t.Error("Throttle() allowed a second request after refilling one token")
+			}
+		})
+	}
+}
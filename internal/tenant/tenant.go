@@ -0,0 +1,105 @@
+// Package tenant reads the tenants of a shared deployment, each an issuer of its own served under /t/{id}.
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/config"
+)
+
+const (
+	ConfigFile = "tenant.json"
+)
+
+var (
+	idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+)
+
+type Tenant struct {
+	ID     string
+	Dir    string
+	Config Config
+}
+
+// Config fields left unset fall back to the defaults of the deployment.
+type Config struct {
+	// Issuer defaults to the path of the tenant on the deployment, or is a public URL a proxy maps to it.
+	Issuer         string          `json:"issuer,omitempty"`
+	Audience       string          `json:"audience,omitempty"`
+	AccessTokenTTL config.Duration `json:"access_token_ttl,omitempty"`
+}
+
+func PathPrefix(id string) string {
+	return "/t/" + id
+}
+
+// Load reads the subdirectories of root, each named after its tenant ID. A missing root holds no tenants.
+func Load(root string) ([]Tenant, error) {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tenants []Tenant
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		if !idPattern.MatchString(e.Name()) {
+			return nil, fmt.Errorf("tenants directory %s: invalid tenant id %q", root, e.Name())
+		}
+
+		t := Tenant{ID: e.Name(), Dir: filepath.Join(root, e.Name())}
+		if t.Config, err = loadConfig(t.Path(ConfigFile)); err != nil {
+			return nil, err
+		}
+
+		tenants = append(tenants, t)
+	}
+
+	return tenants, nil
+}
+
+func (t Tenant) Path(name string) string {
+	return filepath.Join(t.Dir, name)
+}
+
+func (t Tenant) Issuer(deploymentIssuer string) string {
+	if t.Config.Issuer != "" {
+		return t.Config.Issuer
+	}
+
+	return strings.TrimSuffix(deploymentIssuer, "/") + PathPrefix(t.ID) + "/"
+}
+
+func loadConfig(path string) (Config, error) {
+	fileBytes, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(fileBytes, &cfg); err != nil {
+		return Config{}, fmt.Errorf("could not parse tenant config %s: %w", path, err)
+	}
+
+	if cfg.AccessTokenTTL < 0 {
+		return Config{}, fmt.Errorf("tenant config %s: negative access_token_ttl", path)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,136 @@
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/config"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		// dirs are the tenant directories to create, with the content of their configuration file if any.
+		dirs    map[string]string
+		wantIDs []string
+		wantErr string
+	}{
+		{name: "no tenants"},
+		{
+			name:    "tenants",
+			dirs:    map[string]string{"acme": "", "globex-2": `{"audience": "https://api.globex.example"}`},
+			wantIDs: []string{"acme", "globex-2"},
+		},
+		{name: "uppercase id", dirs: map[string]string{"Acme": ""}, wantErr: `invalid tenant id "Acme"`},
+		{name: "id starting with a dash", dirs: map[string]string{"-acme": ""}, wantErr: `invalid tenant id "-acme"`},
+		{name: "id with a dot", dirs: map[string]string{"acme.corp": ""}, wantErr: `invalid tenant id "acme.corp"`},
+		{name: "id too long", dirs: map[string]string{strings.Repeat("a", 64): ""}, wantErr: "invalid tenant id"},
+		{name: "malformed configuration", dirs: map[string]string{"acme": `{"issuer": `}, wantErr: "could not parse tenant config"},
+		{name: "invalid duration", dirs: map[string]string{"acme": `{"access_token_ttl": "soon"}`}, wantErr: "soon"},
+		{name: "negative duration", dirs: map[string]string{"acme": `{"access_token_ttl": "-1m"}`}, wantErr: "negative access_token_ttl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			// Files next to the tenant directories are not tenants.
+			if err := os.WriteFile(filepath.Join(root, "README"), nil, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			for id, content := range tt.dirs {
+				if err := os.Mkdir(filepath.Join(root, id), 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if content == "" {
+					continue
+				}
+				if err := os.WriteFile(filepath.Join(root, id, ConfigFile), []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tenants, err := Load(root)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() = %v", err)
+			}
+
+			var ids []string
+			for _, tenant := range tenants {
+				ids = append(ids, tenant.ID)
+				if tenant.Dir != filepath.Join(root, tenant.ID) {
+					t.Errorf("tenant %s: Dir = %s", tenant.ID, tenant.Dir)
+				}
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("Load() = tenants %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "acme"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	content := `{"issuer": "https://acme.example.com/", "audience": "https://api.acme.example.com", "access_token_ttl": "15m"}`
+	if err := os.WriteFile(filepath.Join(root, "acme", ConfigFile), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tenants, err := Load(root)
+	if err != nil || len(tenants) != 1 {
+		t.Fatalf("Load() = %v, %v, want one tenant", tenants, err)
+	}
+
+	want := Config{
+		Issuer:         "https://acme.example.com/",
+		Audience:       "https://api.acme.example.com",
+		AccessTokenTTL: config.Duration(15 * time.Minute),
+	}
+	if tenants[0].Config != want {
+		t.Errorf("Config = %+v, want %+v", tenants[0].Config, want)
+	}
+}
+
+func TestLoadMissingRoot(t *testing.T) {
+	tenants, err := Load(filepath.Join(t.TempDir(), "tenants"))
+	if err != nil || len(tenants) != 0 {
+		t.Errorf("Load() of a missing directory = %v, %v, want no tenants", tenants, err)
+	}
+}
+
+func TestIssuer(t *testing.T) {
+	tests := []struct {
+		name       string
+		tenant     Tenant
+		deployment string
+		want       string
+	}{
+		{name: "derived", tenant: Tenant{ID: "acme"}, deployment: "https://auth.example.com/", want: "https://auth.example.com/t/acme/"},
+		{name: "derived without trailing slash", tenant: Tenant{ID: "acme"}, deployment: "https://auth.example.com", want: "https://auth.example.com/t/acme/"},
+		{
+			name:       "configured",
+			tenant:     Tenant{ID: "acme", Config: Config{Issuer: "https://acme.example.com/"}},
+			deployment: "https://auth.example.com/",
+			want:       "https://acme.example.com/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tenant.Issuer(tt.deployment); got != tt.want {
+				t.Errorf("Issuer() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
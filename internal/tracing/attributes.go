@@ -1,6 +1,8 @@
 package tracing
 
 import (
+	"time"
+
 	"go.uber.org/zap"
 )
 
@@ -9,6 +11,11 @@ type AttributeType uint
 const (
 	AttributeTypeString AttributeType = iota
 	AttributeTypeInt
+	AttributeTypeBool
+	AttributeTypeFloat
+	AttributeTypeDuration
+	AttributeTypeError
+	AttributeTypeObject
 )
 
 type Attribute struct {
@@ -25,13 +32,61 @@ func Int(k string, v int) Attribute {
 	return Attribute{k, v, AttributeTypeInt}
 }
 
+func Bool(k string, v bool) Attribute {
+	return Attribute{k, v, AttributeTypeBool}
+}
+
+func Float(k string, v float64) Attribute {
+	return Attribute{k, v, AttributeTypeFloat}
+}
+
+func Duration(k string, v time.Duration) Attribute {
+	return Attribute{k, v, AttributeTypeDuration}
+}
+
+// Err logs nothing for a nil error.
+func Err(err error) Attribute {
+	return Attribute{"error", err, AttributeTypeError}
+}
+
+// Object logs v like its JSON encoding, with its secrets redacted like the other attributes.
+func Object(k string, v interface{}) Attribute {
+	return Attribute{k, v, AttributeTypeObject}
+}
+
 func toZapField(attr Attribute) zap.Field {
 	switch attr.Type {
 	case AttributeTypeString:
 		return zap.String(attr.Key, attr.Value.(string))
 	case AttributeTypeInt:
 		return zap.Int(attr.Key, attr.Value.(int))
+	case AttributeTypeBool:
+		return zap.Bool(attr.Key, attr.Value.(bool))
+	case AttributeTypeFloat:
+		return zap.Float64(attr.Key, attr.Value.(float64))
+	case AttributeTypeDuration:
+		return zap.Duration(attr.Key, attr.Value.(time.Duration))
+	case AttributeTypeError:
+		err, _ := attr.Value.(error)
+		return zap.NamedError(attr.Key, err)
+	case AttributeTypeObject:
+		return zap.Any(attr.Key, redactObject(attr.Value))
 	default:
 		return zap.Any(attr.Key, attr.Value)
 	}
 }
+
+// exportValue writes errors and durations as strings.
+func exportValue(attr Attribute) interface{} {
+	switch v := attr.Value.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	default:
+		if attr.Type == AttributeTypeObject {
+			return redactObject(v)
+		}
+		return v
+	}
+}
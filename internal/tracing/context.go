@@ -17,7 +17,7 @@ func SetInContext(ctx context.Context, tracer *Tracer) context.Context {
 func FromContext(ctx context.Context) *Tracer {
 	tracer := ctx.Value(contextKeyTracer)
 	if tracer == nil {
-		return New(nil, Noop())
+		return New(Noop())
 	}
 
 	return tracer.(*Tracer)
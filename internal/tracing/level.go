@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type Level int8
+
+const (
+	LevelDebug = Level(zapcore.DebugLevel)
+	LevelInfo  = Level(zapcore.InfoLevel)
+	LevelWarn  = Level(zapcore.WarnLevel)
+	LevelError = Level(zapcore.ErrorLevel)
+)
+
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+}
+
+func (l Level) String() string {
+	return zapcore.Level(l).String()
+}
+
+type Format string
+
+const (
+	FormatJSON    Format = "json"
+	FormatConsole Format = "console"
+)
+
+// SetLevel also changes every tracer derived from the same one, while they log.
+func (tr *Tracer) SetLevel(level Level) {
+	tr.level.SetLevel(zapcore.Level(level))
+}
+
+func (tr *Tracer) Level() Level {
+	return Level(tr.level.Level())
+}
+
+// LevelHandler reports the level on GET and changes it on PUT, with a JSON body such as {"level":"debug"}.
+func (tr *Tracer) LevelHandler() http.Handler {
+	return tr.level
+}
@@ -1,6 +1,7 @@
 package tracing
 
 import (
+	"io"
 	"os"
 
 	"go.uber.org/zap"
@@ -11,22 +12,42 @@ type Tracer struct {
 	logTags []Attribute
 
 	zapLog *zap.Logger
+	level  zap.AtomicLevel
+	format Format
+	output io.Writer
+
+	spans *spanProcessor
 }
 
 func New(opts ...Option) *Tracer {
 	l := &Tracer{
-		zapLog: newZap(nil),
+		level:  zap.NewAtomicLevelAt(zap.InfoLevel),
+		format: FormatJSON,
+		output: os.Stdout,
+		spans:  newSpanProcessor(),
 	}
 
 	for _, opt := range opts {
 		opt(l)
 	}
 
+	if l.zapLog == nil {
+		l.zapLog = newZap(l.format, l.output, l.level, l.logTags)
+	}
+
+	zapLog := l.zapLog
+	l.spans.onError = func(err error) {
+		zapLog.Error("tracing failed", zap.Error(err))
+	}
+	l.spans.run()
+
 	return l
 }
 
-func newZap(logTags []Attribute) *zap.Logger {
+// newZap keeps secrets out of the entries.
+func newZap(format Format, output io.Writer, level zap.AtomicLevel, logTags []Attribute) *zap.Logger {
 	encoderCfg := zapcore.EncoderConfig{
+		TimeKey:        "time",
 		MessageKey:     "msg",
 		LevelKey:       "level",
 		NameKey:        "logger",
@@ -34,8 +55,15 @@ func newZap(logTags []Attribute) *zap.Logger {
 		EncodeTime:     zapcore.ISO8601TimeEncoder,
 		EncodeDuration: zapcore.StringDurationEncoder,
 	}
-	core := zapcore.NewCore(zapcore.NewJSONEncoder(encoderCfg), os.Stdout, zap.InfoLevel)
-	zapLog := zap.New(core, zap.AddCaller())
+
+	encoder := zapcore.NewJSONEncoder(encoderCfg)
+	if format == FormatConsole {
+		encoderCfg.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderCfg)
+	}
+
+	core := zapcore.NewCore(encoder, zapcore.AddSync(output), level)
+	zapLog := zap.New(redactCore{core}, zap.AddCaller())
 
 	if len(logTags) > 0 {
 		var logFields []zap.Field
@@ -1,13 +1,66 @@
 package tracing
 
 import (
+	"io"
+
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 type Option func(*Tracer)
 
+// WithLevel defaults to Info and can be changed while logging with SetLevel.
+func WithLevel(level Level) Option {
+	return func(tracer *Tracer) {
+		tracer.level.SetLevel(zapcore.Level(level))
+	}
+}
+
+// WithFormat defaults to JSON.
+func WithFormat(format Format) Option {
+	return func(tracer *Tracer) {
+		tracer.format = format
+	}
+}
+
+// WithOutput replaces stdout, such as with a RotatingFile.
+func WithOutput(w io.Writer) Option {
+	return func(tracer *Tracer) {
+		tracer.output = w
+	}
+}
+
 func Noop() Option {
 	return func(tracer *Tracer) {
 		tracer.zapLog = zap.NewNop()
 	}
 }
+
+// WithExporter adds to the exporters already set. Spans are not recorded without an exporter.
+func WithExporter(exporter Exporter) Option {
+	return func(tracer *Tracer) {
+		switch exporters := tracer.spans.exporter.(type) {
+		case nil:
+			tracer.spans.exporter = exporter
+		case multiExporter:
+			tracer.spans.exporter = append(exporters, exporter)
+		default:
+			tracer.spans.exporter = multiExporter{exporters, exporter}
+		}
+	}
+}
+
+// WithServiceName overrides the default service name, serverd.
+func WithServiceName(name string) Option {
+	return func(tracer *Tracer) {
+		tracer.spans.service = name
+	}
+}
+
+// WithSampleRatio only applies to new traces: traces started by a caller are recorded when the caller
+// records them.
+func WithSampleRatio(ratio float64) Option {
+	return func(tracer *Tracer) {
+		tracer.spans.ratio = ratio
+	}
+}
@@ -0,0 +1,132 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		path     string
+		header   http.Header
+		received otlpTraces
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		path, header = r.URL.Path, r.Header.Clone()
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}))
+	defer collector.Close()
+
+	exporter, err := NewOTLPExporter(collector.URL, WithOTLPHeaders(map[string]string{"Authorization": "Bearer collector-token"}))
+	if err != nil {
+		t.Fatalf("NewOTLPExporter() = %v", err)
+	}
+
+	start := time.Unix(1700000000, 0)
+	span := SpanData{
+		Name: "jws.sign",
+		Kind: SpanKindInternal,
+		SpanContext: SpanContext{
+			TraceID:    TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+			SpanID:     SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+			TraceState: "vendor=a",
+		},
+		Parent:     SpanID{0x01},
+		Start:      start,
+		End:        start.Add(time.Millisecond),
+		Attributes: []Attribute{String("jws.alg", "RS256"), Int("size", 42), Bool("cached", true), Float("ratio", 0.5)},
+		StatusCode: StatusError,
+		Service:    "serverd",
+	}
+
+	if err := exporter.Export(context.Background(), []SpanData{span, span}); err != nil {
+		t.Fatalf("Export() = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if path != otlpTracesPath {
+		t.Errorf("spans sent to %q, want %q", path, otlpTracesPath)
+	}
+	if got := header.Get("Authorization"); got != "Bearer collector-token" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	if len(received.ResourceSpans) != 1 || len(received.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("spans of one service are not grouped: %+v", received)
+	}
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("received %d spans, want 2", len(spans))
+	}
+
+	got := spans[0]
+	if got.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || got.SpanID != "00f067aa0ba902b7" || got.ParentSpanID != "0100000000000000" {
+		t.Errorf("span IDs = %s %s %s", got.TraceID, got.SpanID, got.ParentSpanID)
+	}
+	if got.StartTimeUnixNano != "1700000000000000000" || got.EndTimeUnixNano != "1700000000001000000" {
+		t.Errorf("span times = %s %s", got.StartTimeUnixNano, got.EndTimeUnixNano)
+	}
+	if got.Status.Code != StatusError || got.TraceState != "vendor=a" {
+		t.Errorf("span status = %+v, trace state %q", got.Status, got.TraceState)
+	}
+
+	values := map[string]otlpValue{}
+	for _, kv := range got.Attributes {
+		values[kv.Key] = kv.Value
+	}
+	if v := values["jws.alg"].StringValue; v == nil || *v != "RS256" {
+		t.Errorf("string attribute = %v", v)
+	}
+	if v := values["size"].IntValue; v == nil || *v != "42" {
+		t.Errorf("int attribute = %v", v)
+	}
+	if v := values["cached"].BoolValue; v == nil || !*v {
+		t.Errorf("bool attribute = %v", v)
+	}
+	if v := values["ratio"].DoubleValue; v == nil || *v != 0.5 {
+		t.Errorf("float attribute = %v", v)
+	}
+}
+
+func TestOTLPExporterCollectorError(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	exporter, err := NewOTLPExporter(collector.URL + "/custom/traces")
+	if err != nil {
+		t.Fatalf("NewOTLPExporter() = %v", err)
+	}
+
+	if err := exporter.Export(context.Background(), []SpanData{{Name: "request"}}); err == nil {
+		t.Error("Export() succeeded while the collector is unavailable")
+	}
+}
+
+func TestNewOTLPExporterInvalidEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"", "collector:4318", "ftp://collector", "http://"} {
+		if _, err := NewOTLPExporter(endpoint); err == nil {
+			t.Errorf("NewOTLPExporter(%q) succeeded", endpoint)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	spanQueueSize   = 2048
+	spanBatchSize   = 512
+	spanBatchDelay  = 5 * time.Second
+	spanExportLimit = 10 * time.Second
+)
+
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// spanProcessor exports in the background so requests never wait on the collector. Spans ended while
+// the queue is full are dropped.
+type spanProcessor struct {
+	exporter Exporter
+	service  string
+	ratio    float64
+	onError  func(error)
+
+	queue   chan SpanData
+	flushes chan chan struct{}
+}
+
+func newSpanProcessor() *spanProcessor {
+	return &spanProcessor{
+		service: "serverd",
+		ratio:   1,
+		onError: func(error) {},
+	}
+}
+
+func (p *spanProcessor) run() {
+	if p.exporter == nil {
+		return
+	}
+
+	p.queue = make(chan SpanData, spanQueueSize)
+	p.flushes = make(chan chan struct{})
+	go p.loop()
+}
+
+func (p *spanProcessor) enqueue(span SpanData) {
+	if p.queue == nil {
+		return
+	}
+
+	select {
+	case p.queue <- span:
+	default:
+	}
+}
+
+func (p *spanProcessor) flush() {
+	if p.queue == nil {
+		return
+	}
+
+	done := make(chan struct{})
+	p.flushes <- done
+	<-done
+}
+
+func (p *spanProcessor) loop() {
+	ticker := time.NewTicker(spanBatchDelay)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, spanBatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), spanExportLimit)
+		defer cancel()
+
+		if err := p.exporter.Export(ctx, batch); err != nil {
+			p.onError(fmt.Errorf("could not export spans: %w", err))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) == spanBatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case done := <-p.flushes:
+			for n := len(p.queue); n > 0; n-- {
+				batch = append(batch, <-p.queue)
+				if len(batch) == spanBatchSize {
+					export()
+				}
+			}
+			export()
+			close(done)
+		}
+	}
+}
+
+type multiExporter []Exporter
+
+func (m multiExporter) Export(ctx context.Context, spans []SpanData) error {
+	var errs []error
+	for _, exporter := range m {
+		errs = append(errs, exporter.Export(ctx, spans))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// W3C trace context headers, see https://www.w3.org/TR/trace-context/.
+const (
+	headerTraceParent = "Traceparent"
+	headerTraceState  = "Tracestate"
+
+	maxTraceStateLen = 512
+	flagSampled      = 0x01
+)
+
+// Extract ignores invalid headers, so a new trace starts.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := parseTraceParent(header.Get(headerTraceParent))
+	if !ok {
+		return ctx
+	}
+
+	// The list may be split across several headers.
+	if state := strings.Join(header.Values(headerTraceState), ","); len(state) <= maxTraceStateLen {
+		sc.TraceState = state
+	}
+
+	return context.WithValue(ctx, contextKeyRemoteSpan{}, sc)
+}
+
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	header.Set(headerTraceParent, "00-"+sc.TraceID.String()+"-"+sc.SpanID.String()+"-"+flags)
+
+	header.Del(headerTraceState)
+	if sc.TraceState != "" {
+		header.Set(headerTraceState, sc.TraceState)
+	}
+}
+
+// parseTraceParent ignores the fields versions after 00 may append.
+func parseTraceParent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, false
+	}
+
+	version, ok := decodeHex(parts[0], 1)
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+
+	traceID, ok := decodeHex(parts[1], len(TraceID{}))
+	if !ok {
+		return SpanContext{}, false
+	}
+
+	spanID, ok := decodeHex(parts[2], len(SpanID{}))
+	if !ok {
+		return SpanContext{}, false
+	}
+
+	flags, ok := decodeHex(parts[3], 1)
+	if !ok {
+		return SpanContext{}, false
+	}
+
+	sc := SpanContext{
+		Sampled: flags[0]&flagSampled != 0,
+		Remote:  true,
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+
+	return sc, sc.IsValid()
+}
+
+// decodeHex only accepts lowercase hex, as the trace context requires.
+func decodeHex(s string, n int) ([]byte, bool) {
+	if len(s) != 2*n || strings.ToLower(s) != s {
+		return nil, false
+	}
+
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
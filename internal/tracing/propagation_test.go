@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceParent string
+		traceState  []string
+		wantValid   bool
+		wantSampled bool
+		wantState   string
+	}{
+		{
+			name:        "sampled",
+			traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			traceState:  []string{"vendor=a"},
+			wantValid:   true,
+			wantSampled: true,
+			wantState:   "vendor=a",
+		},
+		{
+			name:        "not sampled",
+			traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			wantValid:   true,
+		},
+		{
+			name:        "tracestate split across headers",
+			traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			traceState:  []string{"vendor=a", "other=b"},
+			wantValid:   true,
+			wantSampled: true,
+			wantState:   "vendor=a,other=b",
+		},
+		{
+			name:        "later version with more fields",
+			traceParent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			wantValid:   true,
+			wantSampled: true,
+		},
+		{name: "version 00 with more fields", traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{name: "invalid version", traceParent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "zero trace ID", traceParent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "zero span ID", traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "uppercase", traceParent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01"},
+		{name: "short trace ID", traceParent: "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01"},
+		{name: "missing", traceParent: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.traceParent != "" {
+				header.Set(headerTraceParent, tt.traceParent)
+			}
+			for _, state := range tt.traceState {
+				header.Add(headerTraceState, state)
+			}
+
+			sc, _ := Extract(context.Background(), header).Value(contextKeyRemoteSpan{}).(SpanContext)
+			if sc.IsValid() != tt.wantValid {
+				t.Fatalf("extracted span context valid = %v, want %v", sc.IsValid(), tt.wantValid)
+			}
+			if !tt.wantValid {
+				return
+			}
+
+			if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+				t.Errorf("extracted %s-%s", sc.TraceID, sc.SpanID)
+			}
+			if sc.Sampled != tt.wantSampled {
+				t.Errorf("Sampled = %v, want %v", sc.Sampled, tt.wantSampled)
+			}
+			if sc.TraceState != tt.wantState {
+				t.Errorf("TraceState = %q, want %q", sc.TraceState, tt.wantState)
+			}
+			if !sc.Remote {
+				t.Error("extracted span context is not remote")
+			}
+		})
+	}
+}
+
+func TestInject(t *testing.T) {
+	tracer := New(Noop(), WithExporter(NewWriterExporter(&lockedBuffer{})))
+
+	incoming := http.Header{}
+	incoming.Set(headerTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	incoming.Set(headerTraceState, "vendor=a")
+
+	ctx, span := tracer.StartServerSpan(Extract(context.Background(), incoming), "request")
+	defer span.End()
+
+	outgoing := http.Header{}
+	outgoing.Set(headerTraceState, "stale=1")
+	Inject(ctx, outgoing)
+
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanContext().SpanID.String() + "-01"
+	if got := outgoing.Get(headerTraceParent); got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+	if got := outgoing.Get(headerTraceState); got != "vendor=a" {
+		t.Errorf("tracestate = %q, want %q", got, "vendor=a")
+	}
+
+	// Nothing is injected outside of a span.
+	outside := http.Header{}
+	Inject(context.Background(), outside)
+	if len(outside) != 0 {
+		t.Errorf("headers injected without a span: %v", outside)
+	}
+}
@@ -0,0 +1,164 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// sensitiveKeys are the fields, parameters and headers holding secrets, lowercase with - and . as _.
+	sensitiveKeys = map[string]bool{
+		"authorization":       true,
+		"proxy_authorization": true,
+		"cookie":              true,
+		"set_cookie":          true,
+		"password":            true,
+		"secret":              true,
+		"client_secret":       true,
+		"client_assertion":    true,
+		"assertion":           true,
+		"token":               true,
+		"access_token":        true,
+		"refresh_token":       true,
+		"id_token":            true,
+		"subject_token":       true,
+		"actor_token":         true,
+		"code":                true,
+		"device_code":         true,
+		"code_verifier":       true,
+		"dpop":                true,
+	}
+
+	jwtPattern         = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*(\.[A-Za-z0-9_-]*){2,4}`)
+	credentialsPattern = regexp.MustCompile(`(?i)\b(Basic|Bearer|DPoP)\s+[A-Za-z0-9._~+/=-]+`)
+)
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		key = key[i+1:]
+	}
+	key = strings.ReplaceAll(key, "-", "_")
+
+	return sensitiveKeys[key] || strings.HasSuffix(key, "_secret") || strings.HasSuffix(key, "_token") ||
+		strings.HasSuffix(key, "_password")
+}
+
+func RedactString(s string) string {
+	if !strings.Contains(s, "eyJ") && !credentialsPattern.MatchString(s) {
+		return s
+	}
+
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	return credentialsPattern.ReplaceAllString(s, "$1 "+redacted)
+}
+
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return redacted
+	}
+
+	for key, v := range values {
+		for i := range v {
+			if isSensitiveKey(key) {
+				v[i] = redacted
+			} else {
+				v[i] = RedactString(v[i])
+			}
+		}
+	}
+
+	return values.Encode()
+}
+
+// redactObject goes through the JSON encoding of the object.
+func redactObject(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return redacted
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return redacted
+	}
+
+	return redactValue(generic)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if isSensitiveKey(key) {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue(value)
+			}
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+		return v
+	case string:
+		return RedactString(v)
+	default:
+		return v
+	}
+}
+
+// redactCore keeps secrets out of the entries written by its core: fields named like secrets are replaced,
+// and tokens in messages, strings and errors are masked.
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+func (c redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = RedactString(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redactedFields := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch {
+		case isSensitiveKey(f.Key) && f.Type != zapcore.SkipType:
+			f = zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: redacted}
+		case f.Type == zapcore.StringType:
+			f.String = RedactString(f.String)
+		case f.Type == zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok {
+				f.Interface = errors.New(RedactString(err.Error()))
+			}
+		}
+		redactedFields[i] = f
+	}
+
+	return redactedFields
+}
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxSize    int64
+		maxBackups int
+		writes     []string
+		// want holds the content of the file then of its backups, "" for a file that must not exist.
+		want []string
+	}{
+		{
+			name:       "rotation",
+			maxSize:    10,
+			maxBackups: 2,
+			writes:     []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n", "ffff\n", "gggg\n"},
+			want:       []string{"gggg\n", "eeee\nffff\n", "cccc\ndddd\n", ""},
+		},
+		{
+			name:       "write larger than the maximum size",
+			maxSize:    4,
+			maxBackups: 1,
+			writes:     []string{"aaaaaaaa\n", "bbbbbbbb\n"},
+			want:       []string{"bbbbbbbb\n", "aaaaaaaa\n", ""},
+		},
+		{
+			name:    "no backups",
+			maxSize: 10,
+			writes:  []string{"aaaa\n", "bbbb\n", "cccc\n"},
+			want:    []string{"cccc\n", ""},
+		},
+		{
+			name:       "never rotated",
+			maxBackups: 2,
+			writes:     []string{"aaaa\n", "bbbb\n", "cccc\n"},
+			want:       []string{"aaaa\nbbbb\ncccc\n", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "serverd.log")
+			f, err := OpenRotatingFile(path, tt.maxSize, tt.maxBackups)
+			if err != nil {
+				t.Fatalf("OpenRotatingFile() = %v", err)
+			}
+			defer f.Close()
+
+			for _, s := range tt.writes {
+				if n, err := f.Write([]byte(s)); err != nil || n != len(s) {
+					t.Fatalf("Write() = %d, %v", n, err)
+				}
+			}
+
+			for i, want := range tt.want {
+				name := path
+				if i > 0 {
+					name = path + "." + strconv.Itoa(i)
+				}
+
+				b, err := os.ReadFile(name)
+				switch {
+				case want == "" && !errors.Is(err, os.ErrNotExist):
+					t.Errorf("%s exists: %q, %v", filepath.Base(name), b, err)
+				case want != "" && string(b) != want:
+					t.Errorf("%s = %q, %v, want %q", filepath.Base(name), b, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "serverd.log")
+	if err := os.WriteFile(path, []byte("aaaa\nbbbb\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The size of the existing file counts towards the maximum.
+	f, err := OpenRotatingFile(path, 12, 1)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() = %v", err)
+	}
+	if _, err := f.Write([]byte("cccc\n")); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if _, err := f.Write([]byte("dddd\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write() after Close() = %v, want os.ErrClosed", err)
+	}
+
+	if b, _ := os.ReadFile(path); string(b) != "cccc\n" {
+		t.Errorf("file = %q, want the write after the rotation", b)
+	}
+	if b, _ := os.ReadFile(path + ".1"); string(b) != "aaaa\nbbbb\n" {
+		t.Errorf("backup = %q, want the existing content", b)
+	}
+}
@@ -0,0 +1,287 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+type SpanKind int
+
+// Span kinds, numbered like OTLP.
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+type StatusCode int
+
+// Span statuses, numbered like OTLP.
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	// TraceState is passed on unchanged.
+	TraceState string
+	Remote     bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []Attribute
+}
+
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	Parent        SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Events        []Event
+	StatusCode    StatusCode
+	StatusMessage string
+	Service       string
+}
+
+// Spans that are not sampled still carry their span context, so the trace continues in the services
+// called. A nil span is valid and does nothing.
+type Span struct {
+	spans *spanProcessor
+	sc    SpanContext
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+
+	return s.sc
+}
+
+func (s *Span) IsRecording() bool {
+	if s == nil || !s.sc.Sampled {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.ended
+}
+
+// SetAttributes replaces the attributes with the same keys.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if !s.IsRecording() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, attr := range attrs {
+		replaced := false
+		for i := range s.data.Attributes {
+			if s.data.Attributes[i].Key == attr.Key {
+				s.data.Attributes[i], replaced = attr, true
+				break
+			}
+		}
+
+		if !replaced {
+			s.data.Attributes = append(s.data.Attributes, attr)
+		}
+	}
+}
+
+// SetStatus only keeps the description for errors.
+func (s *Span) SetStatus(code StatusCode, description string) {
+	if !s.IsRecording() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.StatusCode = code
+	s.data.StatusMessage = ""
+	if code == StatusError {
+		s.data.StatusMessage = description
+	}
+}
+
+// RecordError does nothing when err is nil.
+func (s *Span) RecordError(err error) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+
+	s.mu.Lock()
+	s.data.Events = append(s.data.Events, Event{
+		Name: "exception",
+		Time: time.Now(),
+		Attributes: []Attribute{
+			String("exception.type", fmt.Sprintf("%T", err)),
+			String("exception.message", err.Error()),
+		},
+	})
+	s.mu.Unlock()
+
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End hands the span to the exporters. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil || !s.sc.Sampled {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.spans.enqueue(data)
+}
+
+type contextKeySpan struct{}
+
+type contextKeyRemoteSpan struct{}
+
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, contextKeySpan{}, span)
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(contextKeySpan{}).(*Span)
+	return span
+}
+
+// StartSpan returns a nil span, which does nothing, when the context has no span, such as outside of a
+// request.
+func StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	span := parent.spans.start(parent.sc, name, SpanKindInternal, attrs)
+	return ContextWithSpan(ctx, span), span
+}
+
+// StartServerSpan continues the span of the context or the remote span of the request headers, and
+// starts a new trace without either.
+func (tr *Tracer) StartServerSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	parent := SpanFromContext(ctx).SpanContext()
+	if !parent.IsValid() {
+		parent, _ = ctx.Value(contextKeyRemoteSpan{}).(SpanContext)
+	}
+
+	span := tr.spans.start(parent, name, SpanKindServer, attrs)
+	return ContextWithSpan(ctx, span), span
+}
+
+func (p *spanProcessor) start(parent SpanContext, name string, kind SpanKind, attrs []Attribute) *Span {
+	sc := SpanContext{
+		TraceID:    parent.TraceID,
+		Sampled:    parent.Sampled,
+		TraceState: parent.TraceState,
+	}
+	if !parent.IsValid() {
+		sc.TraceID = newTraceID()
+		sc.Sampled = p.sampled(sc.TraceID)
+	}
+	sc.SpanID = newSpanID()
+
+	return &Span{
+		spans: p,
+		sc:    sc,
+		data: SpanData{
+			Name:        name,
+			Kind:        kind,
+			SpanContext: sc,
+			Parent:      parent.SpanID,
+			Start:       time.Now(),
+			Attributes:  append([]Attribute(nil), attrs...),
+			Service:     p.service,
+		},
+	}
+}
+
+// sampled decides from the trace ID whether a new trace is recorded, so the decision does not depend on which
+// replica starts the trace.
+func (p *spanProcessor) sampled(id TraceID) bool {
+	if p.exporter == nil || p.ratio <= 0 {
+		return false
+	}
+	if p.ratio >= 1 {
+		return true
+	}
+
+	return binary.BigEndian.Uint64(id[8:]) < uint64(p.ratio*math.MaxUint64)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+
+	return id
+}
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// lockedBuffer is a bytes.Buffer the exporter can write to while the test reads it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return bytes.Clone(b.buf.Bytes())
+}
+
+func exportedSpans(t *testing.T, tracer *Tracer, out *lockedBuffer) []writtenSpan {
+	t.Helper()
+
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+
+	var spans []writtenSpan
+	dec := json.NewDecoder(bytes.NewReader(out.Bytes()))
+	for dec.More() {
+		var span writtenSpan
+		if err := dec.Decode(&span); err != nil {
+			t.Fatalf("could not decode exported span: %v", err)
+		}
+		spans = append(spans, span)
+	}
+
+	return spans
+}
+
+func TestSpanExport(t *testing.T) {
+	out := &lockedBuffer{}
+	tracer := New(Noop(), WithExporter(NewWriterExporter(out)), WithServiceName("test"))
+
+	header := http.Header{}
+	header.Set(headerTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, server := tracer.StartServerSpan(Extract(context.Background(), header), "POST /token", String("http.method", "POST"))
+	_, child := StartSpan(ctx, "jws.sign", String("jws.alg", "RS256"))
+	child.SetAttributes(Int("size", 1), Int("size", 2), Bool("cached", true))
+	child.RecordError(errors.New("signing failed"))
+	child.End()
+	child.End()
+	server.SetStatus(StatusOK, "ignored")
+	server.End()
+
+	spans := exportedSpans(t, tracer, out)
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+
+	sign, request := spans[0], spans[1]
+	if request.Name != "POST /token" || request.Kind != "server" || request.Service != "test" {
+		t.Errorf("server span = %+v", request)
+	}
+	if request.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || request.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("server span is not a child of the remote span: trace %s, parent %s", request.TraceID, request.ParentSpanID)
+	}
+	if request.Status != "ok" || request.StatusMessage != "" {
+		t.Errorf("server span status = %q %q, want ok without message", request.Status, request.StatusMessage)
+	}
+
+	if sign.Kind != "internal" || sign.TraceID != request.TraceID || sign.ParentSpanID != request.SpanID {
+		t.Errorf("child span is not a child of the server span: %+v", sign)
+	}
+	if sign.Attributes["size"] != float64(2) || sign.Attributes["cached"] != true || sign.Attributes["jws.alg"] != "RS256" {
+		t.Errorf("child span attributes = %v", sign.Attributes)
+	}
+	if sign.Status != "error" || sign.StatusMessage != "signing failed" {
+		t.Errorf("child span status = %q %q", sign.Status, sign.StatusMessage)
+	}
+	if len(sign.Events) != 1 || sign.Events[0].Name != "exception" || sign.Events[0].Attributes["exception.message"] != "signing failed" {
+		t.Errorf("child span events = %+v", sign.Events)
+	}
+}
+
+func TestSpanSampling(t *testing.T) {
+	tests := []struct {
+		name        string
+		ratio       float64
+		traceParent string
+		wantSampled bool
+	}{
+		{name: "new trace always sampled", ratio: 1, wantSampled: true},
+		{name: "new trace never sampled", ratio: 0},
+		{name: "caller sampled", ratio: 0, traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantSampled: true},
+		{name: "caller not sampled", ratio: 1, traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &lockedBuffer{}
+			tracer := New(Noop(), WithExporter(NewWriterExporter(out)), WithSampleRatio(tt.ratio))
+
+			header := http.Header{}
+			if tt.traceParent != "" {
+				header.Set(headerTraceParent, tt.traceParent)
+			}
+
+			_, span := tracer.StartServerSpan(Extract(context.Background(), header), "request")
+			if span.SpanContext().Sampled != tt.wantSampled {
+				t.Errorf("Sampled = %v, want %v", span.SpanContext().Sampled, tt.wantSampled)
+			}
+			span.End()
+
+			if spans := exportedSpans(t, tracer, out); (len(spans) == 1) != tt.wantSampled {
+				t.Errorf("exported %d spans, sampled %v", len(spans), tt.wantSampled)
+			}
+		})
+	}
+}
+
+func TestStartSpanWithoutParent(t *testing.T) {
+	ctx, span := StartSpan(context.Background(), "orphan")
+	if span != nil {
+		t.Fatal("StartSpan() without a span in the context returned a span")
+	}
+	if ctx != context.Background() {
+		t.Error("StartSpan() without a span in the context changed the context")
+	}
+
+	// A nil span does nothing.
+	span.SetAttributes(String("k", "v"))
+	span.RecordError(errors.New("failed"))
+	span.End()
+}
@@ -8,10 +8,20 @@ import (
 	"go.uber.org/zap"
 )
 
+func (tr *Tracer) Debug(format string, args ...interface{}) {
+	if tr.zapLog.Core().Enabled(zap.DebugLevel) {
+		tr.zapLog.Debug(fmt.Sprintf(format, args...))
+	}
+}
+
 func (tr *Tracer) Info(format string, args ...interface{}) {
 	tr.zapLog.Info(fmt.Sprintf(format, args...))
 }
 
+func (tr *Tracer) Warn(format string, args ...interface{}) {
+	tr.zapLog.Warn(fmt.Sprintf(format, args...))
+}
+
 func (tr *Tracer) Error(err error, format string, args ...interface{}) {
 	logFields := []zap.Field{
 		zap.Error(err),
@@ -41,7 +51,10 @@ func (tr *Tracer) WithAttributes(attrs ...Attribute) *Tracer {
 	return &cloned
 }
 
+// Flush exports the ended spans and writes the buffered logs.
 func (tr *Tracer) Flush() error {
+	tr.spans.flush()
+
 	if err := tr.zapLog.Sync(); err != nil {
 		// Ignore this stderr https://github.com/uber-go/zap/issues/328
 		if !errors.Is(err, syscall.ENOTTY) && !errors.Is(err, syscall.EINVAL) {
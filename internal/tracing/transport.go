@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"net/http"
+)
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// NewTransport traces requests in a client span whose trace they carry to the service called. base
+// defaults to http.DefaultTransport.
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return transport{base: base}
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	parent := SpanFromContext(req.Context())
+	if parent == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	span := parent.spans.start(parent.sc, "HTTP "+req.Method, SpanKindClient, []Attribute{
+		String("http.request.method", req.Method),
+		String("server.address", req.URL.Host),
+		String("url.path", req.URL.Path),
+	})
+	defer span.End()
+
+	// A RoundTripper must not modify the request it is given.
+	ctx := ContextWithSpan(req.Context(), span)
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(StatusError, resp.Status)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,106 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+type fileStore struct {
+	path string
+
+	mu      sync.RWMutex
+	modTime time.Time
+	byID    map[string]User
+	byName  map[string]User
+}
+
+// NewFileStore reloads the file whenever its modification time changes; a missing file holds no users.
+func NewFileStore(path string) (Store, error) {
+	s := &fileStore{path: path}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *fileStore) Authenticate(_ context.Context, username, password string) (User, error) {
+	if err := s.reload(); err != nil {
+		return User{}, err
+	}
+
+	s.mu.RLock()
+	byName := s.byName
+	s.mu.RUnlock()
+
+	return authenticate(byName, username, password)
+}
+
+func (s *fileStore) Get(_ context.Context, id string) (User, error) {
+	if err := s.reload(); err != nil {
+		return User{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.byID[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+
+	return u, nil
+}
+
+func (s *fileStore) reload() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.mu.Lock()
+		s.modTime, s.byID, s.byName = time.Time{}, nil, nil
+		s.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	upToDate := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if upToDate {
+		return nil
+	}
+
+	fileBytes, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	var users []User
+	if err := json.Unmarshal(fileBytes, &users); err != nil {
+		return fmt.Errorf("could not parse users file %s: %w", s.path, err)
+	}
+
+	byID := make(map[string]User, len(users))
+	byName := make(map[string]User, len(users))
+	for _, u := range users {
+		if u.ID == "" || u.Username == "" {
+			return fmt.Errorf("users file %s: user without id or username", s.path)
+		}
+
+		byID[u.ID] = u
+		byName[u.Username] = u
+	}
+
+	s.mu.Lock()
+	s.modTime, s.byID, s.byName = info.ModTime(), byID, byName
+	s.mu.Unlock()
+
+	return nil
+}
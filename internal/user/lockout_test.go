@@ -0,0 +1,143 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/storage"
+)
+
+type countingStore struct {
+	Store
+	verified atomic.Int64
+}
+
+func (s *countingStore) Authenticate(ctx context.Context, username, password string) (User, error) {
+	s.verified.Add(1)
+	return s.Store.Authenticate(ctx, username, password)
+}
+
+func newTestLockoutStore(t *testing.T, policy LockoutPolicy) (Store, *countingStore, storage.Store) {
+	t.Helper()
+
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword() = %v", err)
+	}
+
+	users := &countingStore{Store: NewMemoryStore(User{ID: "u1", Username: "alice", PasswordHash: hash})}
+	kv := storage.NewMemoryStore()
+	return NewLockoutStore(users, kv, policy), users, kv
+}
+
+func TestLockDuration(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	for failures, want := range []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if got := policy.lockDuration(failures); got != want {
+			t.Errorf("lockDuration(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
+
+func TestLockoutStore(t *testing.T) {
+	ctx := context.Background()
+	policy := LockoutPolicy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour}
+
+	t.Run("locks after the threshold", func(t *testing.T) {
+		s, users, kv := newTestLockoutStore(t, policy)
+
+		for i := 0; i < 3; i++ {
+			if _, err := s.Authenticate(ctx, "alice", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("Authenticate() #%d = %v, want ErrInvalidCredentials", i+1, err)
+			}
+		}
+
+		// The right password is not verified while locked.
+		if _, err := s.Authenticate(ctx, "alice", "correct horse"); !errors.Is(err, ErrLocked) {
+			t.Fatalf("Authenticate() of a locked account = %v, want ErrLocked", err)
+		}
+		if n := users.verified.Load(); n != 3 {
+			t.Errorf("verified %d passwords, want 3", n)
+		}
+
+		// Unknown users are locked the same way.
+		for i := 0; i < 3; i++ {
+			_, _ = s.Authenticate(ctx, "mallory", "guess")
+		}
+		if _, err := s.Authenticate(ctx, "mallory", "guess"); !errors.Is(err, ErrLocked) {
+			t.Errorf("Authenticate() of a locked unknown user = %v, want ErrLocked", err)
+		}
+
+		// Once the lock expires, one more failure locks for twice as long.
+		expire := func() {
+			if _, err := kv.Delete(ctx, "lockout:locked:alice"); err != nil {
+				t.Fatalf("Delete() = %v", err)
+			}
+		}
+		expire()
+		if _, err := s.Authenticate(ctx, "alice", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Authenticate() after the lock = %v, want ErrInvalidCredentials", err)
+		}
+		if ttl, _ := kv.TTL(ctx, "lockout:locked:alice"); ttl <= time.Minute || ttl > 2*time.Minute {
+			t.Errorf("lock after a further failure = %v, want 2m", ttl)
+		}
+		expire()
+		if u, err := s.Authenticate(ctx, "alice", "correct horse"); err != nil || u.ID != "u1" {
+			t.Errorf("Authenticate() after the lock = %+v, %v, want u1", u, err)
+		}
+	})
+
+	t.Run("success resets the failures", func(t *testing.T) {
+		s, _, _ := newTestLockoutStore(t, policy)
+
+		for round := 0; round < 3; round++ {
+			for i := 0; i < 2; i++ {
+				if _, err := s.Authenticate(ctx, "alice", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("Authenticate() = %v, want ErrInvalidCredentials", err)
+				}
+			}
+			if _, err := s.Authenticate(ctx, "alice", "correct horse"); err != nil {
+				t.Fatalf("Authenticate() = %v", err)
+			}
+		}
+	})
+
+	t.Run("success at the threshold", func(t *testing.T) {
+		s, _, _ := newTestLockoutStore(t, policy)
+
+		for i := 0; i < 2; i++ {
+			_, _ = s.Authenticate(ctx, "alice", "wrong")
+		}
+
+		// The attempt reaching the threshold took the lock, which its success releases.
+		if _, err := s.Authenticate(ctx, "alice", "correct horse"); err != nil {
+			t.Fatalf("Authenticate() = %v", err)
+		}
+		if _, err := s.Authenticate(ctx, "alice", "correct horse"); err != nil {
+			t.Errorf("Authenticate() after a success = %v", err)
+		}
+	})
+
+	t.Run("concurrent attempts", func(t *testing.T) {
+		s, users, _ := newTestLockoutStore(t, policy)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = s.Authenticate(ctx, "alice", "wrong")
+			}()
+		}
+		wg.Wait()
+
+		if n := users.verified.Load(); n != 3 {
+			t.Errorf("verified %d of 20 concurrent passwords, want 3", n)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package user
+
+import (
+	"context"
+	"fmt"
+)
+
+type memoryStore struct {
+	byID   map[string]User
+	byName map[string]User
+}
+
+func NewMemoryStore(users ...User) Store {
+	s := memoryStore{
+		byID:   make(map[string]User, len(users)),
+		byName: make(map[string]User, len(users)),
+	}
+
+	for _, u := range users {
+		s.byID[u.ID] = u
+		s.byName[u.Username] = u
+	}
+
+	return s
+}
+
+func (s memoryStore) Authenticate(_ context.Context, username, password string) (User, error) {
+	return authenticate(s.byName, username, password)
+}
+
+func (s memoryStore) Get(_ context.Context, id string) (User, error) {
+	u, ok := s.byID[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+
+	return u, nil
+}
+
+// authenticate verifies the password of the named user, spending the same effort when the user does not exist.
+func authenticate(byName map[string]User, username, password string) (User, error) {
+	u, ok := byName[username]
+	if !ok {
+		_, _ = VerifyPassword(dummyHash, password)
+		return User{}, ErrInvalidCredentials
+	}
+
+	matched, err := VerifyPassword(u.PasswordHash, password)
+	if err != nil {
+		return User{}, fmt.Errorf("could not verify password of user %q: %w", u.ID, err)
+	}
+
+	if !matched {
+		return User{}, ErrInvalidCredentials
+	}
+
+	return u, nil
+}
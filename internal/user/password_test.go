@@ -0,0 +1,60 @@
+package user
+
+import (
+	"testing"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	encoded, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		encoded  string
+		password string
+		want     bool
+		wantErr  bool
+	}{
+		{name: "matching password", encoded: encoded, password: "correct horse", want: true},
+		{name: "wrong password", encoded: encoded, password: "battery staple"},
+		{
+			name:     "zero parallelism",
+			encoded:  "$argon2id$v=19$m=65536,t=3,p=0$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U",
+			password: "correct horse",
+			wantErr:  true,
+		},
+		{
+			name:     "zero iterations",
+			encoded:  "$argon2id$v=19$m=65536,t=0,p=2$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U",
+			password: "correct horse",
+			wantErr:  true,
+		},
+		{
+			name:     "zero memory",
+			encoded:  "$argon2id$v=19$m=0,t=3,p=2$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U",
+			password: "correct horse",
+			wantErr:  true,
+		},
+		{
+			name:     "empty key",
+			encoded:  "$argon2id$v=19$m=65536,t=3,p=2$c2FsdHNhbHRzYWx0c2FsdA$",
+			password: "correct horse",
+			wantErr:  true,
+		},
+		{name: "unsupported hash", encoded: "plain", password: "plain", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyPassword(tt.encoded, tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
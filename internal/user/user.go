@@ -0,0 +1,35 @@
+package user
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrNotFound           = errors.New("user not found")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+)
+
+type User struct {
+	ID            string   `json:"id"`
+	Username      string   `json:"username"`
+	PasswordHash  string   `json:"password_hash"`
+	Name          string   `json:"name,omitempty"`
+	GivenName     string   `json:"given_name,omitempty"`
+	FamilyName    string   `json:"family_name,omitempty"`
+	Picture       string   `json:"picture,omitempty"`
+	Locale        string   `json:"locale,omitempty"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified bool     `json:"email_verified,omitempty"`
+	Roles         []string `json:"roles,omitempty"`
+	// Groups and Entitlements are released in access tokens following RFC 9068.
+	Groups       []string `json:"groups,omitempty"`
+	Entitlements []string `json:"entitlements,omitempty"`
+	// Metadata holds custom attributes claim mappings can copy into tokens.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type Store interface {
+	Authenticate(ctx context.Context, username, password string) (User, error)
+	Get(ctx context.Context, id string) (User, error)
+}
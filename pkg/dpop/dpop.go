@@ -0,0 +1,246 @@
+// Package dpop verifies DPoP proofs (RFC 9449) for authorization and resource servers.
+package dpop
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+)
+
+const (
+	HeaderName      = "DPoP"
+	NonceHeaderName = "DPoP-Nonce"
+	TokenType       = "DPoP"
+
+	proofType = "dpop+jwt"
+
+	defaultMaxAge = 5 * time.Minute
+	defaultLeeway = 30 * time.Second
+)
+
+var (
+	ErrInvalidProof = errors.New("invalid dpop proof")
+	// ErrUseNonce asks the client to retry with the nonce from the DPoP-Nonce header.
+	ErrUseNonce = errors.New("dpop proof requires a server nonce")
+)
+
+type ReplayCache interface {
+	// Use returns false when the identifier was already used and has not expired yet.
+	Use(ctx context.Context, id string, expiresAt time.Time) (bool, error)
+}
+
+type Proof struct {
+	// Thumbprint is the jkt confirmation of a DPoP-bound access token.
+	Thumbprint string
+	ID         string
+	IssuedAt   time.Time
+}
+
+type proofClaims struct {
+	JWTID           string `json:"jti"`
+	Method          string `json:"htm"`
+	URI             string `json:"htu"`
+	IssuedAt        int64  `json:"iat"`
+	AccessTokenHash string `json:"ath"`
+	Nonce           string `json:"nonce"`
+}
+
+type Verifier struct {
+	replays    ReplayCache
+	nonces     NonceSource
+	maxAge     time.Duration
+	leeway     time.Duration
+	algorithms []string
+}
+
+type Option func(*Verifier)
+
+func WithNonces(nonces NonceSource) Option {
+	return func(v *Verifier) {
+		v.nonces = nonces
+	}
+}
+
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(v *Verifier) {
+		v.maxAge = maxAge
+	}
+}
+
+func WithLeeway(leeway time.Duration) Option {
+	return func(v *Verifier) {
+		v.leeway = leeway
+	}
+}
+
+func WithAlgorithms(algorithms ...string) Option {
+	return func(v *Verifier) {
+		v.algorithms = algorithms
+	}
+}
+
+func NewVerifier(replays ReplayCache, opts ...Option) *Verifier {
+	v := &Verifier{
+		replays:    replays,
+		maxAge:     defaultMaxAge,
+		leeway:     defaultLeeway,
+		algorithms: jose.SupportedAlgorithms,
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+func (v *Verifier) Algorithms() []string {
+	return v.algorithms
+}
+
+// Nonce returns an empty string when no nonce is required.
+func (v *Verifier) Nonce(ctx context.Context) (string, error) {
+	if v.nonces == nil {
+		return "", nil
+	}
+
+	return v.nonces.Nonce(ctx)
+}
+
+// Verify requires the proof to be bound to the access token with the ath claim when one is given.
+func (v *Verifier) Verify(ctx context.Context, proof, method, uri, accessToken string) (Proof, error) {
+	token, err := jose.Parse(proof)
+	if err != nil {
+		return Proof{}, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+
+	if !strings.EqualFold(token.Header.Typ, proofType) || token.Header.JWK == nil {
+		return Proof{}, fmt.Errorf("%w: not a dpop+jwt with an embedded jwk", ErrInvalidProof)
+	}
+
+	if !slices.Contains(v.algorithms, token.Header.Alg) {
+		return Proof{}, fmt.Errorf("%w: %w", ErrInvalidProof, jose.ErrUnsupportedAlgorithm)
+	}
+
+	pub, err := token.Header.JWK.PublicKey()
+	if err != nil {
+		return Proof{}, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+
+	if err := token.Verify(pub); err != nil {
+		return Proof{}, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+
+	var claims proofClaims
+	if err := token.Claims(&claims); err != nil {
+		return Proof{}, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+
+	now := time.Now()
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	switch {
+	case claims.JWTID == "":
+		return Proof{}, fmt.Errorf("%w: missing jti", ErrInvalidProof)
+	case claims.Method != method:
+		return Proof{}, fmt.Errorf("%w: htm does not match the request", ErrInvalidProof)
+	case !sameURI(claims.URI, uri):
+		return Proof{}, fmt.Errorf("%w: htu does not match the request", ErrInvalidProof)
+	case claims.IssuedAt == 0 || issuedAt.After(now.Add(v.leeway)) || now.After(issuedAt.Add(v.maxAge+v.leeway)):
+		return Proof{}, fmt.Errorf("%w: iat is not recent", ErrInvalidProof)
+	case accessToken != "" && claims.AccessTokenHash != accessTokenHash(accessToken):
+		return Proof{}, fmt.Errorf("%w: ath does not match the access token", ErrInvalidProof)
+	}
+
+	if v.nonces != nil && !v.nonces.Valid(ctx, claims.Nonce) {
+		return Proof{}, ErrUseNonce
+	}
+
+	thumbprint, err := token.Header.JWK.Thumbprint()
+	if err != nil {
+		return Proof{}, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+
+	fresh, err := v.replays.Use(ctx, "dpop:"+thumbprint+":"+claims.JWTID, issuedAt.Add(v.maxAge+v.leeway))
+	if err != nil {
+		return Proof{}, err
+	}
+	if !fresh {
+		return Proof{}, fmt.Errorf("%w: jti was already used", ErrInvalidProof)
+	}
+
+	return Proof{Thumbprint: thumbprint, ID: claims.JWTID, IssuedAt: issuedAt}, nil
+}
+
+// VerifyRequest derives the URI from the request, servers behind a proxy rewriting it should call Verify
+// with the public URI instead.
+func (v *Verifier) VerifyRequest(r *http.Request, accessToken, jkt string) (Proof, error) {
+	proof, err := ProofHeader(r.Header)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	p, err := v.Verify(r.Context(), proof, r.Method, scheme+"://"+r.Host+r.URL.Path, accessToken)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	if p.Thumbprint != jkt {
+		return Proof{}, fmt.Errorf("%w: key does not match the access token", ErrInvalidProof)
+	}
+
+	return p, nil
+}
+
+func ProofHeader(h http.Header) (string, error) {
+	proofs := h.Values(HeaderName)
+	if len(proofs) != 1 || proofs[0] == "" {
+		return "", fmt.Errorf("%w: exactly one proof is required", ErrInvalidProof)
+	}
+
+	return proofs[0], nil
+}
+
+// sameURI ignores the query and fragment.
+func sameURI(htu, uri string) bool {
+	a, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+
+	b, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(hostPort(a), hostPort(b)) &&
+		a.EscapedPath() == b.EscapedPath()
+}
+
+// hostPort drops the default port of the scheme.
+func hostPort(u *url.URL) string {
+	switch {
+	case u.Port() == "80" && strings.EqualFold(u.Scheme, "http"), u.Port() == "443" && strings.EqualFold(u.Scheme, "https"):
+		return u.Hostname()
+	default:
+		return u.Host
+	}
+}
+
+func accessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,293 @@
+package dpop
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/the-witcher-knight/jwt-encryption-server/internal/jose"
+)
+
+const (
+	testURI         = "https://as.example.com/token"
+	testAccessToken = "access-token"
+)
+
+func TestVerify(t *testing.T) {
+	key := newKey(t)
+	otherKey := newKey(t)
+	now := time.Now()
+
+	claims := func(customize func(c map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{"htm": "POST", "htu": testURI, "iat": now.Unix(), "jti": newJTI(t)}
+		if customize != nil {
+			customize(c)
+		}
+		return c
+	}
+	set := func(name string, value interface{}) func(c map[string]interface{}) {
+		return func(c map[string]interface{}) { c[name] = value }
+	}
+
+	tests := []struct {
+		name        string
+		proof       string
+		accessToken string
+		wantErr     error
+	}{
+		{name: "valid proof", proof: signProof(t, key, jose.Header{}, claims(nil))},
+		{name: "typ of a JWT", proof: signProof(t, key, jose.Header{Typ: "JWT"}, claims(nil)), wantErr: ErrInvalidProof},
+		{name: "no embedded key", proof: signProof(t, key, jose.Header{JWK: &jose.JWK{}}, claims(nil)), wantErr: ErrInvalidProof},
+		{name: "unsigned", proof: unsignedProof(t, key, claims(nil)), wantErr: ErrInvalidProof},
+		{name: "HMAC", proof: signProof(t, key, jose.Header{Alg: "HS256"}, claims(nil)), wantErr: ErrInvalidProof},
+		{name: "signed with another key", proof: signProof(t, otherKey, jose.Header{JWK: ptr(publicJWK(key))}, claims(nil)), wantErr: ErrInvalidProof},
+		{name: "missing jti", proof: signProof(t, key, jose.Header{}, claims(func(c map[string]interface{}) { delete(c, "jti") })), wantErr: ErrInvalidProof},
+
+		{name: "other method", proof: signProof(t, key, jose.Header{}, claims(set("htm", "GET"))), wantErr: ErrInvalidProof},
+		{name: "lowercase method", proof: signProof(t, key, jose.Header{}, claims(set("htm", "post"))), wantErr: ErrInvalidProof},
+		{name: "other path", proof: signProof(t, key, jose.Header{}, claims(set("htu", "https://as.example.com/userinfo"))), wantErr: ErrInvalidProof},
+		{name: "other scheme", proof: signProof(t, key, jose.Header{}, claims(set("htu", "http://as.example.com/token"))), wantErr: ErrInvalidProof},
+		{name: "other host", proof: signProof(t, key, jose.Header{}, claims(set("htu", "https://rs.example.com/token"))), wantErr: ErrInvalidProof},
+		{name: "other port", proof: signProof(t, key, jose.Header{}, claims(set("htu", "https://as.example.com:8443/token"))), wantErr: ErrInvalidProof},
+		{name: "query and fragment", proof: signProof(t, key, jose.Header{}, claims(set("htu", testURI+"?a=b#c")))},
+		{name: "default port", proof: signProof(t, key, jose.Header{}, claims(set("htu", "https://as.example.com:443/token")))},
+		{name: "uppercase scheme and host", proof: signProof(t, key, jose.Header{}, claims(set("htu", "HTTPS://AS.EXAMPLE.COM/token")))},
+
+		{name: "no iat", proof: signProof(t, key, jose.Header{}, claims(set("iat", 0))), wantErr: ErrInvalidProof},
+		{name: "stale iat", proof: signProof(t, key, jose.Header{}, claims(set("iat", now.Add(-6*time.Minute).Unix()))), wantErr: ErrInvalidProof},
+		{name: "iat within the max age", proof: signProof(t, key, jose.Header{}, claims(set("iat", now.Add(-4*time.Minute).Unix())))},
+		{name: "iat ahead within the leeway", proof: signProof(t, key, jose.Header{}, claims(set("iat", now.Add(20*time.Second).Unix())))},
+		{name: "future iat", proof: signProof(t, key, jose.Header{}, claims(set("iat", now.Add(2*time.Minute).Unix()))), wantErr: ErrInvalidProof},
+
+		{
+			name:        "ath of the access token",
+			proof:       signProof(t, key, jose.Header{}, claims(set("ath", tokenHash(testAccessToken)))),
+			accessToken: testAccessToken,
+		},
+		{
+			name:        "ath of another token",
+			proof:       signProof(t, key, jose.Header{}, claims(set("ath", tokenHash("other-token")))),
+			accessToken: testAccessToken,
+			wantErr:     ErrInvalidProof,
+		},
+		{name: "no ath with an access token", proof: signProof(t, key, jose.Header{}, claims(nil)), accessToken: testAccessToken, wantErr: ErrInvalidProof},
+	}
+
+	v := NewVerifier(newMemoryReplays())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := v.Verify(context.Background(), tt.proof, "POST", testURI, tt.accessToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() = %v, want %v", err, tt.wantErr)
+			}
+
+			if want, _ := publicJWK(key).Thumbprint(); err == nil && p.Thumbprint != want {
+				t.Errorf("Thumbprint = %q, want %q", p.Thumbprint, want)
+			}
+		})
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	key := newKey(t)
+	v := NewVerifier(newMemoryReplays())
+
+	proof := signProof(t, key, jose.Header{}, map[string]interface{}{"htm": "POST", "htu": testURI, "iat": time.Now().Unix(), "jti": "once"})
+	if _, err := v.Verify(context.Background(), proof, "POST", testURI, ""); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+	if _, err := v.Verify(context.Background(), proof, "POST", testURI, ""); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("Verify() of a replayed proof = %v, want %v", err, ErrInvalidProof)
+	}
+
+	// The jti is remembered per key, another key may use it.
+	other := signProof(t, newKey(t), jose.Header{}, map[string]interface{}{"htm": "POST", "htu": testURI, "iat": time.Now().Unix(), "jti": "once"})
+	if _, err := v.Verify(context.Background(), other, "POST", testURI, ""); err != nil {
+		t.Errorf("Verify() of the jti with another key = %v", err)
+	}
+}
+
+func TestVerifyNonce(t *testing.T) {
+	ctx := context.Background()
+	key := newKey(t)
+	store := newMemoryNonceStore()
+	nonces := &sharedNonces{store: store, interval: time.Hour}
+	v := NewVerifier(newMemoryReplays(), WithNonces(nonces))
+
+	current, err := v.Nonce(ctx)
+	if err != nil {
+		t.Fatalf("Nonce() = %v", err)
+	}
+	if again, _ := v.Nonce(ctx); again != current {
+		t.Errorf("Nonce() changed within an interval: %q, then %q", current, again)
+	}
+
+	// Nonces of the previous intervals, as if rotated.
+	interval := time.Now().UnixNano() / int64(time.Hour)
+	store.values[nonces.key(interval-1)] = []byte("previous")
+	store.values[nonces.key(interval-2)] = []byte("stale")
+
+	tests := []struct {
+		name    string
+		nonce   string
+		wantErr error
+	}{
+		{name: "current nonce", nonce: current},
+		{name: "nonce of the previous interval", nonce: "previous"},
+		{name: "no nonce", wantErr: ErrUseNonce},
+		{name: "nonce two intervals old", nonce: "stale", wantErr: ErrUseNonce},
+		{name: "unknown nonce", nonce: "made-up", wantErr: ErrUseNonce},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := map[string]interface{}{"htm": "POST", "htu": testURI, "iat": time.Now().Unix(), "jti": newJTI(t)}
+			if tt.nonce != "" {
+				c["nonce"] = tt.nonce
+			}
+
+			if _, err := v.Verify(ctx, signProof(t, key, jose.Header{}, c), "POST", testURI, ""); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Without a nonce source no nonce is required or issued.
+	if nonce, err := NewVerifier(newMemoryReplays()).Nonce(ctx); err != nil || nonce != "" {
+		t.Errorf("Nonce() without nonces = %q, %v", nonce, err)
+	}
+}
+
+type memoryReplays struct {
+	mu   sync.Mutex
+	used map[string]bool
+}
+
+func newMemoryReplays() *memoryReplays {
+	return &memoryReplays{used: map[string]bool{}}
+}
+
+func (r *memoryReplays) Use(_ context.Context, id string, _ time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.used[id] {
+		return false, nil
+	}
+	r.used[id] = true
+	return true, nil
+}
+
+type memoryNonceStore struct {
+	values map[string][]byte
+}
+
+func newMemoryNonceStore() *memoryNonceStore {
+	return &memoryNonceStore{values: map[string][]byte{}}
+}
+
+func (s *memoryNonceStore) SetNX(_ context.Context, key string, value []byte, _ time.Duration) (bool, error) {
+	if _, ok := s.values[key]; ok {
+		return false, nil
+	}
+	s.values[key] = value
+	return true, nil
+}
+
+func (s *memoryNonceStore) Get(_ context.Context, key string) ([]byte, error) {
+	v, ok := s.values[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return v, nil
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	return key
+}
+
+func newJTI(t *testing.T) string {
+	t.Helper()
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("could not generate jti: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func publicJWK(key *ecdsa.PrivateKey) jose.JWK {
+	return jose.JWK{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("could not marshal %v: %v", v, err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signProof defaults the header to a dpop+jwt with the public key; a JWK without a key type leaves it out.
+func signProof(t *testing.T, key *ecdsa.PrivateKey, header jose.Header, claims map[string]interface{}) string {
+	t.Helper()
+
+	if header.Alg == "" {
+		header.Alg = jose.AlgES256
+	}
+	if header.Typ == "" {
+		header.Typ = proofType
+	}
+	switch {
+	case header.JWK == nil:
+		header.JWK = ptr(publicJWK(key))
+	case header.JWK.Kty == "":
+		header.JWK = nil
+	}
+
+	input := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("could not sign proof: %v", err)
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...))
+}
+
+func unsignedProof(t *testing.T, key *ecdsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+
+	jwk := publicJWK(key)
+	return encodeSegment(t, jose.Header{Alg: "none", Typ: proofType, JWK: &jwk}) + "." + encodeSegment(t, claims) + "."
+}
@@ -0,0 +1,76 @@
+package dpop
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// NonceSource limits how long proofs created in advance remain usable.
+type NonceSource interface {
+	Nonce(ctx context.Context) (string, error)
+	Valid(ctx context.Context, nonce string) bool
+}
+
+// NonceStore is typically a Redis-backed key-value store.
+type NonceStore interface {
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Get returns an error when key does not exist.
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+type sharedNonces struct {
+	store    NonceStore
+	interval time.Duration
+}
+
+// NewSharedNonces keeps the previous nonce valid so clients racing a rotation are not rejected. Servers
+// sharing the store accept the nonces each of them issues.
+func NewSharedNonces(store NonceStore, interval time.Duration) NonceSource {
+	return &sharedNonces{
+		store:    store,
+		interval: interval,
+	}
+}
+
+func (n *sharedNonces) Nonce(ctx context.Context) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	key := n.key(time.Now().UnixNano() / int64(n.interval))
+	if _, err := n.store.SetNX(ctx, key, []byte(base64.RawURLEncoding.EncodeToString(b)), 2*n.interval); err != nil {
+		return "", err
+	}
+
+	nonce, err := n.store.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	return string(nonce), nil
+}
+
+func (n *sharedNonces) Valid(ctx context.Context, nonce string) bool {
+	if nonce == "" {
+		return false
+	}
+
+	current := time.Now().UnixNano() / int64(n.interval)
+	for _, interval := range []int64{current, current - 1} {
+		stored, err := n.store.Get(ctx, n.key(interval))
+		if err == nil && subtle.ConstantTimeCompare([]byte(nonce), stored) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n *sharedNonces) key(interval int64) string {
+	return "dpop_nonce:" + strconv.FormatInt(interval, 10)
+}
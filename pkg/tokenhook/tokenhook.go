@@ -0,0 +1,235 @@
+// Package tokenhook calls the pre-issuance token hook. Requests are signed with HMAC-SHA256; receivers
+// check them with Verify.
+package tokenhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SignatureHeader is the request header carrying the signature of the body, in the form
+	// t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">.
+	SignatureHeader = "X-Hook-Signature"
+
+	DefaultFailureThreshold = 5
+	DefaultCooldown         = 30 * time.Second
+
+	defaultTimeout  = 2 * time.Second
+	maxResponseSize = 1 << 20
+)
+
+var (
+	// ErrUnavailable leaves it to the caller whether issuance fails open or closed.
+	ErrUnavailable      = errors.New("token hook is unavailable")
+	ErrInvalidSignature = errors.New("invalid token hook signature")
+)
+
+type Request struct {
+	GrantType string                 `json:"grant_type"`
+	Client    map[string]interface{} `json:"client"`
+	User      map[string]interface{} `json:"user,omitempty"`
+	Claims    map[string]interface{} `json:"claims"`
+}
+
+type Response struct {
+	// Deny refuses to issue the token, telling the client the reason when one is given.
+	Deny   bool                   `json:"deny,omitempty"`
+	Reason string                 `json:"reason,omitempty"`
+	Claims map[string]interface{} `json:"claims,omitempty"`
+}
+
+type Hook interface {
+	// Call reports every failure as ErrUnavailable.
+	Call(ctx context.Context, req Request) (Response, error)
+}
+
+type httpHook struct {
+	url     string
+	secret  []byte
+	client  *http.Client
+	timeout time.Duration
+	breaker *breaker
+}
+
+type Option func(*httpHook)
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *httpHook) {
+		h.timeout = timeout
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(h *httpHook) {
+		h.client = client
+	}
+}
+
+// WithCircuitBreaker stops calling the hook for cooldown after threshold consecutive failures. A single call
+// is then let through, closing the circuit when it succeeds.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(h *httpHook) {
+		h.breaker = &breaker{threshold: threshold, cooldown: cooldown}
+	}
+}
+
+func NewHTTPHook(url string, secret []byte, opts ...Option) Hook {
+	h := &httpHook{
+		url:     url,
+		secret:  secret,
+		client:  http.DefaultClient,
+		timeout: defaultTimeout,
+		breaker: &breaker{threshold: DefaultFailureThreshold, cooldown: DefaultCooldown},
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+func (h *httpHook) Call(ctx context.Context, req Request) (Response, error) {
+	if !h.breaker.allow() {
+		return Response{}, fmt.Errorf("%w: circuit is open", ErrUnavailable)
+	}
+
+	resp, err := h.call(ctx, req)
+	h.breaker.record(err == nil)
+	if err != nil {
+		return Response{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	return resp, nil
+}
+
+func (h *httpHook) call(ctx context.Context, req Request) (Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(SignatureHeader, Sign(h.secret, time.Now(), body))
+
+	httpResp, err := h.client.Do(httpReq)
+	if err != nil {
+		return Response{}, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return Response{}, fmt.Errorf("unexpected status %d", httpResp.StatusCode)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, maxResponseSize)).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("could not decode response: %w", err)
+	}
+
+	return resp, nil
+}
+
+func Sign(secret []byte, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+// Verify rejects signatures made more than tolerance ago to limit replays.
+func Verify(secret []byte, header string, body []byte, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+
+	if age := time.Since(time.Unix(sec, 0)); age > tolerance || age < -tolerance {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func mac(secret []byte, ts string, body []byte) string {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(ts + "."))
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow lets a single probing call through once the cooldown is over.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+
+	b.probing = true
+	return true
+}
+
+func (b *breaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
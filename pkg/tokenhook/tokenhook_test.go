@@ -0,0 +1,207 @@
+package tokenhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("hook-secret")
+	body := []byte(`{"grant_type":"client_credentials"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		wantErr bool
+	}{
+		{name: "valid", header: Sign(secret, now, body), body: body},
+		{name: "within the tolerance", header: Sign(secret, now.Add(-4*time.Minute), body), body: body},
+		{name: "clock ahead within the tolerance", header: Sign(secret, now.Add(4*time.Minute), body), body: body},
+		{name: "too old", header: Sign(secret, now.Add(-6*time.Minute), body), body: body, wantErr: true},
+		{name: "too far ahead", header: Sign(secret, now.Add(6*time.Minute), body), body: body, wantErr: true},
+		{name: "tampered body", header: Sign(secret, now, body), body: []byte(`{"grant_type":"password"}`), wantErr: true},
+		{name: "other secret", header: Sign([]byte("other-secret"), now, body), body: body, wantErr: true},
+		{
+			// The timestamp is signed: moving it forward to pass the tolerance breaks the signature.
+			name:    "replaced timestamp",
+			header:  strings.Replace(Sign(secret, now.Add(-time.Hour), body), "t="+unix(now.Add(-time.Hour)), "t="+unix(now), 1),
+			body:    body,
+			wantErr: true,
+		},
+		{name: "no signature", header: "t=" + unix(now), body: body, wantErr: true},
+		{name: "no timestamp", header: "v1=" + mac(secret, unix(now), body), body: body, wantErr: true},
+		{name: "empty", body: body, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(secret, tt.header, tt.body, 5*time.Minute)
+			if tt.wantErr && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify() = %v, want ErrInvalidSignature", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Verify() = %v", err)
+			}
+		})
+	}
+}
+
+func unix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+func newTestHookServer(t *testing.T, secret []byte, fail *atomic.Bool, calls *atomic.Int64) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(secret, r.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if fail.Load() {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+
+		var req Request
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Response{Claims: map[string]interface{}{"tier": "gold", "grant": req.GrantType}})
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestHTTPHook(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("hook-secret")
+	var fail atomic.Bool
+	var calls atomic.Int64
+	srv := newTestHookServer(t, secret, &fail, &calls)
+	serve := func(fn http.HandlerFunc) string {
+		other := httptest.NewServer(fn)
+		t.Cleanup(other.Close)
+		return other.URL
+	}
+
+	resp, err := NewHTTPHook(srv.URL, secret).Call(ctx, Request{GrantType: "client_credentials"})
+	if err != nil {
+		t.Fatalf("Call() = %v", err)
+	}
+	if resp.Claims["tier"] != "gold" || resp.Claims["grant"] != "client_credentials" {
+		t.Errorf("Call() = %+v", resp)
+	}
+
+	tests := []struct {
+		name string
+		hook Hook
+	}{
+		{name: "wrong secret", hook: NewHTTPHook(srv.URL, []byte("other-secret"))},
+		{name: "unreachable", hook: NewHTTPHook("http://127.0.0.1:1", secret)},
+		{
+			name: "malformed response",
+			hook: NewHTTPHook(serve(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("<html>"))
+			}), secret),
+		},
+		{
+			name: "too slow",
+			hook: NewHTTPHook(serve(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(200 * time.Millisecond):
+				}
+			}), secret, WithTimeout(20*time.Millisecond)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.hook.Call(ctx, Request{}); !errors.Is(err, ErrUnavailable) {
+				t.Errorf("Call() = %v, want ErrUnavailable", err)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("hook-secret")
+	var fail atomic.Bool
+	var calls atomic.Int64
+	srv := newTestHookServer(t, secret, &fail, &calls)
+	hook := NewHTTPHook(srv.URL, secret, WithCircuitBreaker(2, 50*time.Millisecond))
+
+	call := func() error {
+		_, err := hook.Call(ctx, Request{})
+		return err
+	}
+
+	// Consecutive failures open the circuit, calls then fail without reaching the hook.
+	fail.Store(true)
+	for i := 0; i < 2; i++ {
+		if err := call(); !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("Call() = %v, want ErrUnavailable", err)
+		}
+	}
+	fail.Store(false)
+	if err := call(); !errors.Is(err, ErrUnavailable) || calls.Load() != 2 {
+		t.Fatalf("Call() of an open circuit = %v after %d calls of the hook, want ErrUnavailable after 2", err, calls.Load())
+	}
+
+	// After the cooldown a failing probe opens the circuit again.
+	time.Sleep(60 * time.Millisecond)
+	fail.Store(true)
+	if err := call(); !errors.Is(err, ErrUnavailable) || calls.Load() != 3 {
+		t.Fatalf("probing Call() = %v after %d calls of the hook, want a failed probe", err, calls.Load())
+	}
+	fail.Store(false)
+	if err := call(); !errors.Is(err, ErrUnavailable) || calls.Load() != 3 {
+		t.Fatalf("Call() after a failed probe = %v after %d calls of the hook, want the circuit open", err, calls.Load())
+	}
+
+	// A successful probe closes the circuit.
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if err := call(); err != nil {
+			t.Fatalf("Call() #%d after a successful probe = %v", i+1, err)
+		}
+	}
+	if calls.Load() != 6 {
+		t.Errorf("hook called %d times, want 6", calls.Load())
+	}
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	b := &breaker{threshold: 1, cooldown: time.Millisecond}
+	b.record(false)
+	time.Sleep(2 * time.Millisecond)
+
+	if !b.allow() {
+		t.Fatal("allow() after the cooldown = false, want a probe")
+	}
+	if b.allow() {
+		t.Error("allow() during a probe = true, want a single probe")
+	}
+
+	b.record(true)
+	if !b.allow() || !b.allow() {
+		t.Error("allow() after a successful probe = false, want the circuit closed")
+	}
+}